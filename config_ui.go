@@ -26,6 +26,10 @@ type UIConfig struct {
 	FileStreamingContentTypes []string `bson:"file_streaming_content_types" json:"file_streaming_content_types" yaml:"file_streaming_content_types"` // allowed content types for the file streaming route.
 	LoginDomain               string   `bson:"login_domain" json:"login_domain" yaml:"login_domain"`                                                 // domain for the login cookie (defaults to domain of app)
 	UserVoice                 string   `bson:"userVoice" json:"userVoice" yaml:"userVoice"`
+	// OmitSpruceRedirect drops the redirect_spruce_users query parameter
+	// from the UI links sent in GitHub statuses, for deployments without
+	// Spruce.
+	OmitSpruceRedirect bool `bson:"omit_spruce_redirect" json:"omit_spruce_redirect" yaml:"omit_spruce_redirect"`
 }
 
 func (c *UIConfig) SectionId() string { return "ui" }
@@ -62,6 +66,7 @@ func (c *UIConfig) Set(ctx context.Context) error {
 			"file_streaming_content_types": c.FileStreamingContentTypes,
 			"login_domain":                 c.LoginDomain,
 			"userVoice":                    c.UserVoice,
+			"omit_spruce_redirect":         c.OmitSpruceRedirect,
 		},
 	}, options.Update().SetUpsert(true))
 
@@ -353,7 +353,20 @@ func (b Builds) InsertMany(ctx context.Context, ordered bool) error {
 // the statuses of tasks in the build, to be used by jobs and notification
 // processing.
 func (b *Build) GetPRNotificationDescription(tasks []task.Task) string {
+	return b.getPRNotificationDescription(tasks, false)
+}
+
+// GetPRNotificationDescriptionWithRetries is the same as
+// GetPRNotificationDescription, but also notes how many of the successful
+// tasks only succeeded after being retried, so that flaky tasks aren't
+// hidden by a successful build.
+func (b *Build) GetPRNotificationDescriptionWithRetries(tasks []task.Task) string {
+	return b.getPRNotificationDescription(tasks, true)
+}
+
+func (b *Build) getPRNotificationDescription(tasks []task.Task, showRetries bool) string {
 	success := 0
+	retried := 0
 	failed := 0
 	other := 0
 	runningOrWillRun := 0
@@ -362,6 +375,9 @@ func (b *Build) GetPRNotificationDescription(tasks []task.Task) string {
 		switch {
 		case t.Status == evergreen.TaskSucceeded:
 			success++
+			if t.Execution > 0 {
+				retried++
+			}
 
 		case t.Status == evergreen.TaskFailed:
 			failed++
@@ -395,8 +411,11 @@ func (b *Build) GetPRNotificationDescription(tasks []task.Task) string {
 		return "no tasks were run"
 	}
 
-	desc := fmt.Sprintf("%s, %s", taskStatusSubformat(success, "succeeded"),
-		taskStatusSubformat(failed, "failed"))
+	succeeded := taskStatusSubformat(success, "succeeded")
+	if showRetries && retried > 0 {
+		succeeded = fmt.Sprintf("%s (%d after retry)", succeeded, retried)
+	}
+	desc := fmt.Sprintf("%s, %s", succeeded, taskStatusSubformat(failed, "failed"))
 	if unscheduledEssential > 0 {
 		desc = fmt.Sprintf("%s, %s", desc, unscheduledEssentialTaskStatusSubformat(unscheduledEssential))
 	}
@@ -533,6 +533,15 @@ func TestGetPRNotificationDescription(t *testing.T) {
 		}
 		assert.Equal(t, "1 succeeded, none failed in 10s", b.GetPRNotificationDescription(tasks))
 	})
+	t.Run("RetriedTasksAreOnlyNotedWhenRequested", func(t *testing.T) {
+		tasks := []task.Task{
+			{Status: evergreen.TaskSucceeded},
+			{Status: evergreen.TaskSucceeded, Execution: 1},
+			{Status: evergreen.TaskFailed, Execution: 1},
+		}
+		assert.Equal(t, "2 succeeded, 1 failed in 10s", b.GetPRNotificationDescription(tasks))
+		assert.Equal(t, "2 succeeded (1 after retry), 1 failed in 10s", b.GetPRNotificationDescriptionWithRetries(tasks))
+	})
 	t.Run("OneFailedTasksReturnsNoSuccessAndOneFailure", func(t *testing.T) {
 		tasks := []task.Task{
 			{
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -248,20 +250,106 @@ type FinderSettings struct {
 }
 
 type PlannerSettings struct {
-	Version                   string        `bson:"version" json:"version" mapstructure:"version"`
-	TargetTime                time.Duration `bson:"target_time" json:"target_time" mapstructure:"target_time,omitempty"`
-	GroupVersions             *bool         `bson:"group_versions" json:"group_versions" mapstructure:"group_versions,omitempty"`
-	PatchFactor               int64         `bson:"patch_zipper_factor" json:"patch_factor" mapstructure:"patch_factor"`
-	PatchTimeInQueueFactor    int64         `bson:"patch_time_in_queue_factor" json:"patch_time_in_queue_factor" mapstructure:"patch_time_in_queue_factor"`
-	CommitQueueFactor         int64         `bson:"commit_queue_factor" json:"commit_queue_factor" mapstructure:"commit_queue_factor"`
-	MainlineTimeInQueueFactor int64         `bson:"mainline_time_in_queue_factor" json:"mainline_time_in_queue_factor" mapstructure:"mainline_time_in_queue_factor"`
-	ExpectedRuntimeFactor     int64         `bson:"expected_runtime_factor" json:"expected_runtime_factor" mapstructure:"expected_runtime_factor"`
-	GenerateTaskFactor        int64         `bson:"generate_task_factor" json:"generate_task_factor" mapstructure:"generate_task_factor"`
-	StepbackTaskFactor        int64         `bson:"stepback_task_factor" json:"stepback_task_factor" mapstructure:"stepback_task_factor"`
+	Version                    string        `bson:"version" json:"version" mapstructure:"version"`
+	TargetTime                 time.Duration `bson:"target_time" json:"target_time" mapstructure:"target_time,omitempty"`
+	GroupVersions              *bool         `bson:"group_versions" json:"group_versions" mapstructure:"group_versions,omitempty"`
+	PatchFactor                int64         `bson:"patch_zipper_factor" json:"patch_factor" mapstructure:"patch_factor"`
+	PatchTimeInQueueFactor     int64         `bson:"patch_time_in_queue_factor" json:"patch_time_in_queue_factor" mapstructure:"patch_time_in_queue_factor"`
+	CommitQueueFactor          int64         `bson:"commit_queue_factor" json:"commit_queue_factor" mapstructure:"commit_queue_factor"`
+	MainlineTimeInQueueFactor  int64         `bson:"mainline_time_in_queue_factor" json:"mainline_time_in_queue_factor" mapstructure:"mainline_time_in_queue_factor"`
+	ExpectedRuntimeFactor      int64         `bson:"expected_runtime_factor" json:"expected_runtime_factor" mapstructure:"expected_runtime_factor"`
+	GenerateTaskFactor         int64         `bson:"generate_task_factor" json:"generate_task_factor" mapstructure:"generate_task_factor"`
+	StepbackTaskFactor         int64         `bson:"stepback_task_factor" json:"stepback_task_factor" mapstructure:"stepback_task_factor"`
+	StrictPriorityTiers        *bool         `bson:"strict_priority_tiers" json:"strict_priority_tiers" mapstructure:"strict_priority_tiers,omitempty"`
+	CommitQueueOverPatchMargin int64         `bson:"commit_queue_over_patch_margin" json:"commit_queue_over_patch_margin" mapstructure:"commit_queue_over_patch_margin"`
+	ExcludeDeactivatedTasks    *bool         `bson:"exclude_deactivated_tasks" json:"exclude_deactivated_tasks" mapstructure:"exclude_deactivated_tasks,omitempty"`
+	MainlineReservationRatio   float64       `bson:"mainline_reservation_ratio" json:"mainline_reservation_ratio" mapstructure:"mainline_reservation_ratio"`
+	ProjectPriorityFactor      int64         `bson:"project_priority_factor" json:"project_priority_factor" mapstructure:"project_priority_factor"`
+	SchedulingObjective        string        `bson:"scheduling_objective" json:"scheduling_objective" mapstructure:"scheduling_objective,omitempty"`
+	ScaleByIdleHosts           *bool         `bson:"scale_by_idle_hosts" json:"scale_by_idle_hosts" mapstructure:"scale_by_idle_hosts,omitempty"`
+	MaxUnitSize                int           `bson:"max_unit_size" json:"max_unit_size" mapstructure:"max_unit_size"`
+	RankValueEpsilon           int64         `bson:"rank_value_epsilon" json:"rank_value_epsilon" mapstructure:"rank_value_epsilon"`
+	QuarantineFactor           int64         `bson:"quarantine_factor" json:"quarantine_factor" mapstructure:"quarantine_factor"`
+	ExpectedRuntimePercentile  int           `bson:"expected_runtime_percentile" json:"expected_runtime_percentile" mapstructure:"expected_runtime_percentile"`
+	MaxBoostedGenerators       int           `bson:"max_boosted_generators" json:"max_boosted_generators" mapstructure:"max_boosted_generators"`
+	TimeInQueueCurve           string        `bson:"time_in_queue_curve" json:"time_in_queue_curve" mapstructure:"time_in_queue_curve,omitempty"`
+	PrioritizeBuildCompletion  *bool         `bson:"prioritize_build_completion" json:"prioritize_build_completion" mapstructure:"prioritize_build_completion,omitempty"`
+	PreserveTaskOrder          *bool         `bson:"preserve_task_order" json:"preserve_task_order" mapstructure:"preserve_task_order,omitempty"`
+	DefaultTaskDuration        time.Duration `bson:"default_task_duration" json:"default_task_duration" mapstructure:"default_task_duration,omitempty"`
+	MinPriorityMultiplier      int64         `bson:"min_priority_multiplier" json:"min_priority_multiplier" mapstructure:"min_priority_multiplier"`
+	ScaleByThroughput          *bool         `bson:"scale_by_throughput" json:"scale_by_throughput" mapstructure:"scale_by_throughput,omitempty"`
+	ExpectedThroughput         float64       `bson:"expected_throughput" json:"expected_throughput" mapstructure:"expected_throughput"`
+	StepbackDecayFactor        float64       `bson:"stepback_decay_factor" json:"stepback_decay_factor" mapstructure:"stepback_decay_factor"`
+
+	// RequesterPatchFactors overrides the patch factor for units whose
+	// tasks are predominantly from the given patch requester.
+	RequesterPatchFactors map[string]int64 `bson:"requester_patch_factors,omitempty" json:"requester_patch_factors,omitempty" mapstructure:"requester_patch_factors,omitempty"`
+	// RequesterGroupVersions overrides GroupVersions for tasks from the
+	// given requesters.
+	RequesterGroupVersions map[string]bool `bson:"requester_group_versions,omitempty" json:"requester_group_versions,omitempty" mapstructure:"requester_group_versions,omitempty"`
 
 	maxDurationPerHost time.Duration
+	// factors are the planner factors, along with their sources, which
+	// are only set when the settings are resolved.
+	factors ResolvedFactors
 }
 
+// DefaultCommitQueueOverPatchMargin is the default amount added to the
+// priority of commit queue units by the planner.
+const DefaultCommitQueueOverPatchMargin = 200
+
+// DefaultQuarantineFactor is the default factor by which the planner
+// reduces the rank of units of quarantined tasks.
+const DefaultQuarantineFactor = 10
+
+// DefaultMinPriorityMultiplier is the default smallest priority that
+// the planner multiplies a unit's rank terms by.
+const DefaultMinPriorityMultiplier = 1
+
+// Scheduling objectives determine how the planner weighs the expected
+// runtime of units.
+const (
+	// SchedulingObjectiveMakespan ranks longer units first, to reduce
+	// the total time to finish all of the tasks in the queue.
+	SchedulingObjectiveMakespan = "makespan"
+	// SchedulingObjectiveLatency ranks shorter units first, to
+	// complete as many tasks as possible quickly.
+	SchedulingObjectiveLatency = "latency"
+)
+
+// ValidSchedulingObjectives are all of the valid scheduling objectives.
+var ValidSchedulingObjectives = []string{SchedulingObjectiveMakespan, SchedulingObjectiveLatency}
+
+// Time in queue curves determine how quickly the contribution of the time
+// a unit has spent in the queue to its rank value grows.
+const (
+	// TimeInQueueCurveLinear grows the contribution in proportion to
+	// the time in queue.
+	TimeInQueueCurveLinear = "linear"
+	// TimeInQueueCurveSqrt grows the contribution with the square root
+	// of the time in queue.
+	TimeInQueueCurveSqrt = "sqrt"
+	// TimeInQueueCurveLog grows the contribution with the logarithm of
+	// the time in queue.
+	TimeInQueueCurveLog = "log"
+)
+
+// ValidTimeInQueueCurves are all of the valid time in queue curves.
+var ValidTimeInQueueCurves = []string{TimeInQueueCurveLinear, TimeInQueueCurveSqrt, TimeInQueueCurveLog}
+
+// Environment variables that, when set, override the corresponding
+// planner factors, so that a change to a factor can be tried out on a
+// single scheduler instance.
+const (
+	PatchFactorEnvVar               = "EVERGREEN_PATCH_FACTOR"
+	PatchTimeInQueueFactorEnvVar    = "EVERGREEN_PATCH_TIME_IN_QUEUE_FACTOR"
+	CommitQueueFactorEnvVar         = "EVERGREEN_COMMIT_QUEUE_FACTOR"
+	MainlineTimeInQueueFactorEnvVar = "EVERGREEN_MAINLINE_TIME_IN_QUEUE_FACTOR"
+	ExpectedRuntimeFactorEnvVar     = "EVERGREEN_EXPECTED_RUNTIME_FACTOR"
+	GenerateTaskFactorEnvVar        = "EVERGREEN_GENERATE_TASK_FACTOR"
+	StepbackTaskFactorEnvVar        = "EVERGREEN_STEPBACK_TASK_FACTOR"
+)
+
 type DispatcherSettings struct {
 	Version string `bson:"version" json:"version" mapstructure:"version"`
 }
@@ -310,10 +398,102 @@ func init() {
 	rand.Seed(time.Now().UnixNano())
 }
 
+// ApplyFactorOverrides replaces the planner factors with the values of
+// their environment variables, for those that are set. Values that are
+// not positive integers are ignored. Since this reads the environment,
+// it should be called once when planning starts rather than every time
+// a factor is used.
+func (s *PlannerSettings) ApplyFactorOverrides() {
+	for envVar, factor := range map[string]*int64{
+		PatchFactorEnvVar:               &s.PatchFactor,
+		PatchTimeInQueueFactorEnvVar:    &s.PatchTimeInQueueFactor,
+		CommitQueueFactorEnvVar:         &s.CommitQueueFactor,
+		MainlineTimeInQueueFactorEnvVar: &s.MainlineTimeInQueueFactor,
+		ExpectedRuntimeFactorEnvVar:     &s.ExpectedRuntimeFactor,
+		GenerateTaskFactorEnvVar:        &s.GenerateTaskFactor,
+		StepbackTaskFactorEnvVar:        &s.StepbackTaskFactor,
+	} {
+		if value, ok := factorOverride(envVar); ok {
+			*factor = value
+		}
+	}
+}
+
+// factorOverride returns the value of the environment variable that
+// overrides a planner factor, if it's set to a positive integer.
+func factorOverride(envVar string) (int64, bool) {
+	value, err := strconv.ParseInt(os.Getenv(envVar), 10, 64)
+	if err != nil || value <= 0 {
+		return 0, false
+	}
+
+	return value, true
+}
+
+// Sources of the values of planner factors.
+const (
+	// FactorSourceDefault indicates that the factor isn't set, so the
+	// planner uses its default.
+	FactorSourceDefault = "default"
+	// FactorSourceDistro indicates that the factor is set in the
+	// distro's planner settings.
+	FactorSourceDistro = "distro"
+	// FactorSourceConfig indicates that the factor isn't set for the
+	// distro, so it's the scheduler config's default.
+	FactorSourceConfig = "config"
+	// FactorSourceRequester indicates that the factor is set for a
+	// specific requester.
+	FactorSourceRequester = "requester"
+	// FactorSourceEnv indicates that the factor is overridden by its
+	// environment variable.
+	FactorSourceEnv = "env"
+)
+
+// ResolvedFactor is the value of a planner factor, along with where the
+// value came from.
+type ResolvedFactor struct {
+	Value  int64  `json:"value"`
+	Source string `json:"source"`
+}
+
+// ResolvedFactors are the values of the planner factors that apply to a
+// distro, along with where each value came from.
+type ResolvedFactors struct {
+	PatchFactor               ResolvedFactor `json:"patch_factor"`
+	PatchTimeInQueueFactor    ResolvedFactor `json:"patch_time_in_queue_factor"`
+	CommitQueueFactor         ResolvedFactor `json:"commit_queue_factor"`
+	MainlineTimeInQueueFactor ResolvedFactor `json:"mainline_time_in_queue_factor"`
+	ExpectedRuntimeFactor     ResolvedFactor `json:"expected_runtime_factor"`
+	GenerateTaskFactor        ResolvedFactor `json:"generate_task_factor"`
+	StepbackTaskFactor        ResolvedFactor `json:"stepback_task_factor"`
+	// RequesterPatchFactors are the patch factors for the requesters
+	// that have their own, by requester.
+	RequesterPatchFactors map[string]ResolvedFactor `json:"requester_patch_factors,omitempty"`
+}
+
+// ResolvedFactors returns the value of each planner factor and where it
+// came from, so that it's possible to audit which value the planner
+// used. The factors are determined when the settings are resolved by
+// GetResolvedPlannerSettings, so they're empty for settings that
+// haven't been resolved.
+func (s *PlannerSettings) ResolvedFactors() ResolvedFactors {
+	return s.factors
+}
+
 func (s *PlannerSettings) ShouldGroupVersions() bool {
 	return utility.FromBoolPtr(s.GroupVersions)
 }
 
+// ShouldGroupVersionsForRequester returns whether tasks from the given
+// requester are grouped by version, falling back to GroupVersions if
+// the requester doesn't have its own setting.
+func (s *PlannerSettings) ShouldGroupVersionsForRequester(requester string) bool {
+	if groupVersions, ok := s.RequesterGroupVersions[requester]; ok {
+		return groupVersions
+	}
+	return s.ShouldGroupVersions()
+}
+
 func (s *PlannerSettings) GetPatchFactor() int64 {
 	if s.PatchFactor <= 0 {
 		return 1
@@ -321,6 +501,16 @@ func (s *PlannerSettings) GetPatchFactor() int64 {
 	return s.PatchFactor
 }
 
+// GetPatchFactorForRequester returns the patch factor for units from the
+// given requester, falling back to the patch factor if the requester
+// doesn't have its own.
+func (s *PlannerSettings) GetPatchFactorForRequester(requester string) int64 {
+	if factor := s.RequesterPatchFactors[requester]; factor > 0 {
+		return factor
+	}
+	return s.GetPatchFactor()
+}
+
 func (s *PlannerSettings) GetPatchTimeInQueueFactor() int64 {
 	if s.PatchTimeInQueueFactor <= 0 {
 		return 1
@@ -356,6 +546,17 @@ func (s *PlannerSettings) GetStepbackTaskFactor() int64 {
 	return s.StepbackTaskFactor
 }
 
+// GetStepbackDecayFactor returns how quickly the boost for stepback tasks
+// decays with the number of stepback iterations already performed, or 0
+// if the boost doesn't decay.
+func (s *PlannerSettings) GetStepbackDecayFactor() float64 {
+	if s.StepbackDecayFactor <= 0 {
+		return 0
+	}
+
+	return s.StepbackDecayFactor
+}
+
 func (s *PlannerSettings) GetExpectedRuntimeFactor() int64 {
 	if s.ExpectedRuntimeFactor <= 0 {
 		return 1
@@ -364,6 +565,200 @@ func (s *PlannerSettings) GetExpectedRuntimeFactor() int64 {
 	return s.ExpectedRuntimeFactor
 }
 
+// GetSchedulingObjective returns the objective the planner optimizes
+// the order of units for, which defaults to reducing the makespan.
+func (s *PlannerSettings) GetSchedulingObjective() string {
+	if s.SchedulingObjective == SchedulingObjectiveLatency {
+		return SchedulingObjectiveLatency
+	}
+
+	return SchedulingObjectiveMakespan
+}
+
+// GetTimeInQueueCurve returns the curve applied to the time a unit has
+// spent in the queue before it contributes to the unit's rank value,
+// which defaults to linear.
+func (s *PlannerSettings) GetTimeInQueueCurve() string {
+	if utility.StringSliceContains(ValidTimeInQueueCurves, s.TimeInQueueCurve) {
+		return s.TimeInQueueCurve
+	}
+
+	return TimeInQueueCurveLinear
+}
+
+// GetProjectPriorityFactor returns the factor that scales the scheduling
+// priority of a unit's project in its rank value.
+func (s *PlannerSettings) GetProjectPriorityFactor() int64 {
+	if s.ProjectPriorityFactor <= 0 {
+		return 1
+	}
+
+	return s.ProjectPriorityFactor
+}
+
+// ShouldWeighProjectPriority returns whether the planner should weigh the
+// scheduling priority of a unit's project, which is only the case when a
+// project priority factor is explicitly set.
+func (s *PlannerSettings) ShouldWeighProjectPriority() bool {
+	return s.ProjectPriorityFactor > 0
+}
+
+// GetCommitQueueOverPatchMargin returns the amount added to the priority
+// of commit queue units, which keeps them ahead of patch units with
+// similar priorities.
+func (s *PlannerSettings) GetCommitQueueOverPatchMargin() int64 {
+	if s.CommitQueueOverPatchMargin <= 0 {
+		return DefaultCommitQueueOverPatchMargin
+	}
+
+	return s.CommitQueueOverPatchMargin
+}
+
+// ShouldScaleByIdleHosts returns true when the planner should favor
+// larger units while many of the distro's hosts are idle, and smaller
+// units while few are.
+func (s *PlannerSettings) ShouldScaleByIdleHosts() bool {
+	return utility.FromBoolPtr(s.ScaleByIdleHosts)
+}
+
+// ShouldScaleByThroughput returns true when the planner should adjust
+// the weight of units' expected runtimes by how quickly the distro has
+// recently been finishing tasks, compared to its expected throughput.
+func (s *PlannerSettings) ShouldScaleByThroughput() bool {
+	return utility.FromBoolPtr(s.ScaleByThroughput) && s.GetExpectedThroughput() > 0
+}
+
+// GetExpectedThroughput returns the number of tasks per minute the
+// distro is expected to finish, or 0 if it doesn't have an expected
+// throughput.
+func (s *PlannerSettings) GetExpectedThroughput() float64 {
+	if s.ExpectedThroughput <= 0 {
+		return 0
+	}
+
+	return s.ExpectedThroughput
+}
+
+// ShouldPrioritizeBuildCompletion returns true when the planner should
+// favor units that would finish the remaining tasks of a build.
+func (s *PlannerSettings) ShouldPrioritizeBuildCompletion() bool {
+	return utility.FromBoolPtr(s.PrioritizeBuildCompletion)
+}
+
+// ShouldPreserveTaskOrder returns true when the planner should keep the
+// tasks in units that aren't task groups in the order they were queued,
+// rather than ordering them by their dependencies, priority, and
+// expected duration.
+func (s *PlannerSettings) ShouldPreserveTaskOrder() bool {
+	return utility.FromBoolPtr(s.PreserveTaskOrder)
+}
+
+// GetDefaultTaskDuration returns the expected duration the planner uses
+// for tasks on the distro that don't have any runtime history yet. A
+// duration of 0 means that the planner uses the same default as for
+// every other distro.
+func (s *PlannerSettings) GetDefaultTaskDuration() time.Duration {
+	if s.DefaultTaskDuration <= 0 {
+		return 0
+	}
+
+	return s.DefaultTaskDuration
+}
+
+// GetMaxUnitSize returns the maximum number of tasks in a planner unit.
+// Larger units are split, unless they can't be. A size of 0 means that
+// units aren't limited in size.
+func (s *PlannerSettings) GetMaxUnitSize() int {
+	if s.MaxUnitSize <= 0 {
+		return 0
+	}
+
+	return s.MaxUnitSize
+}
+
+// GetRankValueEpsilon returns the width of the buckets that the planner
+// groups the rank values of units into. Units whose rank values fall in
+// the same bucket are ordered as if their rank values were equal.
+func (s *PlannerSettings) GetRankValueEpsilon() int64 {
+	if s.RankValueEpsilon <= 0 {
+		return 0
+	}
+
+	return s.RankValueEpsilon
+}
+
+// GetMinPriorityMultiplier returns the smallest priority that the
+// planner multiplies a unit's rank terms by, so that units with negative
+// priorities don't have their terms collapse to zero or flip sign.
+func (s *PlannerSettings) GetMinPriorityMultiplier() int64 {
+	if s.MinPriorityMultiplier <= 0 {
+		return DefaultMinPriorityMultiplier
+	}
+
+	return s.MinPriorityMultiplier
+}
+
+// GetQuarantineFactor returns the factor by which the planner reduces
+// the rank of units that only contain quarantined tasks.
+func (s *PlannerSettings) GetQuarantineFactor() int64 {
+	if s.QuarantineFactor <= 0 {
+		return DefaultQuarantineFactor
+	}
+
+	return s.QuarantineFactor
+}
+
+// GetExpectedRuntimePercentile returns the percentile of a task's
+// historical runtimes that the planner uses as its expected runtime. If
+// zero, the planner uses the average runtime.
+func (s *PlannerSettings) GetExpectedRuntimePercentile() int {
+	if s.ExpectedRuntimePercentile <= 0 || s.ExpectedRuntimePercentile >= 100 {
+		return 0
+	}
+
+	return s.ExpectedRuntimePercentile
+}
+
+// GetMaxBoostedGenerators returns the maximum number of units in a plan
+// that get the full boost for containing a generator task. If zero,
+// every such unit gets the full boost.
+func (s *PlannerSettings) GetMaxBoostedGenerators() int {
+	if s.MaxBoostedGenerators <= 0 {
+		return 0
+	}
+
+	return s.MaxBoostedGenerators
+}
+
+// ShouldExcludeDeactivatedTasks returns true when deactivated tasks
+// should not contribute to the ranking of their units, except through
+// the tasks that depend on them.
+func (s *PlannerSettings) ShouldExcludeDeactivatedTasks() bool {
+	return utility.FromBoolPtr(s.ExcludeDeactivatedTasks)
+}
+
+// GetMainlineReservationRatio returns the minimum fraction of the top of
+// the plan that is reserved for mainline units, between 0 and 1. A ratio
+// of 0 disables the reservation.
+func (s *PlannerSettings) GetMainlineReservationRatio() float64 {
+	if s.MainlineReservationRatio <= 0 {
+		return 0
+	}
+	if s.MainlineReservationRatio > 1 {
+		return 1
+	}
+
+	return s.MainlineReservationRatio
+}
+
+// GetStrictPriorityTiers returns true when the planner should treat task
+// priority as a hard tier, so that units with higher priority tasks are
+// always ordered before units with lower priority tasks, regardless of
+// their other factors.
+func (s *PlannerSettings) GetStrictPriorityTiers() bool {
+	return utility.FromBoolPtr(s.StrictPriorityTiers)
+}
+
 // GenerateName generates a unique instance name for a host in a distro.
 func (d *Distro) GenerateName() string {
 	switch d.Provider {
@@ -684,22 +1079,88 @@ func (d *Distro) GetResolvedHostAllocatorSettings(s *evergreen.Settings) (HostAl
 	return resolved, nil
 }
 
+// resolveFactors returns the value of each planner factor for the
+// distro's planner settings and where it came from. An environment
+// variable override takes precedence over the distro's value, which
+// takes precedence over the scheduler config's default. Per-requester
+// patch factors can only be set by the distro, and take precedence over
+// the patch factor for their requesters, even when it's overridden.
+func resolveFactors(ps PlannerSettings, config evergreen.SchedulerConfig) ResolvedFactors {
+	resolve := func(distroValue, configValue int64, envVar string) ResolvedFactor {
+		if value, ok := factorOverride(envVar); ok {
+			return ResolvedFactor{Value: value, Source: FactorSourceEnv}
+		}
+		if distroValue > 0 {
+			return ResolvedFactor{Value: distroValue, Source: FactorSourceDistro}
+		}
+		if configValue > 0 {
+			return ResolvedFactor{Value: configValue, Source: FactorSourceConfig}
+		}
+		return ResolvedFactor{Value: 1, Source: FactorSourceDefault}
+	}
+
+	resolved := ResolvedFactors{
+		PatchFactor:               resolve(ps.PatchFactor, config.PatchFactor, PatchFactorEnvVar),
+		PatchTimeInQueueFactor:    resolve(ps.PatchTimeInQueueFactor, config.PatchTimeInQueueFactor, PatchTimeInQueueFactorEnvVar),
+		CommitQueueFactor:         resolve(ps.CommitQueueFactor, config.CommitQueueFactor, CommitQueueFactorEnvVar),
+		MainlineTimeInQueueFactor: resolve(ps.MainlineTimeInQueueFactor, config.MainlineTimeInQueueFactor, MainlineTimeInQueueFactorEnvVar),
+		ExpectedRuntimeFactor:     resolve(ps.ExpectedRuntimeFactor, config.ExpectedRuntimeFactor, ExpectedRuntimeFactorEnvVar),
+		GenerateTaskFactor:        resolve(ps.GenerateTaskFactor, config.GenerateTaskFactor, GenerateTaskFactorEnvVar),
+		// StepbackTaskFactor isn't configurable by distro
+		StepbackTaskFactor: resolve(0, config.StepbackTaskFactor, StepbackTaskFactorEnvVar),
+	}
+	for requester, factor := range ps.RequesterPatchFactors {
+		if factor <= 0 {
+			continue
+		}
+		if resolved.RequesterPatchFactors == nil {
+			resolved.RequesterPatchFactors = map[string]ResolvedFactor{}
+		}
+		resolved.RequesterPatchFactors[requester] = ResolvedFactor{Value: factor, Source: FactorSourceRequester}
+	}
+
+	return resolved
+}
+
 // GetResolvedPlannerSettings combines the distro's PlannerSettings fields with the
 // SchedulerConfig defaults to resolve and validate a canonical set of PlannerSettings' field values.
+// The planner factor overrides from the environment take precedence over both.
 func (d *Distro) GetResolvedPlannerSettings(s *evergreen.Settings) (PlannerSettings, error) {
 	config := s.Scheduler
 	ps := d.PlannerSettings
 	resolved := PlannerSettings{
-		Version:                   ps.Version,
-		TargetTime:                ps.TargetTime,
-		GroupVersions:             ps.GroupVersions,
-		PatchFactor:               ps.PatchFactor,
-		PatchTimeInQueueFactor:    ps.PatchTimeInQueueFactor,
-		CommitQueueFactor:         ps.CommitQueueFactor,
-		MainlineTimeInQueueFactor: ps.MainlineTimeInQueueFactor,
-		ExpectedRuntimeFactor:     ps.ExpectedRuntimeFactor,
-		GenerateTaskFactor:        ps.GenerateTaskFactor,
-		maxDurationPerHost:        evergreen.MaxDurationPerDistroHost,
+		Version:                    ps.Version,
+		TargetTime:                 ps.TargetTime,
+		GroupVersions:              ps.GroupVersions,
+		PatchFactor:                ps.PatchFactor,
+		PatchTimeInQueueFactor:     ps.PatchTimeInQueueFactor,
+		CommitQueueFactor:          ps.CommitQueueFactor,
+		MainlineTimeInQueueFactor:  ps.MainlineTimeInQueueFactor,
+		ExpectedRuntimeFactor:      ps.ExpectedRuntimeFactor,
+		GenerateTaskFactor:         ps.GenerateTaskFactor,
+		StrictPriorityTiers:        ps.StrictPriorityTiers,
+		CommitQueueOverPatchMargin: ps.CommitQueueOverPatchMargin,
+		ExcludeDeactivatedTasks:    ps.ExcludeDeactivatedTasks,
+		MainlineReservationRatio:   ps.MainlineReservationRatio,
+		ProjectPriorityFactor:      ps.ProjectPriorityFactor,
+		SchedulingObjective:        ps.SchedulingObjective,
+		ScaleByIdleHosts:           ps.ScaleByIdleHosts,
+		MaxUnitSize:                ps.MaxUnitSize,
+		RankValueEpsilon:           ps.RankValueEpsilon,
+		QuarantineFactor:           ps.QuarantineFactor,
+		ExpectedRuntimePercentile:  ps.ExpectedRuntimePercentile,
+		MaxBoostedGenerators:       ps.MaxBoostedGenerators,
+		TimeInQueueCurve:           ps.TimeInQueueCurve,
+		PrioritizeBuildCompletion:  ps.PrioritizeBuildCompletion,
+		PreserveTaskOrder:          ps.PreserveTaskOrder,
+		DefaultTaskDuration:        ps.DefaultTaskDuration,
+		MinPriorityMultiplier:      ps.MinPriorityMultiplier,
+		ScaleByThroughput:          ps.ScaleByThroughput,
+		ExpectedThroughput:         ps.ExpectedThroughput,
+		StepbackDecayFactor:        ps.StepbackDecayFactor,
+		RequesterPatchFactors:      ps.RequesterPatchFactors,
+		RequesterGroupVersions:     ps.RequesterGroupVersions,
+		maxDurationPerHost:         evergreen.MaxDurationPerDistroHost,
 	}
 
 	catcher := grip.NewBasicCatcher()
@@ -718,33 +1179,36 @@ func (d *Distro) GetResolvedPlannerSettings(s *evergreen.Settings) (PlannerSetti
 	if !utility.StringSliceContains(evergreen.ValidTaskPlannerVersions, resolved.Version) {
 		catcher.Errorf("'%s' is not a valid planner version", resolved.Version)
 	}
+	if resolved.SchedulingObjective != "" && !utility.StringSliceContains(ValidSchedulingObjectives, resolved.SchedulingObjective) {
+		catcher.Errorf("'%s' is not a valid scheduling objective", resolved.SchedulingObjective)
+	}
+	if resolved.TimeInQueueCurve != "" && !utility.StringSliceContains(ValidTimeInQueueCurves, resolved.TimeInQueueCurve) {
+		catcher.Errorf("'%s' is not a valid time in queue curve", resolved.TimeInQueueCurve)
+	}
 	if resolved.TargetTime == 0 {
 		resolved.TargetTime = time.Duration(config.TargetTimeSeconds) * time.Second
 	}
 	if resolved.GroupVersions == nil {
 		resolved.GroupVersions = &config.GroupVersions
 	}
-	if resolved.PatchFactor == 0 {
-		resolved.PatchFactor = config.PatchFactor
-	}
-	if resolved.PatchTimeInQueueFactor == 0 {
-		resolved.PatchTimeInQueueFactor = config.PatchTimeInQueueFactor
-	}
-	if resolved.CommitQueueFactor == 0 {
-		resolved.CommitQueueFactor = config.CommitQueueFactor
-	}
-	if resolved.MainlineTimeInQueueFactor == 0 {
-		resolved.MainlineTimeInQueueFactor = config.MainlineTimeInQueueFactor
-	}
-	if resolved.ExpectedRuntimeFactor == 0 {
-		resolved.ExpectedRuntimeFactor = config.ExpectedRuntimeFactor
-	}
-	if resolved.GenerateTaskFactor == 0 {
-		resolved.GenerateTaskFactor = config.GenerateTaskFactor
-	}
 
-	// StepbackTaskFactor isn't configurable by distro
-	resolved.StepbackTaskFactor = config.StepbackTaskFactor
+	// The factors are resolved from the distro's own settings, so that a
+	// distro's value can be told apart from the scheduler config's, and
+	// the environment is only read once.
+	resolved.factors = resolveFactors(ps, config)
+	for factor, resolvedFactor := range map[*int64]ResolvedFactor{
+		&resolved.PatchFactor:               resolved.factors.PatchFactor,
+		&resolved.PatchTimeInQueueFactor:    resolved.factors.PatchTimeInQueueFactor,
+		&resolved.CommitQueueFactor:         resolved.factors.CommitQueueFactor,
+		&resolved.MainlineTimeInQueueFactor: resolved.factors.MainlineTimeInQueueFactor,
+		&resolved.ExpectedRuntimeFactor:     resolved.factors.ExpectedRuntimeFactor,
+		&resolved.GenerateTaskFactor:        resolved.factors.GenerateTaskFactor,
+		&resolved.StepbackTaskFactor:        resolved.factors.StepbackTaskFactor,
+	} {
+		if resolvedFactor.Source != FactorSourceDefault {
+			*factor = resolvedFactor.Value
+		}
+	}
 
 	if catcher.HasErrors() {
 		return PlannerSettings{}, errors.Wrapf(catcher.Resolve(), "resolving planner settings for distro '%s'", d.Id)
@@ -562,6 +562,95 @@ func TestGetResolvedPlannerSettings(t *testing.T) {
 	assert.EqualValues(t, 0, resolved2.GenerateTaskFactor)
 }
 
+func TestPlannerSettingsFactorOverrides(t *testing.T) {
+	t.Run("OverridesConfiguredValue", func(t *testing.T) {
+		t.Setenv(PatchFactorEnvVar, "42")
+		ps := PlannerSettings{PatchFactor: 10, CommitQueueFactor: 5}
+		ps.ApplyFactorOverrides()
+		assert.EqualValues(t, 42, ps.GetPatchFactor())
+		assert.EqualValues(t, 5, ps.GetCommitQueueFactor())
+	})
+	t.Run("IgnoresInvalidValues", func(t *testing.T) {
+		t.Setenv(PatchFactorEnvVar, "not-a-number")
+		t.Setenv(CommitQueueFactorEnvVar, "-3")
+		ps := PlannerSettings{PatchFactor: 10, CommitQueueFactor: 5}
+		ps.ApplyFactorOverrides()
+		assert.EqualValues(t, 10, ps.GetPatchFactor())
+		assert.EqualValues(t, 5, ps.GetCommitQueueFactor())
+	})
+	t.Run("NoOverrides", func(t *testing.T) {
+		ps := PlannerSettings{}
+		ps.ApplyFactorOverrides()
+		assert.Equal(t, PlannerSettings{}, ps)
+	})
+	t.Run("AppliedWhenResolved", func(t *testing.T) {
+		t.Setenv(PatchFactorEnvVar, "42")
+		d := Distro{
+			Id:              "distro",
+			PlannerSettings: PlannerSettings{PatchFactor: 10},
+		}
+		settings := &evergreen.Settings{Scheduler: evergreen.SchedulerConfig{
+			Planner:            evergreen.PlannerVersionTunable,
+			FutureHostFraction: .1,
+			CommitQueueFactor:  5,
+		}}
+		resolved, err := d.GetResolvedPlannerSettings(settings)
+		require.NoError(t, err)
+		assert.EqualValues(t, 42, resolved.PatchFactor)
+		assert.EqualValues(t, 5, resolved.CommitQueueFactor)
+		assert.EqualValues(t, 42, d.PlannerSettings.PatchFactor)
+	})
+}
+
+func TestPlannerSettingsResolvedFactors(t *testing.T) {
+	t.Setenv(PatchFactorEnvVar, "42")
+	t.Setenv(CommitQueueFactorEnvVar, "not-a-number")
+	d := Distro{
+		Id: "distro",
+		PlannerSettings: PlannerSettings{
+			PatchFactor:           10,
+			CommitQueueFactor:     5,
+			ExpectedRuntimeFactor: 3,
+			RequesterPatchFactors: map[string]int64{
+				evergreen.GithubPRRequester:     20,
+				evergreen.PatchVersionRequester: 0,
+			},
+		},
+	}
+	settings := &evergreen.Settings{Scheduler: evergreen.SchedulerConfig{
+		Planner:                evergreen.PlannerVersionTunable,
+		FutureHostFraction:     .1,
+		CommitQueueFactor:      7,
+		PatchTimeInQueueFactor: 8,
+		StepbackTaskFactor:     9,
+	}}
+
+	assert.Empty(t, d.PlannerSettings.ResolvedFactors(), "unresolved settings shouldn't have resolved factors")
+
+	resolved, err := d.GetResolvedPlannerSettings(settings)
+	require.NoError(t, err)
+	factors := resolved.ResolvedFactors()
+	assert.Equal(t, ResolvedFactor{Value: 42, Source: FactorSourceEnv}, factors.PatchFactor)
+	assert.Equal(t, ResolvedFactor{Value: 5, Source: FactorSourceDistro}, factors.CommitQueueFactor)
+	assert.Equal(t, ResolvedFactor{Value: 3, Source: FactorSourceDistro}, factors.ExpectedRuntimeFactor)
+	assert.Equal(t, ResolvedFactor{Value: 8, Source: FactorSourceConfig}, factors.PatchTimeInQueueFactor)
+	assert.Equal(t, ResolvedFactor{Value: 9, Source: FactorSourceConfig}, factors.StepbackTaskFactor)
+	assert.Equal(t, ResolvedFactor{Value: 1, Source: FactorSourceDefault}, factors.GenerateTaskFactor)
+	assert.Equal(t, map[string]ResolvedFactor{
+		evergreen.GithubPRRequester: {Value: 20, Source: FactorSourceRequester},
+	}, factors.RequesterPatchFactors)
+
+	assert.EqualValues(t, factors.PatchFactor.Value, resolved.GetPatchFactor())
+	assert.EqualValues(t, factors.CommitQueueFactor.Value, resolved.GetCommitQueueFactor())
+	assert.EqualValues(t, factors.PatchTimeInQueueFactor.Value, resolved.GetPatchTimeInQueueFactor())
+	assert.EqualValues(t, factors.StepbackTaskFactor.Value, resolved.StepbackTaskFactor)
+	assert.EqualValues(t, factors.GenerateTaskFactor.Value, resolved.GetGenerateTaskFactor())
+	assert.EqualValues(t, factors.RequesterPatchFactors[evergreen.GithubPRRequester].Value, resolved.GetPatchFactorForRequester(evergreen.GithubPRRequester))
+
+	t.Setenv(PatchFactorEnvVar, "")
+	assert.Equal(t, factors, resolved.ResolvedFactors(), "the environment should only be read when the settings are resolved")
+}
+
 func TestAddPermissions(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
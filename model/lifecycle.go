@@ -425,6 +425,11 @@ func restartTasks(ctx context.Context, allFinishedTasks []task.Task, caller, ver
 			return errors.Wrapf(err, "updating build '%s' PR status", b.Id)
 		}
 	}
+	if caller != "" && !evergreen.IsSystemActivator(caller) {
+		if err = setVersionRestartedBy(versionId, caller); err != nil {
+			return errors.Wrap(err, "recording who restarted the version")
+		}
+	}
 	return errors.Wrap(setVersionStatus(versionId, evergreen.VersionStarted), "changing version status")
 }
 
@@ -52,6 +52,11 @@ var (
 	TriggersKey             = bsonutil.MustHaveTag(Patch{}, "Triggers")
 	HiddenKey               = bsonutil.MustHaveTag(Patch{}, "Hidden")
 
+	CommitQueueDequeueReasonKey = bsonutil.MustHaveTag(Patch{}, "CommitQueueDequeueReason")
+	LastGithubStatusesKey       = bsonutil.MustHaveTag(Patch{}, "LastGithubStatuses")
+	GithubSummaryCommentSentKey = bsonutil.MustHaveTag(Patch{}, "GithubSummaryCommentSent")
+	AwaitingManualApprovalKey   = bsonutil.MustHaveTag(Patch{}, "AwaitingManualApproval")
+
 	// BSON fields for sync at end struct
 	SyncAtEndOptionsBuildVariantsKey = bsonutil.MustHaveTag(SyncAtEndOptions{}, "BuildVariants")
 	SyncAtEndOptionsTasksKey         = bsonutil.MustHaveTag(SyncAtEndOptions{}, "Tasks")
@@ -185,11 +185,32 @@ type Patch struct {
 	// MergedFrom is populated with the patch id of the existing patch
 	// the merged patch is based off of, if applicable.
 	MergedFrom string `bson:"merged_from,omitempty"`
+	// CommitQueueDequeueReason is the reason a commit queue patch was
+	// removed from the commit queue, if it was dequeued.
+	CommitQueueDequeueReason string `bson:"commit_queue_dequeue_reason,omitempty"`
+	// LastGithubStatuses are the GitHub statuses most recently sent for
+	// the patch, with one for each context.
+	LastGithubStatuses []GithubStatusRecord `bson:"last_github_statuses,omitempty"`
+	// GithubSummaryCommentSent indicates that the comment summarizing the
+	// finished patch has already been posted to its PR.
+	GithubSummaryCommentSent bool `bson:"github_summary_comment_sent,omitempty"`
+	// AwaitingManualApproval indicates that some of the patch's tasks
+	// won't be scheduled until a user approves them.
+	AwaitingManualApproval bool `bson:"awaiting_manual_approval,omitempty"`
 }
 
 func (p *Patch) MarshalBSON() ([]byte, error)  { return mgobson.Marshal(p) }
 func (p *Patch) UnmarshalBSON(in []byte) error { return mgobson.Unmarshal(in, p) }
 
+// GithubStatusRecord records a GitHub status that was sent for a patch.
+type GithubStatusRecord struct {
+	Context     string `bson:"context"`
+	State       string `bson:"state"`
+	Description string `bson:"description"`
+	// SentAt is when the status was sent.
+	SentAt time.Time `bson:"sent_at,omitempty"`
+}
+
 // ModulePatch stores request details for a patch
 type ModulePatch struct {
 	ModuleName string   `bson:"name"`
@@ -250,6 +271,34 @@ func (p *Patch) SetDescription(desc string) error {
 	)
 }
 
+// SetCommitQueueDequeueReason records the reason a commit queue patch was
+// removed from the commit queue.
+func (p *Patch) SetCommitQueueDequeueReason(reason string) error {
+	p.CommitQueueDequeueReason = reason
+	return UpdateOne(
+		bson.M{IdKey: p.Id},
+		bson.M{
+			"$set": bson.M{
+				CommitQueueDequeueReasonKey: reason,
+			},
+		},
+	)
+}
+
+// SetAwaitingManualApproval records whether the patch is waiting for a
+// user to approve its tasks.
+func (p *Patch) SetAwaitingManualApproval(awaiting bool) error {
+	p.AwaitingManualApproval = awaiting
+	return UpdateOne(
+		bson.M{IdKey: p.Id},
+		bson.M{
+			"$set": bson.M{
+				AwaitingManualApprovalKey: awaiting,
+			},
+		},
+	)
+}
+
 func (p *Patch) SetMergePatch(newPatchID string) error {
 	p.MergePatch = newPatchID
 	return UpdateOne(
@@ -284,6 +333,19 @@ func (p *Patch) GetURL(uiHost string) string {
 	return url
 }
 
+// GetConfigureURL returns the URL of the page for configuring the patch's
+// tasks, which is where a user approves a patch that's awaiting manual
+// approval. Unlike GetURL, it's the same whether or not the patch has
+// been activated.
+func (p *Patch) GetConfigureURL(uiHost string) string {
+	url := uiHost + "/patch/" + p.Id.Hex()
+	if p.DisplayNewUI {
+		url = url + "?redirect_spruce_users=true"
+	}
+
+	return url
+}
+
 // ClearPatchData removes any inline patch data stored in this patch object for patches that have
 // an associated id in gridfs, so that it can be stored properly.
 func (p *Patch) ClearPatchData() {
@@ -643,8 +705,9 @@ func (p *Patch) SetFinalized(ctx context.Context, versionId string) error {
 				VersionKey:   versionId,
 			},
 			"$unset": bson.M{
-				ProjectStorageMethodKey: 1,
-				PatchedProjectConfigKey: 1,
+				ProjectStorageMethodKey:   1,
+				PatchedProjectConfigKey:   1,
+				AwaitingManualApprovalKey: 1,
 			},
 		},
 	); err != nil {
@@ -655,6 +718,7 @@ func (p *Patch) SetFinalized(ctx context.Context, versionId string) error {
 	p.Activated = true
 	p.ProjectStorageMethod = ""
 	p.PatchedProjectConfig = ""
+	p.AwaitingManualApproval = false
 
 	return nil
 }
@@ -681,6 +745,34 @@ func (p *Patch) SetChildPatches() error {
 	)
 }
 
+// SetLastGithubStatuses records the GitHub statuses most recently sent
+// for the patch.
+func (p *Patch) SetLastGithubStatuses(statuses []GithubStatusRecord) error {
+	p.LastGithubStatuses = statuses
+	return UpdateOne(
+		bson.M{IdKey: p.Id},
+		bson.M{
+			"$set": bson.M{
+				LastGithubStatusesKey: statuses,
+			},
+		},
+	)
+}
+
+// SetGithubSummaryCommentSent records that the comment summarizing the
+// finished patch was posted to its PR.
+func (p *Patch) SetGithubSummaryCommentSent() error {
+	p.GithubSummaryCommentSent = true
+	return UpdateOne(
+		bson.M{IdKey: p.Id},
+		bson.M{
+			"$set": bson.M{
+				GithubSummaryCommentSentKey: true,
+			},
+		},
+	)
+}
+
 // SetActivation sets the patch to the desired activation state without
 // modifying the activation status of the possibly corresponding version.
 func (p *Patch) SetActivation(activated bool) error {
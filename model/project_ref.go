@@ -78,6 +78,77 @@ type ProjectRef struct {
 	PeriodicBuilds       []PeriodicBuildDefinition `bson:"periodic_builds" json:"periodic_builds"`
 	CommitQueue          CommitQueueParams         `bson:"commit_queue" json:"commit_queue" yaml:"commit_queue"`
 
+	// GithubRequiredVariants are the build variants whose results
+	// determine the state of the overall GitHub status for a PR patch. If
+	// empty, every variant is required.
+	GithubRequiredVariants []string `bson:"github_required_variants,omitempty" json:"github_required_variants,omitempty" yaml:"github_required_variants"`
+	// GithubEarlyFailureStatus, if true, reports the GitHub status of a
+	// running build as failed as soon as any of its tasks fail.
+	GithubEarlyFailureStatus *bool `bson:"github_early_failure_status,omitempty" json:"github_early_failure_status,omitempty" yaml:"github_early_failure_status"`
+	// GithubFailedTaskLogLink, if true, links the GitHub status of a
+	// failed build to the task log when exactly one of its tasks failed.
+	GithubFailedTaskLogLink *bool `bson:"github_failed_task_log_link,omitempty" json:"github_failed_task_log_link,omitempty" yaml:"github_failed_task_log_link"`
+	// GithubReportSkippedVariants, if true, sends an informational GitHub
+	// status for each required variant that a patch didn't run.
+	GithubReportSkippedVariants *bool `bson:"github_report_skipped_variants,omitempty" json:"github_report_skipped_variants,omitempty" yaml:"github_report_skipped_variants"`
+	// GithubDebounceStatuses, if true, only sends a GitHub status for a
+	// patch when it differs from the last status sent for its context.
+	GithubDebounceStatuses *bool `bson:"github_debounce_statuses,omitempty" json:"github_debounce_statuses,omitempty" yaml:"github_debounce_statuses"`
+	// GithubSummaryComment, if true, posts a comment summarizing the
+	// results of each variant to the PR once a patch finishes.
+	GithubSummaryComment *bool `bson:"github_summary_comment,omitempty" json:"github_summary_comment,omitempty" yaml:"github_summary_comment"`
+	// GithubMaxBuildStatuses is the maximum number of builds in a patch
+	// that are each sent their own GitHub status. Patches with more
+	// builds than this only send statuses for failed builds. If zero,
+	// there is no limit.
+	GithubMaxBuildStatuses int `bson:"github_max_build_statuses,omitempty" json:"github_max_build_statuses,omitempty" yaml:"github_max_build_statuses"`
+	// GithubRequiredChecksSummary, if true, describes a pending patch's
+	// overall GitHub status by how many of its required variants are
+	// complete.
+	GithubRequiredChecksSummary *bool `bson:"github_required_checks_summary,omitempty" json:"github_required_checks_summary,omitempty" yaml:"github_required_checks_summary"`
+	// GithubModuleStatuses, if true, sends a GitHub status for each
+	// module changed by a patch, based on the builds of the variants that
+	// use the module.
+	GithubModuleStatuses *bool `bson:"github_module_statuses,omitempty" json:"github_module_statuses,omitempty" yaml:"github_module_statuses"`
+	// GithubReportRetriedTasks, if true, notes how many tasks only
+	// succeeded after being retried in the descriptions of finished
+	// builds and versions.
+	GithubReportRetriedTasks *bool `bson:"github_report_retried_tasks,omitempty" json:"github_report_retried_tasks,omitempty" yaml:"github_report_retried_tasks"`
+	// GithubCollapseSuccessStatuses, if true, collapses the statuses of
+	// a patch's successful variants into the overall status once the
+	// patch succeeds, so the overall status is the one that stands out.
+	GithubCollapseSuccessStatuses *bool `bson:"github_collapse_success_statuses,omitempty" json:"github_collapse_success_statuses,omitempty" yaml:"github_collapse_success_statuses"`
+	// GithubStatusesDisabled, if true, prevents Evergreen from sending
+	// GitHub statuses for the project's patches.
+	GithubStatusesDisabled *bool `bson:"github_statuses_disabled,omitempty" json:"github_statuses_disabled,omitempty" yaml:"github_statuses_disabled"`
+	// GithubCompletionEstimate, if true, adds an estimate of the time
+	// remaining until a running patch finishes to its overall GitHub
+	// status.
+	GithubCompletionEstimate *bool `bson:"github_completion_estimate,omitempty" json:"github_completion_estimate,omitempty" yaml:"github_completion_estimate"`
+	// GithubSuccessDescription, if set, is the description of a patch's
+	// overall GitHub status once the patch succeeds. Any occurrence of
+	// {duration} is replaced with how long the patch took to finish.
+	GithubSuccessDescription string `bson:"github_success_description,omitempty" json:"github_success_description,omitempty" yaml:"github_success_description"`
+	// GithubStatusMinIntervalSeconds is the minimum number of seconds
+	// between sending GitHub statuses with the same state for the same
+	// context of a patch. Statuses whose state changed are always sent.
+	// If zero, there is no minimum.
+	GithubStatusMinIntervalSeconds int `bson:"github_status_min_interval_seconds,omitempty" json:"github_status_min_interval_seconds,omitempty" yaml:"github_status_min_interval_seconds"`
+	// GithubCoalesceChildPatches, if true, sends a single GitHub status
+	// for all of a patch's child patches for the same project, rather
+	// than one status for each child patch.
+	GithubCoalesceChildPatches *bool `bson:"github_coalesce_child_patches,omitempty" json:"github_coalesce_child_patches,omitempty" yaml:"github_coalesce_child_patches"`
+	// GithubVariantTimeBudgetSeconds are the number of seconds that a
+	// build of each build variant is expected to finish within, by
+	// build variant. A running build that takes longer than its budget
+	// has its GitHub status changed to an error.
+	GithubVariantTimeBudgetSeconds map[string]int `bson:"github_variant_time_budget_seconds,omitempty" json:"github_variant_time_budget_seconds,omitempty" yaml:"github_variant_time_budget_seconds"`
+	// GithubReportBaseCommit, if true, includes the short SHA of the
+	// base commit that a patch was tested against in the description of
+	// its overall GitHub status, so that it's clear when a PR is stale
+	// relative to its base branch.
+	GithubReportBaseCommit *bool `bson:"github_report_base_commit,omitempty" json:"github_report_base_commit,omitempty" yaml:"github_report_base_commit"`
+
 	// Admins contain a list of users who are able to access the projects page.
 	Admins []string `bson:"admins" json:"admins"`
 
@@ -88,6 +159,10 @@ type ProjectRef struct {
 	// If a repo is enabled and this is what creates the hook, then TracksPushEvents will be set at the repo level.
 	TracksPushEvents *bool `bson:"tracks_push_events" json:"tracks_push_events" yaml:"tracks_push_events"`
 
+	// SchedulingPriority is the priority of the project's tasks relative
+	// to other projects' tasks in shared distro queues.
+	SchedulingPriority int64 `bson:"scheduling_priority,omitempty" json:"scheduling_priority,omitempty" yaml:"scheduling_priority"`
+
 	// TaskSync holds settings for synchronizing task directories to S3.
 	TaskSync TaskSyncOptions `bson:"task_sync" json:"task_sync" yaml:"task_sync"`
 
@@ -342,6 +417,25 @@ var (
 	projectRefTriggersKey                 = bsonutil.MustHaveTag(ProjectRef{}, "Triggers")
 	projectRefPatchTriggerAliasesKey      = bsonutil.MustHaveTag(ProjectRef{}, "PatchTriggerAliases")
 	projectRefGithubTriggerAliasesKey     = bsonutil.MustHaveTag(ProjectRef{}, "GithubTriggerAliases")
+	projectRefGithubRequiredVariantsKey   = bsonutil.MustHaveTag(ProjectRef{}, "GithubRequiredVariants")
+	projectRefGithubEarlyFailureKey       = bsonutil.MustHaveTag(ProjectRef{}, "GithubEarlyFailureStatus")
+	projectRefGithubLogLinkKey            = bsonutil.MustHaveTag(ProjectRef{}, "GithubFailedTaskLogLink")
+	projectRefGithubSkippedVariantsKey    = bsonutil.MustHaveTag(ProjectRef{}, "GithubReportSkippedVariants")
+	projectRefGithubDebounceKey           = bsonutil.MustHaveTag(ProjectRef{}, "GithubDebounceStatuses")
+	projectRefGithubSummaryCommentKey     = bsonutil.MustHaveTag(ProjectRef{}, "GithubSummaryComment")
+	projectRefGithubMaxBuildStatusesKey   = bsonutil.MustHaveTag(ProjectRef{}, "GithubMaxBuildStatuses")
+	projectRefGithubChecksSummaryKey      = bsonutil.MustHaveTag(ProjectRef{}, "GithubRequiredChecksSummary")
+	projectRefGithubModuleStatusesKey     = bsonutil.MustHaveTag(ProjectRef{}, "GithubModuleStatuses")
+	projectRefGithubRetriedTasksKey       = bsonutil.MustHaveTag(ProjectRef{}, "GithubReportRetriedTasks")
+	projectRefGithubCollapseSuccessKey    = bsonutil.MustHaveTag(ProjectRef{}, "GithubCollapseSuccessStatuses")
+	projectRefGithubStatusesDisabledKey   = bsonutil.MustHaveTag(ProjectRef{}, "GithubStatusesDisabled")
+	projectRefGithubCompletionETAKey      = bsonutil.MustHaveTag(ProjectRef{}, "GithubCompletionEstimate")
+	projectRefGithubSuccessDescKey        = bsonutil.MustHaveTag(ProjectRef{}, "GithubSuccessDescription")
+	projectRefGithubMinIntervalKey        = bsonutil.MustHaveTag(ProjectRef{}, "GithubStatusMinIntervalSeconds")
+	projectRefGithubCoalesceChildKey      = bsonutil.MustHaveTag(ProjectRef{}, "GithubCoalesceChildPatches")
+	projectRefGithubTimeBudgetsKey        = bsonutil.MustHaveTag(ProjectRef{}, "GithubVariantTimeBudgetSeconds")
+	projectRefGithubBaseCommitKey         = bsonutil.MustHaveTag(ProjectRef{}, "GithubReportBaseCommit")
+	projectRefSchedulingPriorityKey       = bsonutil.MustHaveTag(ProjectRef{}, "SchedulingPriority")
 	projectRefPeriodicBuildsKey           = bsonutil.MustHaveTag(ProjectRef{}, "PeriodicBuilds")
 	projectRefWorkstationConfigKey        = bsonutil.MustHaveTag(ProjectRef{}, "WorkstationConfig")
 	projectRefTaskAnnotationSettingsKey   = bsonutil.MustHaveTag(ProjectRef{}, "TaskAnnotationSettings")
@@ -416,6 +510,79 @@ func (p *ProjectRef) IsGithubChecksEnabled() bool {
 	return utility.FromBoolPtr(p.GithubChecksEnabled)
 }
 
+func (p *ProjectRef) IsGithubEarlyFailureStatusEnabled() bool {
+	return utility.FromBoolPtr(p.GithubEarlyFailureStatus)
+}
+
+func (p *ProjectRef) IsGithubFailedTaskLogLinkEnabled() bool {
+	return utility.FromBoolPtr(p.GithubFailedTaskLogLink)
+}
+
+func (p *ProjectRef) IsGithubReportSkippedVariantsEnabled() bool {
+	return utility.FromBoolPtr(p.GithubReportSkippedVariants)
+}
+
+func (p *ProjectRef) IsGithubDebounceStatusesEnabled() bool {
+	return utility.FromBoolPtr(p.GithubDebounceStatuses)
+}
+
+func (p *ProjectRef) IsGithubSummaryCommentEnabled() bool {
+	return utility.FromBoolPtr(p.GithubSummaryComment)
+}
+
+func (p *ProjectRef) IsGithubRequiredChecksSummaryEnabled() bool {
+	return utility.FromBoolPtr(p.GithubRequiredChecksSummary)
+}
+
+func (p *ProjectRef) IsGithubModuleStatusesEnabled() bool {
+	return utility.FromBoolPtr(p.GithubModuleStatuses)
+}
+
+func (p *ProjectRef) IsGithubReportRetriedTasksEnabled() bool {
+	return utility.FromBoolPtr(p.GithubReportRetriedTasks)
+}
+
+func (p *ProjectRef) IsGithubCollapseSuccessStatusesEnabled() bool {
+	return utility.FromBoolPtr(p.GithubCollapseSuccessStatuses)
+}
+
+func (p *ProjectRef) IsGithubStatusesDisabled() bool {
+	return utility.FromBoolPtr(p.GithubStatusesDisabled)
+}
+
+func (p *ProjectRef) IsGithubCompletionEstimateEnabled() bool {
+	return utility.FromBoolPtr(p.GithubCompletionEstimate)
+}
+
+// GetGithubStatusMinInterval returns the minimum time between sending
+// GitHub statuses with the same state for the same context of a patch.
+func (p *ProjectRef) GetGithubStatusMinInterval() time.Duration {
+	if p.GithubStatusMinIntervalSeconds <= 0 {
+		return 0
+	}
+
+	return time.Duration(p.GithubStatusMinIntervalSeconds) * time.Second
+}
+
+func (p *ProjectRef) IsGithubReportBaseCommitEnabled() bool {
+	return utility.FromBoolPtr(p.GithubReportBaseCommit)
+}
+
+func (p *ProjectRef) IsGithubCoalesceChildPatchesEnabled() bool {
+	return utility.FromBoolPtr(p.GithubCoalesceChildPatches)
+}
+
+// GetGithubVariantTimeBudget returns how long a build of the build
+// variant is expected to take, or 0 if the variant doesn't have a time
+// budget.
+func (p *ProjectRef) GetGithubVariantTimeBudget(variant string) time.Duration {
+	if seconds := p.GithubVariantTimeBudgetSeconds[variant]; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	return 0
+}
+
 func (p *ProjectRef) ShouldDeactivatePrevious() bool {
 	return utility.FromBoolPtr(p.DeactivatePrevious)
 }
@@ -479,6 +646,9 @@ const (
 	ProjectTriggerLevelPush  = "push"
 	intervalPrefix           = "@every"
 	maxBatchTime             = 153722867 // math.MaxInt64 / 60 / 1_000_000_000
+	// maxGithubSuccessDescriptionLength is GitHub's limit on the length
+	// of a status description.
+	maxGithubSuccessDescriptionLength = 140
 )
 
 type ProjectPageSection string
@@ -2013,6 +2183,7 @@ func SaveProjectPageForSection(projectId string, p *ProjectRef, section ProjectP
 			projectRefPatchingDisabledKey:      p.PatchingDisabled,
 			projectRefTaskSyncKey:              p.TaskSync,
 			ProjectRefDisabledStatsCacheKey:    p.DisabledStatsCache,
+			projectRefSchedulingPriorityKey:    p.SchedulingPriority,
 		}
 		// Unlike other fields, this will only be set if we're actually modifying it since it's used by the backend.
 		if p.TracksPushEvents != nil {
@@ -2098,6 +2269,24 @@ func SaveProjectPageForSection(projectId string, p *ProjectRef, section ProjectP
 					ProjectRefGitTagAuthorizedUsersKey:  p.GitTagAuthorizedUsers,
 					ProjectRefGitTagAuthorizedTeamsKey:  p.GitTagAuthorizedTeams,
 					projectRefCommitQueueKey:            p.CommitQueue,
+					projectRefGithubRequiredVariantsKey: p.GithubRequiredVariants,
+					projectRefGithubEarlyFailureKey:     p.GithubEarlyFailureStatus,
+					projectRefGithubLogLinkKey:          p.GithubFailedTaskLogLink,
+					projectRefGithubSkippedVariantsKey:  p.GithubReportSkippedVariants,
+					projectRefGithubDebounceKey:         p.GithubDebounceStatuses,
+					projectRefGithubSummaryCommentKey:   p.GithubSummaryComment,
+					projectRefGithubMaxBuildStatusesKey: p.GithubMaxBuildStatuses,
+					projectRefGithubChecksSummaryKey:    p.GithubRequiredChecksSummary,
+					projectRefGithubModuleStatusesKey:   p.GithubModuleStatuses,
+					projectRefGithubRetriedTasksKey:     p.GithubReportRetriedTasks,
+					projectRefGithubCollapseSuccessKey:  p.GithubCollapseSuccessStatuses,
+					projectRefGithubStatusesDisabledKey: p.GithubStatusesDisabled,
+					projectRefGithubCompletionETAKey:    p.GithubCompletionEstimate,
+					projectRefGithubSuccessDescKey:      p.GithubSuccessDescription,
+					projectRefGithubMinIntervalKey:      p.GithubStatusMinIntervalSeconds,
+					projectRefGithubCoalesceChildKey:    p.GithubCoalesceChildPatches,
+					projectRefGithubTimeBudgetsKey:      p.GithubVariantTimeBudgetSeconds,
+					projectRefGithubBaseCommitKey:       p.GithubReportBaseCommit,
 				},
 			})
 	case ProjectPageNotificationsSection:
@@ -2502,6 +2691,16 @@ func validateOwner(owner string, validOrgs []string) error {
 	return nil
 }
 
+// ValidateGithubSuccessDescription checks that the custom success
+// description for the project's GitHub statuses fits within GitHub's
+// limit on status descriptions.
+func (p *ProjectRef) ValidateGithubSuccessDescription() error {
+	if length := len([]rune(p.GithubSuccessDescription)); length > maxGithubSuccessDescriptionLength {
+		return errors.Errorf("GitHub success description is %d characters long, which exceeds the maximum of %d", length, maxGithubSuccessDescriptionLength)
+	}
+	return nil
+}
+
 func (p *ProjectRef) ValidateIdentifier() error {
 	if p.Id == p.Identifier { // we already know the id is unique
 		return nil
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -3249,6 +3250,17 @@ func TestValidateOwnerAndRepo(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestValidateGithubSuccessDescription(t *testing.T) {
+	project := ProjectRef{GithubSuccessDescription: "All checks green — ready to merge"}
+	assert.NoError(t, project.ValidateGithubSuccessDescription())
+
+	project.GithubSuccessDescription = strings.Repeat("—", maxGithubSuccessDescriptionLength)
+	assert.NoError(t, project.ValidateGithubSuccessDescription())
+
+	project.GithubSuccessDescription += "!"
+	assert.Error(t, project.ValidateGithubSuccessDescription())
+}
+
 func TestProjectCanDispatchTask(t *testing.T) {
 	t.Run("ReturnsTrueWithEnabledProject", func(t *testing.T) {
 		pRef := ProjectRef{
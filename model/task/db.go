@@ -121,6 +121,7 @@ var (
 	LastFailingStepbackTaskIdKey = bsonutil.MustHaveTag(StepbackInfo{}, "LastFailingStepbackTaskId")
 	LastPassingStepbackTaskIdKey = bsonutil.MustHaveTag(StepbackInfo{}, "LastPassingStepbackTaskId")
 	NextStepbackTaskIdKey        = bsonutil.MustHaveTag(StepbackInfo{}, "NextStepbackTaskId")
+	StepbackIterationsKey        = bsonutil.MustHaveTag(StepbackInfo{}, "StepbackIterations")
 )
 
 var (
@@ -1614,6 +1615,16 @@ func CountActivatedTasksForVersion(versionId string) (int, error) {
 	}))
 }
 
+// CountFinishedTasksForDistroSince returns the number of tasks in the
+// distro that finished running after the given time.
+func CountFinishedTasksForDistroSince(distroID string, since time.Time) (int, error) {
+	return Count(db.Query(bson.M{
+		DistroIdKey:   distroID,
+		StatusKey:     bson.M{"$in": evergreen.TaskCompletedStatuses},
+		FinishTimeKey: bson.M{"$gt": since},
+	}))
+}
+
 // HasActivatedDependentTasks returns true if there are active tasks waiting on the given task.
 func HasActivatedDependentTasks(taskId string) (bool, error) {
 	numDependentTasks, err := Count(db.Query(bson.M{
@@ -125,6 +125,14 @@ type Task struct {
 	// UnattainableDependency caches the contents of DependsOn for more efficient querying.
 	UnattainableDependency bool `bson:"unattainable_dependency" json:"unattainable_dependency"`
 	NumDependents          int  `bson:"num_dependents,omitempty" json:"num_dependents,omitempty"`
+	// SchedulingWeight scales the task's contribution to the expected
+	// runtime and number of dependents of its unit when it is planned,
+	// without changing its priority. Values that are not positive are
+	// treated as a weight of 1.
+	SchedulingWeight float64 `bson:"scheduling_weight,omitempty" json:"scheduling_weight,omitempty"`
+	// Quarantined indicates that the task is known to be flaky, so the
+	// planner runs it after other tasks.
+	Quarantined bool `bson:"quarantined,omitempty" json:"quarantined,omitempty"`
 	// OverrideDependencies indicates whether a task should override its dependencies. If set, it will not
 	// wait for its dependencies to finish before running.
 	OverrideDependencies bool `bson:"override_dependencies,omitempty" json:"override_dependencies,omitempty"`
@@ -298,6 +306,9 @@ type StepbackInfo struct {
 	// NextStepbackTaskId stores the next task id to stepback to when doing bisect stepback. This
 	// is the middle of LastFailingStepbackTaskId and LastPassingStepbackTaskId.
 	NextStepbackTaskId string `bson:"next_stepback_task_id,omitempty" json:"next_stepback_task_id"`
+	// StepbackIterations is the number of bisect stepback iterations that were
+	// performed before this task was stepped back to.
+	StepbackIterations int `bson:"stepback_iterations,omitempty" json:"stepback_iterations"`
 }
 
 // ExecutionPlatform indicates the type of environment that the task runs in.
@@ -447,6 +458,16 @@ var (
 	AllStatuses = "*"
 )
 
+// GetSchedulingWeight returns the weight the planner applies to the
+// task's contribution to its unit.
+func (t *Task) GetSchedulingWeight() float64 {
+	if t.SchedulingWeight <= 0 {
+		return 1
+	}
+
+	return t.SchedulingWeight
+}
+
 // IsAbortable returns true if the task can be aborted.
 func (t *Task) IsAbortable() bool {
 	return t.Status == evergreen.TaskStarted ||
@@ -2979,6 +3000,13 @@ func GetAllDependencies(taskIDs []string, taskMap map[string]*Task) ([]Dependenc
 	return deps, nil
 }
 
+// IsDefaultExpectedDuration returns whether the duration stats are the
+// default that FetchExpectedDuration returns for tasks without any
+// runtime history.
+func IsDefaultExpectedDuration(stats util.DurationStats) bool {
+	return stats.Average == defaultTaskDuration && stats.StdDev == 0
+}
+
 func (t *Task) FetchExpectedDuration() util.DurationStats {
 	if t.DurationPrediction.TTL == 0 {
 		t.DurationPrediction.TTL = utility.JitterInterval(predictionTTL)
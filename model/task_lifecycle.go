@@ -589,6 +589,7 @@ func doBisectStepback(ctx context.Context, t *task.Task) error {
 	if t.StepbackInfo != nil && t.StepbackInfo.LastPassingStepbackTaskId != "" {
 		// Carry over from the last task.
 		s = *t.StepbackInfo
+		s.StepbackIterations++
 	} else {
 		// If this is the first iteration of stepback, we must get the initial condition (last successful passing task).
 		lastPassing, err := t.PreviousCompletedTask(t.Project, []string{evergreen.TaskSucceeded})
@@ -1066,6 +1067,11 @@ func dequeueAndRestartItem(ctx context.Context, opts dequeueAndRestartOptions) (
 	if err != nil {
 		return nil, errors.Wrapf(err, "dequeueing and aborting commit queue item '%s'", opts.itemVersionID)
 	}
+	grip.Error(message.WrapError(p.SetCommitQueueDequeueReason(opts.reason), message.Fields{
+		"message": "unable to record commit queue dequeue reason",
+		"patch":   p.Id.Hex(),
+		"reason":  opts.reason,
+	}))
 
 	grip.Info(message.Fields{
 		"message":      "commit queue item was dequeued and later items were restarted",
@@ -78,6 +78,10 @@ type Version struct {
 	// this comment, if they can be identified
 	AuthorID string `bson:"author_id,omitempty" json:"author_id,omitempty"`
 
+	// RestartedBy is the user who most recently restarted tasks in the
+	// version, if any.
+	RestartedBy string `bson:"restarted_by,omitempty" json:"restarted_by,omitempty"`
+
 	SatisfiedTriggers []string `bson:"satisfied_triggers,omitempty" json:"satisfied_triggers,omitempty"`
 	// Fields set if triggered by an upstream build
 	// TriggerID is the ID of the entity that triggered the downstream version. Depending on the trigger type, this
@@ -215,6 +219,17 @@ func setVersionStatus(versionId, newStatus string) error {
 	)
 }
 
+// setVersionRestartedBy records the user who restarted tasks in the
+// version.
+func setVersionRestartedBy(versionId, caller string) error {
+	return VersionUpdateOne(
+		bson.M{VersionIdKey: versionId},
+		bson.M{"$set": bson.M{
+			VersionRestartedByKey: caller,
+		}},
+	)
+}
+
 // GetTimeSpent returns the total time_taken and makespan of a version for
 // each task that has finished running
 func (v *Version) GetTimeSpent() (time.Duration, time.Duration, error) {
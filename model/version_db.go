@@ -50,6 +50,7 @@ var (
 	VersionActivatedKey            = bsonutil.MustHaveTag(Version{}, "Activated")
 	VersionAbortedKey              = bsonutil.MustHaveTag(Version{}, "Aborted")
 	VersionAuthorIDKey             = bsonutil.MustHaveTag(Version{}, "AuthorID")
+	VersionRestartedByKey          = bsonutil.MustHaveTag(Version{}, "RestartedBy")
 	VersionProjectStorageMethodKey = bsonutil.MustHaveTag(Version{}, "ProjectStorageMethod")
 )
 
@@ -134,6 +134,11 @@ func TestRestartVersion(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, evergreen.BuildStarted, b.Status)
 	assert.Equal(t, "caller", b.ActivatedBy)
+
+	v, err := VersionFindOneId(versionID)
+	require.NoError(t, err)
+	require.NotNil(t, v)
+	assert.Equal(t, "caller", v.RestartedBy)
 }
 
 func TestFindVersionByIdFail(t *testing.T) {
@@ -356,6 +356,9 @@ func SaveProjectSettingsForSection(ctx context.Context, projectId string, change
 		if err = handleGithubConflicts(mergedSection, "Toggling GitHub features"); err != nil {
 			return nil, err
 		}
+		if err = mergedSection.ValidateGithubSuccessDescription(); err != nil {
+			return nil, err
+		}
 		// At project creation we now insert a commit queue, however older projects still may not have one
 		// so we need to validate that this exists if the feature is being toggled on.
 		if !mergedBeforeRef.CommitQueue.IsEnabled() && mergedSection.CommitQueue.IsEnabled() {
@@ -3,6 +3,7 @@ package data
 import (
 	"context"
 
+	"github.com/evergreen-ci/evergreen"
 	"github.com/evergreen-ci/evergreen/model/distro"
 	"github.com/evergreen-ci/evergreen/model/task"
 	"github.com/evergreen-ci/evergreen/scheduler"
@@ -51,6 +52,13 @@ func CompareTasks(ctx context.Context, taskIds []string, useLegacy bool) ([]stri
 		if d == nil {
 			return nil, nil, errors.Errorf("distro '%s' not found", distroId)
 		}
+		settings, err := evergreen.GetConfig(ctx)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "getting scheduler settings")
+		}
+		if _, err = d.GetResolvedPlannerSettings(settings); err != nil {
+			return nil, nil, err
+		}
 		taskPlan := scheduler.PrepareTasksForPlanning(d, tasks)
 		tasks = taskPlan.Export()
 	}
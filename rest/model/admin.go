@@ -2394,6 +2394,7 @@ type APIUIConfig struct {
 	FileStreamingContentTypes []string `json:"file_streaming_content_types"`
 	LoginDomain               *string  `json:"login_domain"`
 	UserVoice                 *string  `json:"userVoice"`
+	OmitSpruceRedirect        bool     `json:"omit_spruce_redirect"`
 }
 
 func (a *APIUIConfig) BuildFromService(h interface{}) error {
@@ -2412,6 +2413,7 @@ func (a *APIUIConfig) BuildFromService(h interface{}) error {
 		a.LoginDomain = utility.ToStringPtr(v.LoginDomain)
 		a.UserVoice = utility.ToStringPtr(v.UserVoice)
 		a.FileStreamingContentTypes = v.FileStreamingContentTypes
+		a.OmitSpruceRedirect = v.OmitSpruceRedirect
 	default:
 		return errors.Errorf("programmatic error: expected UI config but got type %T", h)
 	}
@@ -2433,6 +2435,7 @@ func (a *APIUIConfig) ToService() (interface{}, error) {
 		FileStreamingContentTypes: a.FileStreamingContentTypes,
 		LoginDomain:               utility.FromStringPtr(a.LoginDomain),
 		UserVoice:                 utility.FromStringPtr(a.UserVoice),
+		OmitSpruceRedirect:        a.OmitSpruceRedirect,
 	}, nil
 }
 
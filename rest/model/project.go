@@ -581,6 +581,9 @@ type APIProjectRef struct {
 	StepbackBisect        *bool                      `json:"stepback_bisect"`
 	VersionControlEnabled *bool                      `json:"version_control_enabled"`
 	DisabledStatsCache    *bool                      `json:"disabled_stats_cache"`
+	// Priority of the project's tasks relative to other projects' tasks
+	// that share a distro
+	SchedulingPriority int64 `json:"scheduling_priority"`
 	// Usernames of project admins. Can be null for some projects (EVG-6598).
 	Admins []*string `json:"admins"`
 	// Usernames of project admins to remove
@@ -616,6 +619,26 @@ type APIProjectRef struct {
 	Banner                 APIProjectBanner        `json:"banner"`
 	ParsleyFilters         []APIParsleyFilter      `json:"parsley_filters"`
 	ProjectHealthView      model.ProjectHealthView `json:"project_health_view"`
+
+	// GitHub status settings.
+	GithubRequiredVariants         []*string      `json:"github_required_variants"`
+	GithubEarlyFailureStatus       *bool          `json:"github_early_failure_status"`
+	GithubFailedTaskLogLink        *bool          `json:"github_failed_task_log_link"`
+	GithubReportSkippedVariants    *bool          `json:"github_report_skipped_variants"`
+	GithubDebounceStatuses         *bool          `json:"github_debounce_statuses"`
+	GithubSummaryComment           *bool          `json:"github_summary_comment"`
+	GithubMaxBuildStatuses         int            `json:"github_max_build_statuses"`
+	GithubRequiredChecksSummary    *bool          `json:"github_required_checks_summary"`
+	GithubModuleStatuses           *bool          `json:"github_module_statuses"`
+	GithubReportRetriedTasks       *bool          `json:"github_report_retried_tasks"`
+	GithubCollapseSuccessStatuses  *bool          `json:"github_collapse_success_statuses"`
+	GithubStatusesDisabled         *bool          `json:"github_statuses_disabled"`
+	GithubCompletionEstimate       *bool          `json:"github_completion_estimate"`
+	GithubStatusMinIntervalSeconds int            `json:"github_status_min_interval_seconds"`
+	GithubCoalesceChildPatches     *bool          `json:"github_coalesce_child_patches"`
+	GithubVariantTimeBudgetSeconds map[string]int `json:"github_variant_time_budget_seconds"`
+	GithubReportBaseCommit         *bool          `json:"github_report_base_commit"`
+	GithubSuccessDescription       *string        `json:"github_success_description"`
 }
 
 // ToService returns a service layer ProjectRef using the data from APIProjectRef
@@ -663,6 +686,26 @@ func (p *APIProjectRef) ToService() (*model.ProjectRef, error) {
 		ProjectHealthView:      p.ProjectHealthView,
 	}
 
+	projectRef.SchedulingPriority = p.SchedulingPriority
+	projectRef.GithubRequiredVariants = utility.FromStringPtrSlice(p.GithubRequiredVariants)
+	projectRef.GithubEarlyFailureStatus = utility.BoolPtrCopy(p.GithubEarlyFailureStatus)
+	projectRef.GithubFailedTaskLogLink = utility.BoolPtrCopy(p.GithubFailedTaskLogLink)
+	projectRef.GithubReportSkippedVariants = utility.BoolPtrCopy(p.GithubReportSkippedVariants)
+	projectRef.GithubDebounceStatuses = utility.BoolPtrCopy(p.GithubDebounceStatuses)
+	projectRef.GithubSummaryComment = utility.BoolPtrCopy(p.GithubSummaryComment)
+	projectRef.GithubMaxBuildStatuses = p.GithubMaxBuildStatuses
+	projectRef.GithubRequiredChecksSummary = utility.BoolPtrCopy(p.GithubRequiredChecksSummary)
+	projectRef.GithubModuleStatuses = utility.BoolPtrCopy(p.GithubModuleStatuses)
+	projectRef.GithubReportRetriedTasks = utility.BoolPtrCopy(p.GithubReportRetriedTasks)
+	projectRef.GithubCollapseSuccessStatuses = utility.BoolPtrCopy(p.GithubCollapseSuccessStatuses)
+	projectRef.GithubStatusesDisabled = utility.BoolPtrCopy(p.GithubStatusesDisabled)
+	projectRef.GithubCompletionEstimate = utility.BoolPtrCopy(p.GithubCompletionEstimate)
+	projectRef.GithubStatusMinIntervalSeconds = p.GithubStatusMinIntervalSeconds
+	projectRef.GithubCoalesceChildPatches = utility.BoolPtrCopy(p.GithubCoalesceChildPatches)
+	projectRef.GithubVariantTimeBudgetSeconds = p.GithubVariantTimeBudgetSeconds
+	projectRef.GithubReportBaseCommit = utility.BoolPtrCopy(p.GithubReportBaseCommit)
+	projectRef.GithubSuccessDescription = utility.FromStringPtr(p.GithubSuccessDescription)
+
 	if projectRef.ProjectHealthView == "" {
 		projectRef.ProjectHealthView = model.ProjectHealthViewFailed
 	}
@@ -768,6 +811,26 @@ func (p *APIProjectRef) BuildPublicFields(projectRef model.ProjectRef) error {
 	p.GitTagAuthorizedTeams = utility.ToStringPtrSlice(projectRef.GitTagAuthorizedTeams)
 	p.GithubTriggerAliases = utility.ToStringPtrSlice(projectRef.GithubTriggerAliases)
 
+	p.SchedulingPriority = projectRef.SchedulingPriority
+	p.GithubRequiredVariants = utility.ToStringPtrSlice(projectRef.GithubRequiredVariants)
+	p.GithubEarlyFailureStatus = utility.BoolPtrCopy(projectRef.GithubEarlyFailureStatus)
+	p.GithubFailedTaskLogLink = utility.BoolPtrCopy(projectRef.GithubFailedTaskLogLink)
+	p.GithubReportSkippedVariants = utility.BoolPtrCopy(projectRef.GithubReportSkippedVariants)
+	p.GithubDebounceStatuses = utility.BoolPtrCopy(projectRef.GithubDebounceStatuses)
+	p.GithubSummaryComment = utility.BoolPtrCopy(projectRef.GithubSummaryComment)
+	p.GithubMaxBuildStatuses = projectRef.GithubMaxBuildStatuses
+	p.GithubRequiredChecksSummary = utility.BoolPtrCopy(projectRef.GithubRequiredChecksSummary)
+	p.GithubModuleStatuses = utility.BoolPtrCopy(projectRef.GithubModuleStatuses)
+	p.GithubReportRetriedTasks = utility.BoolPtrCopy(projectRef.GithubReportRetriedTasks)
+	p.GithubCollapseSuccessStatuses = utility.BoolPtrCopy(projectRef.GithubCollapseSuccessStatuses)
+	p.GithubStatusesDisabled = utility.BoolPtrCopy(projectRef.GithubStatusesDisabled)
+	p.GithubCompletionEstimate = utility.BoolPtrCopy(projectRef.GithubCompletionEstimate)
+	p.GithubStatusMinIntervalSeconds = projectRef.GithubStatusMinIntervalSeconds
+	p.GithubCoalesceChildPatches = utility.BoolPtrCopy(projectRef.GithubCoalesceChildPatches)
+	p.GithubVariantTimeBudgetSeconds = projectRef.GithubVariantTimeBudgetSeconds
+	p.GithubReportBaseCommit = utility.BoolPtrCopy(projectRef.GithubReportBaseCommit)
+	p.GithubSuccessDescription = utility.ToStringPtr(projectRef.GithubSuccessDescription)
+
 	if projectRef.ProjectHealthView == "" {
 		projectRef.ProjectHealthView = model.ProjectHealthViewFailed
 	}
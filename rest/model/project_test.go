@@ -67,3 +67,52 @@ func TestRecursivelyDefaultBooleans(t *testing.T) {
 	assert.Nil(t, myStruct.PtrStruct) // shouldn't be affected
 
 }
+
+func TestProjectRefSettingsRoundTrip(t *testing.T) {
+	pRef := model.ProjectRef{
+		Id:                             "project",
+		SchedulingPriority:             5,
+		GithubRequiredVariants:         []string{"required"},
+		GithubEarlyFailureStatus:       utility.TruePtr(),
+		GithubFailedTaskLogLink:        utility.TruePtr(),
+		GithubReportSkippedVariants:    utility.TruePtr(),
+		GithubDebounceStatuses:         utility.TruePtr(),
+		GithubSummaryComment:           utility.TruePtr(),
+		GithubMaxBuildStatuses:         10,
+		GithubRequiredChecksSummary:    utility.TruePtr(),
+		GithubModuleStatuses:           utility.TruePtr(),
+		GithubReportRetriedTasks:       utility.TruePtr(),
+		GithubCollapseSuccessStatuses:  utility.TruePtr(),
+		GithubStatusesDisabled:         utility.TruePtr(),
+		GithubCompletionEstimate:       utility.TruePtr(),
+		GithubStatusMinIntervalSeconds: 60,
+		GithubCoalesceChildPatches:     utility.TruePtr(),
+		GithubVariantTimeBudgetSeconds: map[string]int{"variant": 1800},
+		GithubReportBaseCommit:         utility.TruePtr(),
+		GithubSuccessDescription:       "passed in {duration}",
+	}
+	apiRef := &APIProjectRef{}
+	require.NoError(t, apiRef.BuildFromService(pRef))
+	roundTripped, err := apiRef.ToService()
+	require.NoError(t, err)
+
+	assert.Equal(t, pRef.SchedulingPriority, roundTripped.SchedulingPriority)
+	assert.Equal(t, pRef.GithubRequiredVariants, roundTripped.GithubRequiredVariants)
+	assert.Equal(t, pRef.GithubEarlyFailureStatus, roundTripped.GithubEarlyFailureStatus)
+	assert.Equal(t, pRef.GithubFailedTaskLogLink, roundTripped.GithubFailedTaskLogLink)
+	assert.Equal(t, pRef.GithubReportSkippedVariants, roundTripped.GithubReportSkippedVariants)
+	assert.Equal(t, pRef.GithubDebounceStatuses, roundTripped.GithubDebounceStatuses)
+	assert.Equal(t, pRef.GithubSummaryComment, roundTripped.GithubSummaryComment)
+	assert.Equal(t, pRef.GithubMaxBuildStatuses, roundTripped.GithubMaxBuildStatuses)
+	assert.Equal(t, pRef.GithubRequiredChecksSummary, roundTripped.GithubRequiredChecksSummary)
+	assert.Equal(t, pRef.GithubModuleStatuses, roundTripped.GithubModuleStatuses)
+	assert.Equal(t, pRef.GithubReportRetriedTasks, roundTripped.GithubReportRetriedTasks)
+	assert.Equal(t, pRef.GithubCollapseSuccessStatuses, roundTripped.GithubCollapseSuccessStatuses)
+	assert.Equal(t, pRef.GithubStatusesDisabled, roundTripped.GithubStatusesDisabled)
+	assert.Equal(t, pRef.GithubCompletionEstimate, roundTripped.GithubCompletionEstimate)
+	assert.Equal(t, pRef.GithubStatusMinIntervalSeconds, roundTripped.GithubStatusMinIntervalSeconds)
+	assert.Equal(t, pRef.GithubCoalesceChildPatches, roundTripped.GithubCoalesceChildPatches)
+	assert.Equal(t, pRef.GithubVariantTimeBudgetSeconds, roundTripped.GithubVariantTimeBudgetSeconds)
+	assert.Equal(t, pRef.GithubReportBaseCommit, roundTripped.GithubReportBaseCommit)
+	assert.Equal(t, pRef.GithubSuccessDescription, roundTripped.GithubSuccessDescription)
+}
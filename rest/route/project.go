@@ -360,6 +360,9 @@ func (h *projectIDPatchHandler) Run(ctx context.Context) gimlet.Responder {
 	if err := h.newProjectRef.ValidateEnabledRepotracker(); err != nil {
 		return gimlet.MakeJSONErrorResponder(errors.Wrap(err, "validating project repotracker"))
 	}
+	if err := h.newProjectRef.ValidateGithubSuccessDescription(); err != nil {
+		return gimlet.MakeJSONErrorResponder(errors.Wrap(err, "validating GitHub success description"))
+	}
 
 	before, err := dbModel.GetProjectSettings(h.newProjectRef)
 	if err != nil {
@@ -6,13 +6,23 @@ import (
 	"io"
 	"math"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/evergreen-ci/evergreen"
 	"github.com/evergreen-ci/evergreen/model/distro"
 	"github.com/evergreen-ci/evergreen/model/task"
+	"github.com/evergreen-ci/evergreen/util"
+	"github.com/mongodb/grip"
+	"github.com/mongodb/grip/message"
+	"github.com/pkg/errors"
 )
 
+// nowFunc returns the current time, which the planner uses to determine
+// how long tasks have been in the queue. Tests can replace it to rank
+// units independently of the wall clock.
+var nowFunc = time.Now
+
 // UnitCache stores an unordered collection of schedulable units. The
 // Unit type holds one or more tasks, but is handled by the scheduler
 // as a single object. While the constituent tasks in a unit have an
@@ -37,12 +47,13 @@ func (cache UnitCache) AddWhen(cond bool, id string, t task.Task) {
 
 // AddNew adds an entire unit to a cache with the specified ID. If the
 // cached item exists, AddNew extends the existing unit with the tasks
-// from the passed unit.
+// and tags from the passed unit.
 func (cache UnitCache) AddNew(id string, unit *Unit) {
 	if existing, ok := cache[id]; ok {
 		for _, t := range unit.tasks {
 			existing.Add(t)
 		}
+		existing.mergeTags(unit)
 
 		return
 	}
@@ -50,6 +61,47 @@ func (cache UnitCache) AddNew(id string, unit *Unit) {
 	cache[id] = unit
 }
 
+// Merge folds the units from another cache into this cache. Units
+// with keys that already exist in this cache are extended with the
+// tasks from the other unit, as in AddNew, and other keys are added
+// directly. Keys in the other cache that refer to the same unit
+// continue to refer to a single unit after the merge, and units
+// without a distro take the distro of the unit folded into them.
+func (cache UnitCache) Merge(other UnitCache) {
+	folded := map[*Unit]*Unit{}
+	for key, unit := range other {
+		existing, ok := cache[key]
+		if !ok {
+			continue
+		}
+
+		if existing != unit {
+			cache.AddNew(key, unit)
+			existing.id = ""
+			if existing.distro == nil {
+				existing.SetDistro(unit.distro)
+			}
+		}
+
+		if _, ok := folded[unit]; !ok {
+			folded[unit] = existing
+		}
+	}
+
+	for key, unit := range other {
+		if _, ok := cache[key]; ok {
+			continue
+		}
+
+		if existing, ok := folded[unit]; ok {
+			cache[key] = existing
+			continue
+		}
+
+		cache[key] = unit
+	}
+}
+
 func (cache UnitCache) Exists(key string) bool { _, ok := cache[key]; return ok }
 
 // Create makes a new unit around the existing task, caching it with
@@ -68,23 +120,123 @@ func (cache UnitCache) Create(id string, t task.Task) *Unit {
 	return unit
 }
 
-// Export returns an unordered sequence of unique Units.
+// UnitCacheStats summarizes the contents of a UnitCache.
+type UnitCacheStats struct {
+	// NumEntries is the number of keys in the cache.
+	NumEntries int `json:"num_entries"`
+	// NumUnits is the number of distinct units in the cache, by ID.
+	NumUnits int `json:"num_units"`
+	// NumWithoutDistro is the number of keys in the cache for units
+	// without a distro, which are dropped on export.
+	NumWithoutDistro int `json:"num_without_distro"`
+	// LargestUnitSize is the number of tasks in the largest unit.
+	LargestUnitSize int `json:"largest_unit_size"`
+}
+
+// Stats returns a summary of the cache, to help debug how tasks are
+// grouped into units before the cache is exported.
+func (cache UnitCache) Stats() UnitCacheStats {
+	stats := UnitCacheStats{NumEntries: len(cache)}
+	unitIDs := StringSet{}
+	for _, unit := range cache {
+		if !unitIDs.Visit(unit.ID()) {
+			stats.NumUnits++
+		}
+		if unit.distro == nil {
+			stats.NumWithoutDistro++
+		}
+		if len(unit.tasks) > stats.LargestUnitSize {
+			stats.LargestUnitSize = len(unit.tasks)
+		}
+	}
+
+	return stats
+}
+
+// Export returns an unordered sequence of unique Units. Units without
+// a distro are dropped.
 func (cache UnitCache) Export() TaskPlan {
-	seen := StringSet{}
+	tpl, _ := cache.export()
+	return tpl
+}
+
+// ExportStrict is the same as Export, but returns an error listing the
+// IDs of the units that were dropped because they don't have a
+// distro, which typically means SetDistro was never called.
+func (cache UnitCache) ExportStrict() (TaskPlan, error) {
+	tpl, reasons := cache.export()
+	var dropped []string
+	for _, reason := range reasons {
+		if reason.Reason == DropReasonNilDistro {
+			dropped = append(dropped, reason.UnitID)
+		}
+	}
+	if len(dropped) > 0 {
+		return tpl, errors.Errorf("dropped %d unit(s) without a distro: %s", len(dropped), strings.Join(dropped, ", "))
+	}
+
+	return tpl, nil
+}
+
+// Reasons that a unit is dropped when a UnitCache is exported.
+const (
+	// DropReasonNilDistro indicates that the unit doesn't have a
+	// distro.
+	DropReasonNilDistro = "nil distro"
+	// DropReasonDuplicate indicates that the unit has the same ID as
+	// another unit, so its tasks were merged into that unit.
+	DropReasonDuplicate = "duplicate"
+)
+
+// DropReason describes a unit that was dropped when a UnitCache was
+// exported.
+type DropReason struct {
+	UnitID string `json:"unit_id"`
+	Reason string `json:"reason"`
+}
+
+// ExportWithDiagnostics is the same as Export, but also returns why each
+// dropped unit was dropped, ordered by unit ID.
+func (cache UnitCache) ExportWithDiagnostics() (TaskPlan, []DropReason) {
+	return cache.export()
+}
+
+// export returns the unique units in the cache with a distro, as well
+// as the reasons that the other units were dropped, ordered by unit ID.
+func (cache UnitCache) export() (TaskPlan, []DropReason) {
+	seen := map[*Unit]struct{}{}
+	droppedIDs := StringSet{}
 	tpl := TaskPlan{}
+	var reasons []DropReason
 	for id := range cache {
-		if seen.Visit(cache[id].ID()) {
+		unit := cache[id]
+		if _, ok := seen[unit]; ok {
 			continue
 		}
+		seen[unit] = struct{}{}
 
-		if cache[id].distro == nil {
+		if unit.distro == nil {
+			if !droppedIDs.Visit(unit.ID()) {
+				reasons = append(reasons, DropReason{UnitID: unit.ID(), Reason: DropReasonNilDistro})
+			}
 			continue
 		}
 
-		tpl = append(tpl, cache[id])
+		tpl = append(tpl, unit)
 	}
 
-	return tpl
+	tpl, merged := tpl.normalize()
+	for _, id := range merged {
+		reasons = append(reasons, DropReason{UnitID: id, Reason: DropReasonDuplicate})
+	}
+	sort.SliceStable(reasons, func(i, j int) bool {
+		if reasons[i].UnitID != reasons[j].UnitID {
+			return reasons[i].UnitID < reasons[j].UnitID
+		}
+		return reasons[i].Reason < reasons[j].Reason
+	})
+
+	return tpl, reasons
 }
 
 // Unit is a holder of a group of related tasks which should be
@@ -92,10 +244,47 @@ func (cache UnitCache) Export() TaskPlan {
 // and their dependencies, or even all tasks of a version. All tasks
 // in a Unit must be unique with regards to their ID.
 type Unit struct {
-	tasks       map[string]task.Task
-	cachedValue int64
-	id          string
-	distro      *distro.Distro
+	tasks map[string]task.Task
+	// cachedValue is the unit's rank value, which is only valid when
+	// valueComputed is set, since a rank value can be any number.
+	cachedValue   int64
+	valueComputed bool
+	id            string
+	distro        *distro.Distro
+	// cachedInfo is the unit's computed info, which is computed when
+	// it's first needed and cleared whenever the unit changes.
+	cachedInfo *unitInfo
+	// insertionOrder is the position of each task in the order that
+	// the tasks were first added to the unit, by task ID.
+	insertionOrder map[string]int
+	// cachedRequesters is the classification of the requesters of the
+	// unit's tasks, which only changes when tasks are added, so it
+	// outlives changes that only clear cachedInfo.
+	cachedRequesters *unitRequesters
+	// projectPriority is the highest scheduling priority of the
+	// projects of the tasks in the unit.
+	projectPriority int64
+	// idleHosts is the number of the distro's hosts that are idle
+	// when the plan is made, which is only set if idleHostsKnown is.
+	idleHosts      int64
+	idleHostsKnown bool
+	// throughput is the number of tasks per minute the distro has
+	// recently been finishing when the plan is made.
+	throughput float64
+	// reducedGeneratorBoost indicates that the unit only gets a
+	// reduced boost for containing a generator task.
+	reducedGeneratorBoost bool
+	// completedBuilds is the number of builds whose remaining tasks
+	// are all in the unit.
+	completedBuilds int64
+	// deadline is when the unit's tasks should be finished by, if
+	// they have a deadline, and atRisk indicates that the unit is
+	// expected to finish after its deadline.
+	deadline time.Time
+	atRisk   bool
+	// Tags are arbitrary metadata attached to the unit by callers,
+	// which are carried through planning but don't affect ranking.
+	Tags map[string]string
 }
 
 // MakeuUnit constructs a new unit, caching a reference to the distro
@@ -127,7 +316,25 @@ func (unit *Unit) Export() TaskList {
 }
 
 // Add caches a task in the unit.
-func (unit *Unit) Add(t task.Task) { unit.tasks[t.Id] = t }
+func (unit *Unit) Add(t task.Task) {
+	if unit.insertionOrder == nil {
+		unit.insertionOrder = map[string]int{}
+	}
+	if _, ok := unit.insertionOrder[t.Id]; !ok {
+		unit.insertionOrder[t.Id] = len(unit.insertionOrder)
+	}
+	unit.tasks[t.Id] = t
+	unit.cachedInfo = nil
+	unit.cachedRequesters = nil
+}
+
+// invalidate clears the unit's cached info and rank value, so that they
+// are recomputed to reflect a change to the unit's ranking inputs.
+func (unit *Unit) invalidate() {
+	unit.cachedValue = 0
+	unit.valueComputed = false
+	unit.cachedInfo = nil
+}
 
 // SetDistro makes it possible to change/set the cached distro
 // reference in the unit; however, it is not possible to set a nil
@@ -138,6 +345,36 @@ func (unit *Unit) SetDistro(d *distro.Distro) {
 	}
 
 	unit.distro = d
+	unit.cachedInfo = nil
+}
+
+// SetTag attaches a piece of metadata to the unit, replacing any
+// existing value for the key.
+func (unit *Unit) SetTag(key, value string) {
+	if unit.Tags == nil {
+		unit.Tags = map[string]string{}
+	}
+
+	unit.Tags[key] = value
+}
+
+// GetTag returns the value of the tag with the given key, and whether
+// the unit has the tag.
+func (unit *Unit) GetTag(key string) (string, bool) {
+	value, ok := unit.Tags[key]
+	return value, ok
+}
+
+// mergeTags copies the tags from another unit that is being folded
+// into this unit. When both units have a tag with the same key, the
+// value already on this unit is kept.
+func (unit *Unit) mergeTags(other *Unit) {
+	for key, value := range other.Tags {
+		if _, ok := unit.Tags[key]; ok {
+			continue
+		}
+		unit.SetTag(key, value)
+	}
 }
 
 // Keys returns all of the ids of tasks in the unit.
@@ -155,29 +392,73 @@ func (unit *Unit) ID() string {
 		return unit.id
 	}
 
-	hash := sha1.New()
 	ids := make(sort.StringSlice, 0, len(unit.tasks))
 	for id := range unit.tasks {
 		ids = append(ids, id)
 	}
 	sort.Sort(ids)
 
+	var key string
+	useCache := unitIDs.enabled()
+	if useCache {
+		key = strings.Join(ids, "\x00")
+		if id, ok := unitIDs.get(key); ok {
+			unit.id = id
+			return unit.id
+		}
+	}
+
+	hash := sha1.New()
 	for _, id := range ids {
 		_, _ = io.WriteString(hash, id)
 	}
 
 	unit.id = fmt.Sprintf("%x", hash.Sum(nil))
+	if useCache {
+		unitIDs.put(key, unit.id)
+	}
+
 	return unit.id
 }
 
+// addDurationSaturating returns the sum of the durations, clamped to the
+// range of time.Duration rather than overflowing, so that the sums over
+// the tasks of very large units don't wrap around to the opposite sign.
+func addDurationSaturating(a, b time.Duration) time.Duration {
+	if b > 0 && a > math.MaxInt64-b {
+		return math.MaxInt64
+	}
+	if b < 0 && a < math.MinInt64-b {
+		return math.MinInt64
+	}
+
+	return a + b
+}
+
+// scaleDurationSaturating returns the duration multiplied by the weight,
+// clamped to the range of time.Duration.
+func scaleDurationSaturating(d time.Duration, weight float64) time.Duration {
+	scaled := float64(d) * weight
+	if scaled >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	if scaled <= math.MinInt64 {
+		return math.MinInt64
+	}
+
+	return time.Duration(scaled)
+}
+
 type unitInfo struct {
 	// TaskIDs are the ids for the tasks in the unit.
 	TaskIDs []string `json:"task_ids"`
 	// Settings are the planner settings for the unit's distro.
 	Settings distro.PlannerSettings `json:"settings"`
-	// ExpectedRuntime is the sum of the durations the tasks in the unit are expected to take.
+	// ExpectedRuntime is the sum of the durations the tasks in the unit are expected to take,
+	// clamped to the maximum duration if the sum would overflow.
 	ExpectedRuntime time.Duration `json:"expected_runtime_ns"`
-	// TimeInQueue is the sum of the durations the tasks in the unit have been waiting in the queue.
+	// TimeInQueue is the sum of the durations the tasks in the unit have been waiting in the queue,
+	// clamped to the maximum duration if the sum would overflow.
 	TimeInQueue time.Duration `json:"time_in_queue_ns"`
 	// TotalPriority is the sum of the priority values of all the tasks in the unit.
 	TotalPriority int64 `json:"total_priority"`
@@ -187,18 +468,109 @@ type unitInfo struct {
 	ContainsInCommitQueue bool `json:"contains_in_commit_queue"`
 	// ContainsInPatch indicates if the unit contains any tasks that are part of a patch.
 	ContainsInPatch bool `json:"contains_in_patch"`
+	// PatchRequester is the requester of most of the unit's patch tasks.
+	PatchRequester string `json:"patch_requester,omitempty"`
 	// ContainsNonGroupTasks indicates if the unit contains any tasks that are not part of a task group.
 	ContainsNonGroupTasks bool `json:"contains_non_group_tasks"`
 	// ContainsGenerateTask indicates if the unit contains generator task.
 	ContainsGenerateTask bool `json:"contains_generate_task"`
 	// ContainsStepbackTask indicates if the unit contains task activated by stepback.
 	ContainsStepbackTask bool `json:"contains_stepback_task"`
+	// StepbackIterations is the fewest stepback iterations performed before any of the unit's stepback tasks.
+	StepbackIterations int64 `json:"stepback_iterations"`
+	// SingleHostDistro indicates if the unit's distro only has a single host, so all units run serially.
+	SingleHostDistro bool `json:"single_host_distro"`
+	// ProjectPriority is the scheduling priority of the unit's projects.
+	ProjectPriority int64 `json:"project_priority"`
+	// IdleHosts is the number of the distro's hosts that are idle.
+	IdleHosts int64 `json:"idle_hosts"`
+	// IdleHostsKnown indicates if the number of idle hosts is known.
+	IdleHostsKnown bool `json:"idle_hosts_known"`
+	// Throughput is the number of tasks per minute the distro has recently been finishing.
+	Throughput float64 `json:"throughput"`
+	// Quarantined indicates if all of the tasks in the unit are quarantined.
+	Quarantined bool `json:"quarantined"`
+	// ReducedGeneratorBoost indicates if the unit only gets a reduced boost for its generator task.
+	ReducedGeneratorBoost bool `json:"reduced_generator_boost"`
+	// CompletedBuilds is the number of builds that the unit would finish.
+	CompletedBuilds int64 `json:"completed_builds"`
+	// TotalAverageRuntime is the sum of the average durations of all the tasks in the unit, including
+	// deactivated tasks, without weights or percentiles applied.
+	TotalAverageRuntime time.Duration `json:"total_average_runtime_ns"`
+}
+
+// Names of the terms that make up a unit's rank value.
+const (
+	RankFactorPatch               = "patch"
+	RankFactorPatchTimeInQueue    = "patch_time_in_queue"
+	RankFactorCommitQueue         = "commit_queue"
+	RankFactorMainlineTimeInQueue = "mainline_time_in_queue"
+	RankFactorStepback            = "stepback"
+	RankFactorLength              = "length"
+	RankFactorPriority            = "priority"
+	RankFactorNumDeps             = "num_deps"
+	RankFactorExpectedRuntime     = "expected_runtime"
+	RankFactorProjectPriority     = "project_priority"
+	RankFactorIdleHosts           = "idle_hosts"
+	RankFactorQuarantine          = "quarantine"
+	RankFactorCompletedBuilds     = "completed_builds"
+	RankFactorNegativePriority    = "negative_priority"
+)
+
+// maxThroughputAdjustment bounds how much the distro's throughput can
+// scale the weight of a unit's expected runtime, in either direction.
+const maxThroughputAdjustment = 2.0
+
+// throughputAdjustment returns the ratio of the observed throughput to
+// the expected throughput, bounded by maxThroughputAdjustment.
+func throughputAdjustment(observed, expected float64) float64 {
+	return math.Max(1/maxThroughputAdjustment, math.Min(maxThroughputAdjustment, observed/expected))
+}
+
+// negativePriorityPenalty is the value subtracted from a unit for each
+// unit of priority that its priority is below the minimum priority
+// multiplier.
+const negativePriorityPenalty = 100
+
+// completedBuildBonus is the value added to a unit, per unit of priority,
+// for each build that the unit would finish.
+const completedBuildBonus = 10
+
+// rankTerm is a single named term of a unit's rank value.
+type rankTerm struct {
+	Name  string
+	Value int64
 }
 
 func (u *unitInfo) value() int64 {
 	var value int64
+	for _, term := range u.terms() {
+		value += term.Value
+	}
+
+	return value
+}
+
+// effectivePriority returns the average priority of the tasks in the
+// unit, adjusted for task groups and generators, which multiplies most
+// of the terms of the unit's rank value. It's never less than the
+// minimum priority multiplier.
+func (u *unitInfo) effectivePriority() int64 {
+	priority := u.rawPriority()
+	if floor := u.Settings.GetMinPriorityMultiplier(); priority < floor {
+		return floor
+	}
 
+	return priority
+}
+
+// rawPriority returns the unit's priority before the minimum priority
+// multiplier is applied.
+func (u *unitInfo) rawPriority() int64 {
 	length := int64(len(u.TaskIDs))
+	if length == 0 {
+		return 1
+	}
 	priority := 1 + (u.TotalPriority / length)
 
 	if !u.ContainsNonGroupTasks {
@@ -210,42 +582,90 @@ func (u *unitInfo) value() int64 {
 	}
 	if u.ContainsGenerateTask {
 		// give generators a boost so people don't have to wait twice.
-		priority = priority * u.Settings.GetGenerateTaskFactor()
+		factor := u.Settings.GetGenerateTaskFactor()
+		if u.ReducedGeneratorBoost {
+			factor = 1 + (factor-1)/2
+		}
+		priority = priority * factor
+	}
+
+	return priority
+}
+
+// timeInQueueCurve applies the configured time in queue curve to an amount
+// of time in queue, so that with the sublinear curves the difference
+// between long waits matters less than the difference between short ones.
+func (u *unitInfo) timeInQueueCurve(amount float64) float64 {
+	if amount <= 0 {
+		return amount
+	}
+
+	switch u.Settings.GetTimeInQueueCurve() {
+	case distro.TimeInQueueCurveSqrt:
+		return math.Sqrt(amount)
+	case distro.TimeInQueueCurveLog:
+		return math.Log1p(amount)
+	default:
+		return amount
 	}
+}
+
+// terms returns the terms that add up to the unit's rank value.
+func (u *unitInfo) terms() []rankTerm {
+	length := int64(len(u.TaskIDs))
+	if length == 0 {
+		// if none of the tasks in the unit contribute to its
+		// value, only the tasks that it blocks are relevant.
+		return []rankTerm{{Name: RankFactorNumDeps, Value: u.NumDeps}}
+	}
+	priority := u.effectivePriority()
 
+	terms := make([]rankTerm, 0, 6)
 	if u.ContainsInPatch {
 		// give patches a bump, over non-patches.
-		value += priority * u.Settings.GetPatchFactor()
+		terms = append(terms, rankTerm{Name: RankFactorPatch, Value: priority * u.Settings.GetPatchFactorForRequester(u.PatchRequester)})
 		// patches that have spent more time in the queue
 		// should get worked on first (because people are
 		// waiting on the results), and because FIFO feels
 		// fair in this context.
-		value += priority * u.Settings.GetPatchTimeInQueueFactor() * int64(math.Floor(u.TimeInQueue.Minutes()/float64(length)))
+		terms = append(terms, rankTerm{
+			Name:  RankFactorPatchTimeInQueue,
+			Value: priority * u.Settings.GetPatchTimeInQueueFactor() * int64(math.Floor(u.timeInQueueCurve(u.TimeInQueue.Minutes()/float64(length)))),
+		})
 	} else if u.ContainsInCommitQueue {
-		// give commit queue patches a boost over everything else
-		priority += 200
-		value += priority * u.Settings.GetCommitQueueFactor()
+		// give commit queue patches a boost over everything else,
+		// including patches with similar priorities.
+		priority += u.Settings.GetCommitQueueOverPatchMargin()
+		terms = append(terms, rankTerm{Name: RankFactorCommitQueue, Value: priority * u.Settings.GetCommitQueueFactor()})
 	} else {
 		// for mainline builds that are more recent, give them a bit
 		// of a bump, to avoid running older builds first.
 		avgLifeTime := u.TimeInQueue / time.Duration(length)
 
-		var mainlinePriority int64
 		if avgLifeTime < time.Duration(7*24)*time.Hour {
-			mainlinePriority += u.Settings.GetMainlineTimeInQueueFactor() * int64((7*24*time.Hour - avgLifeTime).Hours())
+			terms = append(terms, rankTerm{
+				Name:  RankFactorMainlineTimeInQueue,
+				Value: priority * u.Settings.GetMainlineTimeInQueueFactor() * int64(u.timeInQueueCurve((7*24*time.Hour).Hours())-u.timeInQueueCurve(avgLifeTime.Hours())),
+			})
 		}
 		if u.ContainsStepbackTask {
-			mainlinePriority += u.Settings.GetStepbackTaskFactor()
+			// a bisection that has already taken many iterations
+			// shouldn't keep jumping ahead of other work.
+			stepbackValue := priority * u.Settings.GetStepbackTaskFactor()
+			if decay := u.Settings.GetStepbackDecayFactor(); decay > 0 && u.StepbackIterations > 0 {
+				stepbackValue = int64(float64(stepbackValue) / (1 + decay*float64(u.StepbackIterations)))
+			}
+			terms = append(terms, rankTerm{Name: RankFactorStepback, Value: stepbackValue})
 		}
-
-		value += priority * mainlinePriority
 	}
 
 	// Start with the number of tasks so that units with more
 	// tasks get sorted above one-offs, and then add the priority
 	// setting as a base.
-	value += length
-	value += priority
+	terms = append(terms,
+		rankTerm{Name: RankFactorLength, Value: length},
+		rankTerm{Name: RankFactorPriority, Value: priority},
+	)
 
 	// The remaining values are normalized per tasks, to avoid
 	// situations where larger units are always prioritized above
@@ -258,48 +678,254 @@ func (u *unitInfo) value() int64 {
 	// Increase the value for the number of dependencies, so that
 	// tasks (and units) which block other tasks run before tasks
 	// that don't block other tasks.
-	value += priority * (u.NumDeps / length)
+	terms = append(terms, rankTerm{Name: RankFactorNumDeps, Value: priority * (u.NumDeps / length)})
 
 	// Increase the value for tasks with longer runtimes, given
 	// that most of our workloads have different runtimes, and we
 	// don't want to have longer makespans if longer running tasks
 	// have to execute after shorter running tasks.
-	value += priority * u.Settings.GetExpectedRuntimeFactor() * int64(math.Floor(u.ExpectedRuntime.Minutes()/float64(length)))
+	//
+	// On distros with a single host, all units run serially, so
+	// the makespan is the same regardless of the order; running
+	// shorter tasks first reduces the average wait instead. Distros
+	// that optimize for latency also run shorter tasks first.
+	//
+	// When the distro is finishing tasks faster than expected, it
+	// can afford to start larger units, so their runtime counts for
+	// more, and when it's finishing them slower, it counts for less.
+	runtimeMinutes := u.ExpectedRuntime.Minutes()
+	if u.Settings.ShouldScaleByThroughput() && u.Throughput > 0 {
+		runtimeMinutes *= throughputAdjustment(u.Throughput, u.Settings.GetExpectedThroughput())
+	}
+	runtimeValue := priority * u.Settings.GetExpectedRuntimeFactor() * int64(math.Floor(runtimeMinutes/float64(length)))
+	if u.SingleHostDistro || u.Settings.GetSchedulingObjective() == distro.SchedulingObjectiveLatency {
+		runtimeValue = -runtimeValue
+	}
+	terms = append(terms, rankTerm{Name: RankFactorExpectedRuntime, Value: runtimeValue})
 
-	return value
+	// Units from more important projects win ties with units from
+	// other projects sharing the distro.
+	if u.ProjectPriority != 0 {
+		terms = append(terms, rankTerm{Name: RankFactorProjectPriority, Value: u.ProjectPriority * u.Settings.GetProjectPriorityFactor()})
+	}
+
+	// Larger units can only run in parallel if there are enough idle
+	// hosts, so scale the bonus for each task beyond the first by how
+	// many more hosts are idle than the unit needs. When few hosts are
+	// idle, this favors smaller units, which reduces the wait for
+	// the hosts that are available. Without a count of the idle hosts,
+	// the units aren't scaled at all.
+	if u.Settings.ShouldScaleByIdleHosts() && u.IdleHostsKnown && length > 1 {
+		terms = append(terms, rankTerm{Name: RankFactorIdleHosts, Value: priority * (length - 1) * (u.IdleHosts - length)})
+	}
+
+	// Finishing a build makes its results available, which is
+	// worth more than starting on a build that has many tasks left.
+	if u.Settings.ShouldPrioritizeBuildCompletion() && u.CompletedBuilds > 0 {
+		terms = append(terms, rankTerm{Name: RankFactorCompletedBuilds, Value: priority * u.CompletedBuilds * completedBuildBonus})
+	}
+
+	// Deprioritized units can't sort lower by their multiplier
+	// without flipping the sign of the other terms, so they sort
+	// lower by a penalty instead.
+	if deficit := u.rawPriority() - u.Settings.GetMinPriorityMultiplier(); deficit < 0 {
+		terms = append(terms, rankTerm{Name: RankFactorNegativePriority, Value: deficit * negativePriorityPenalty})
+	}
+
+	// Quarantined tasks are known to be flaky, so push them behind
+	// other units by shrinking their value, while still letting
+	// their time in the queue eventually bring them to the front.
+	if u.Quarantined {
+		var total int64
+		for _, term := range terms {
+			total += term.Value
+		}
+		factor := u.Settings.GetQuarantineFactor()
+		reduced := total / factor
+		if total < 0 {
+			reduced = total * factor
+		}
+		terms = append(terms, rankTerm{Name: RankFactorQuarantine, Value: reduced - total})
+	}
+
+	return terms
 }
 
+// info returns the unit's info, computing it if it isn't already cached.
 func (unit *Unit) info() unitInfo {
+	if unit.cachedInfo == nil {
+		info := unit.computeInfo()
+		unit.cachedInfo = &info
+	}
+
+	return *unit.cachedInfo
+}
+
+func (unit *Unit) computeInfo() unitInfo {
+	rankValueStats.recordInfoComputation()
+
+	d := unit.distro
+	if d == nil {
+		d = &distro.Distro{}
+	}
 	info := unitInfo{
-		Settings: unit.distro.PlannerSettings,
+		Settings:         d.PlannerSettings,
+		SingleHostDistro: d.GetPoolSize() == 1,
+		ProjectPriority:  unit.projectPriority,
+		IdleHosts:        unit.idleHosts,
+		IdleHostsKnown:   unit.idleHostsKnown,
+		Throughput:       unit.throughput,
+
+		ReducedGeneratorBoost: unit.reducedGeneratorBoost,
+		CompletedBuilds:       unit.completedBuilds,
 	}
 
+	excludeDeactivated := info.Settings.ShouldExcludeDeactivatedTasks()
+	requesters := unit.requesters(excludeDeactivated)
+	info.ContainsInCommitQueue = requesters.containsInCommitQueue
+	info.ContainsInPatch = requesters.containsInPatch
+	info.PatchRequester = requesters.patchRequester
+
+	runtimePercentile := info.Settings.GetExpectedRuntimePercentile()
+	numQuarantined := 0
 	for _, t := range unit.tasks {
-		if evergreen.IsCommitQueueRequester(t.Requester) || evergreen.IsGithubMergeQueueRequester(t.Requester) {
-			info.ContainsInCommitQueue = true
-		} else if evergreen.IsPatchRequester(t.Requester) {
-			info.ContainsInPatch = true
+		// the weight only scales the task's contribution to the
+		// unit, and does not change its priority.
+		weight := t.GetSchedulingWeight()
+		durationStats := expectedDuration(&t, info.Settings)
+		info.TotalAverageRuntime += durationStats.Average
+
+		if excludeDeactivated && !t.Activated {
+			// deactivated tasks won't run, so they shouldn't
+			// affect the unit's rank, but they may still block
+			// the tasks that depend on them.
+			info.NumDeps += int64(float64(t.NumDependents) * weight)
+			continue
 		}
 
 		info.ContainsNonGroupTasks = info.ContainsNonGroupTasks || t.TaskGroup == ""
 		info.ContainsGenerateTask = info.ContainsGenerateTask || t.GenerateTask
-		info.ContainsStepbackTask = info.ContainsStepbackTask || t.ActivatedBy == evergreen.StepbackTaskActivator
+		if t.ActivatedBy == evergreen.StepbackTaskActivator {
+			var iterations int64
+			if t.StepbackInfo != nil {
+				iterations = int64(t.StepbackInfo.StepbackIterations)
+			}
+			if !info.ContainsStepbackTask || iterations < info.StepbackIterations {
+				info.StepbackIterations = iterations
+			}
+			info.ContainsStepbackTask = true
+		}
+		if t.Quarantined {
+			numQuarantined++
+		}
 
 		if !t.ActivatedTime.IsZero() {
-			info.TimeInQueue += time.Since(t.ActivatedTime)
+			info.TimeInQueue = addDurationSaturating(info.TimeInQueue, nowFunc().Sub(t.ActivatedTime))
 		} else if !t.IngestTime.IsZero() {
-			info.TimeInQueue += time.Since(t.IngestTime)
+			info.TimeInQueue = addDurationSaturating(info.TimeInQueue, nowFunc().Sub(t.IngestTime))
 		}
 
 		info.TotalPriority += t.Priority
-		info.ExpectedRuntime += t.FetchExpectedDuration().Average
-		info.NumDeps += int64(t.NumDependents)
+		expectedRuntime := durationStats.Average
+		if runtimePercentile > 0 {
+			// the average under-weights tasks that occasionally
+			// run much longer than usual.
+			expectedRuntime = durationStats.Percentile(float64(runtimePercentile))
+		}
+		info.ExpectedRuntime = addDurationSaturating(info.ExpectedRuntime, scaleDurationSaturating(expectedRuntime, weight))
+		info.NumDeps += int64(float64(t.NumDependents) * weight)
 		info.TaskIDs = append(info.TaskIDs, t.Id)
 	}
 
+	info.Quarantined = numQuarantined > 0 && numQuarantined == len(info.TaskIDs)
+
 	return info
 }
 
+// expectedDuration returns the task's expected duration stats. Tasks
+// without any runtime history use the distro's default task duration,
+// if it has one, rather than the default for every distro.
+func expectedDuration(t *task.Task, settings distro.PlannerSettings) util.DurationStats {
+	stats := t.FetchExpectedDuration()
+	if defaultDuration := settings.GetDefaultTaskDuration(); defaultDuration > 0 && task.IsDefaultExpectedDuration(stats) {
+		return util.DurationStats{Average: defaultDuration}
+	}
+
+	return stats
+}
+
+// requesterClass is the kind of requester that a task is from, as far as
+// the planner is concerned.
+type requesterClass int
+
+const (
+	requesterClassMainline requesterClass = iota
+	requesterClassPatch
+	requesterClassCommitQueue
+)
+
+// classifyRequester returns the class of the requester. It's a variable
+// so that tests can observe how often requesters are classified.
+var classifyRequester = func(requester string) requesterClass {
+	if evergreen.IsCommitQueueRequester(requester) || evergreen.IsGithubMergeQueueRequester(requester) {
+		return requesterClassCommitQueue
+	}
+	if evergreen.IsPatchRequester(requester) {
+		return requesterClassPatch
+	}
+
+	return requesterClassMainline
+}
+
+// unitRequesters is the classification of the requesters of a unit's
+// tasks.
+type unitRequesters struct {
+	// excludeDeactivated indicates whether deactivated tasks were
+	// left out of the classification.
+	excludeDeactivated    bool
+	containsInCommitQueue bool
+	containsInPatch       bool
+	// patchRequester is the requester of most of the unit's patch
+	// tasks.
+	patchRequester string
+}
+
+// requesters returns the classification of the requesters of the unit's
+// tasks, computing it if it isn't already cached for the same handling
+// of deactivated tasks.
+func (unit *Unit) requesters(excludeDeactivated bool) unitRequesters {
+	if unit.cachedRequesters != nil && unit.cachedRequesters.excludeDeactivated == excludeDeactivated {
+		return *unit.cachedRequesters
+	}
+
+	out := unitRequesters{excludeDeactivated: excludeDeactivated}
+	patchRequesters := map[string]int{}
+	for _, t := range unit.tasks {
+		if excludeDeactivated && !t.Activated {
+			continue
+		}
+
+		switch classifyRequester(t.Requester) {
+		case requesterClassCommitQueue:
+			out.containsInCommitQueue = true
+		case requesterClassPatch:
+			out.containsInPatch = true
+			patchRequesters[t.Requester]++
+		}
+	}
+
+	for requester, count := range patchRequesters {
+		// break ties by name, so the requester doesn't depend on
+		// the map's iteration order.
+		if count > patchRequesters[out.patchRequester] || (count == patchRequesters[out.patchRequester] && requester < out.patchRequester) {
+			out.patchRequester = requester
+		}
+	}
+
+	unit.cachedRequesters = &out
+	return out
+}
+
 // RankValue returns a point value for the tasks in the unit that can
 // be used to compare units with each other.
 //
@@ -307,16 +933,68 @@ func (unit *Unit) info() unitInfo {
 // units that have been in the queue for longer, with longer expected
 // runtimes. The tasks' priority acts as a multiplying factor.
 func (unit *Unit) RankValue() int64 {
-	if unit.cachedValue > 0 {
+	if unit.valueComputed {
+		rankValueStats.record(true)
 		return unit.cachedValue
 	}
 
+	rankValueStats.record(false)
 	info := unit.info()
 	unit.cachedValue = info.value()
+	unit.valueComputed = true
 
 	return unit.cachedValue
 }
 
+// EffectivePriority returns the priority that the unit's rank value is
+// computed with, which accounts for the priorities of its tasks and
+// the bumps given to task groups and generators.
+func (unit *Unit) EffectivePriority() int64 {
+	info := unit.info()
+	return info.effectivePriority()
+}
+
+// DominantFactor returns the name of the term that contributes the most
+// to the unit's rank value, which is one of the RankFactor constants.
+func (unit *Unit) DominantFactor() string {
+	info := unit.info()
+
+	var dominant rankTerm
+	for idx, term := range info.terms() {
+		if idx == 0 || term.Value > dominant.Value {
+			dominant = term
+		}
+	}
+
+	return dominant.Name
+}
+
+// maxPriority returns the highest priority of any task in the unit.
+func (unit *Unit) maxPriority() int64 {
+	var max int64
+	first := true
+	for _, t := range unit.tasks {
+		if first || t.Priority > max {
+			max = t.Priority
+			first = false
+		}
+	}
+
+	return max
+}
+
+// isMainline returns true if none of the tasks in the unit are from a
+// patch.
+func (unit *Unit) isMainline() bool {
+	for _, t := range unit.tasks {
+		if evergreen.IsPatchRequester(t.Requester) {
+			return false
+		}
+	}
+
+	return len(unit.tasks) > 0
+}
+
 // StringSet provides simple tools for managing sets of strings.
 type StringSet map[string]struct{}
 
@@ -366,14 +1044,349 @@ func (tl TaskList) Less(i, j int) bool {
 	return t1.FetchExpectedDuration().Average > t2.FetchExpectedDuration().Average
 }
 
+// sortWithDependencies sorts the tasks, and then moves each task that
+// depends on another task in the list after that task, otherwise
+// keeping the sorted order. This way, a dispatcher pulling tasks in
+// order never reaches a task before its dependencies in the same unit.
+func (tl TaskList) sortWithDependencies() {
+	sort.Sort(tl)
+	tl.orderDependencies()
+}
+
+// orderDependencies reorders the tasks so that each task comes after the
+// tasks in the list that it depends on, otherwise keeping the tasks in
+// their current order.
+func (tl TaskList) orderDependencies() {
+	positions := make(map[string]int, len(tl))
+	for idx, t := range tl {
+		positions[t.Id] = idx
+	}
+	numPending := make([]int, len(tl))
+	dependents := make([][]int, len(tl))
+	for idx, t := range tl {
+		for _, dep := range t.DependsOn {
+			if depIdx, ok := positions[dep.TaskId]; ok && depIdx != idx {
+				numPending[idx]++
+				dependents[depIdx] = append(dependents[depIdx], idx)
+			}
+		}
+	}
+
+	out := make(TaskList, 0, len(tl))
+	placed := make([]bool, len(tl))
+	for len(out) < len(tl) {
+		next := -1
+		for idx := range tl {
+			if !placed[idx] && numPending[idx] <= 0 {
+				next = idx
+				break
+			}
+		}
+		if next < 0 {
+			// the remaining tasks have a dependency cycle, so
+			// fall back to the sorted order.
+			for idx := range tl {
+				if !placed[idx] {
+					next = idx
+					break
+				}
+			}
+		}
+
+		placed[next] = true
+		out = append(out, tl[next])
+		for _, idx := range dependents[next] {
+			numPending[idx]--
+		}
+	}
+
+	copy(tl, out)
+}
+
 // TaskPlan provides a sortable interface on top of a slice of
 // schedulable units, with ordering of units provided by the
 // implementation of RankValue.
 type TaskPlan []*Unit
 
-func (tpl TaskPlan) Len() int           { return len(tpl) }
-func (tpl TaskPlan) Less(i, j int) bool { return tpl[i].RankValue() > tpl[j].RankValue() }
-func (tpl TaskPlan) Swap(i, j int)      { tpl[i], tpl[j] = tpl[j], tpl[i] }
+func (tpl TaskPlan) Len() int      { return len(tpl) }
+func (tpl TaskPlan) Swap(i, j int) { tpl[i], tpl[j] = tpl[j], tpl[i] }
+
+// Less orders units by their RankValue. When the distro's planner
+// settings use strict priority tiers, units are first ordered by the
+// highest priority of their tasks, and RankValue is only used to
+// order units within the same tier. Units that are otherwise equal are
+// ordered by their IDs.
+func (tpl TaskPlan) Less(i, j int) bool {
+	return defaultUnitComparator(tpl[i], tpl[j]) < 0
+}
+
+// SetProjectPriorities annotates each unit in the plan with the highest
+// scheduling priority of the projects of its tasks, given the
+// priorities by project ID. Projects without a priority have a priority
+// of 0.
+func (tpl TaskPlan) SetProjectPriorities(priorities map[string]int64) {
+	for _, unit := range tpl {
+		var projectPriority int64
+		first := true
+		for _, t := range unit.tasks {
+			if priority := priorities[t.Project]; first || priority > projectPriority {
+				projectPriority = priority
+				first = false
+			}
+		}
+
+		unit.projectPriority = projectPriority
+		unit.invalidate()
+	}
+}
+
+// SetIdleHosts annotates each unit in the plan with the number of the
+// distro's hosts that are currently idle, which only affects ranking
+// when the distro scales units by idle hosts.
+func (tpl TaskPlan) SetIdleHosts(idleHosts int) {
+	for _, unit := range tpl {
+		unit.idleHosts = int64(idleHosts)
+		unit.idleHostsKnown = true
+		unit.invalidate()
+	}
+}
+
+// SetThroughput annotates each unit in the plan with the number of tasks
+// per minute that the distro has recently been finishing, which only
+// affects ranking when the distro scales units by throughput.
+func (tpl TaskPlan) SetThroughput(tasksPerMinute float64) {
+	for _, unit := range tpl {
+		unit.throughput = tasksPerMinute
+		unit.invalidate()
+	}
+}
+
+// SetRemainingBuildTasks annotates each unit in the plan with the number
+// of builds that it would finish, given the number of tasks remaining in
+// each build by build ID. A unit finishes a build if all of the build's
+// remaining tasks are in the unit. Builds without a count aren't
+// finished by any unit. This only affects ranking when the distro
+// prioritizes build completion.
+func (tpl TaskPlan) SetRemainingBuildTasks(remaining map[string]int) {
+	for _, unit := range tpl {
+		tasksPerBuild := map[string]int{}
+		for _, t := range unit.tasks {
+			if t.BuildId != "" {
+				tasksPerBuild[t.BuildId]++
+			}
+		}
+
+		var completed int64
+		for buildID, count := range tasksPerBuild {
+			if numRemaining, ok := remaining[buildID]; ok && numRemaining > 0 && count >= numRemaining {
+				completed++
+			}
+		}
+
+		unit.completedBuilds = completed
+		unit.invalidate()
+	}
+}
+
+// LimitGeneratorBoost gives the full boost for containing a generator
+// task to at most k units in the plan, so that many generators can't
+// starve every other unit. The units that keep the full boost are the
+// ones that rank highest with a reduced boost, and the rest only get
+// the reduced boost. If k is not positive, every unit gets the full
+// boost.
+func (tpl TaskPlan) LimitGeneratorBoost(k int) {
+	generators := TaskPlan{}
+	for _, unit := range tpl {
+		unit.reducedGeneratorBoost = false
+		unit.invalidate()
+		if k > 0 && unit.info().ContainsGenerateTask {
+			unit.reducedGeneratorBoost = true
+			unit.invalidate()
+			generators = append(generators, unit)
+		}
+	}
+	if len(generators) <= k {
+		for _, unit := range generators {
+			unit.reducedGeneratorBoost = false
+			unit.invalidate()
+		}
+		return
+	}
+
+	sort.SliceStable(generators, func(i, j int) bool {
+		if generators[i].RankValue() != generators[j].RankValue() {
+			return generators[i].RankValue() > generators[j].RankValue()
+		}
+		return generators[i].ID() < generators[j].ID()
+	})
+	for _, unit := range generators[:k] {
+		unit.reducedGeneratorBoost = false
+		unit.invalidate()
+	}
+}
+
+// SplitUnits returns the plan with each unit that has more than maxSize
+// tasks split into units of at most maxSize tasks, in the order that
+// the tasks would be dispatched. The tasks of a single-host task group
+// share setup, teardown, and processes on one host, so they're never
+// split up, even if the task group alone exceeds maxSize.
+func (tpl TaskPlan) SplitUnits(maxSize int) TaskPlan {
+	if maxSize <= 0 {
+		return tpl
+	}
+
+	out := make(TaskPlan, 0, len(tpl))
+	for _, unit := range tpl {
+		if len(unit.tasks) <= maxSize {
+			out = append(out, unit)
+			continue
+		}
+
+		for _, tasks := range unit.splitTasks(maxSize) {
+			grip.InfoWhen(len(tasks) > maxSize, message.Fields{
+				"message":       "unit exceeds the max unit size, but its task group can't be split",
+				"unit_id":       unit.ID(),
+				"num_tasks":     len(tasks),
+				"max_unit_size": maxSize,
+			})
+
+			part := MakeUnit(unit.distro)
+			for _, t := range tasks {
+				part.Add(t)
+			}
+			part.projectPriority = unit.projectPriority
+			part.idleHosts = unit.idleHosts
+			part.idleHostsKnown = unit.idleHostsKnown
+			part.throughput = unit.throughput
+			part.reducedGeneratorBoost = unit.reducedGeneratorBoost
+			part.deadline = unit.deadline
+			part.mergeTags(unit)
+			out = append(out, part)
+		}
+	}
+
+	return out
+}
+
+// orderedTasks returns the tasks in the unit in the order they should be
+// dispatched. Tasks are ordered by the TaskList ordering, unless the
+// unit isn't a task group and the distro preserves the order that tasks
+// were queued in. Either way, tasks come after the tasks in the unit
+// that they depend on.
+func (unit *Unit) orderedTasks() TaskList {
+	tasks := unit.Export()
+	if !unit.preservesTaskOrder() {
+		tasks.sortWithDependencies()
+		return tasks
+	}
+
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return unit.insertionOrder[tasks[i].Id] < unit.insertionOrder[tasks[j].Id]
+	})
+	tasks.orderDependencies()
+
+	return tasks
+}
+
+// preservesTaskOrder returns whether the unit's tasks should be
+// dispatched in the order that they were added to the unit.
+func (unit *Unit) preservesTaskOrder() bool {
+	if unit.distro == nil || !unit.distro.PlannerSettings.ShouldPreserveTaskOrder() {
+		return false
+	}
+	for _, t := range unit.tasks {
+		if t.TaskGroup != "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// splitTasks divides the tasks in the unit into ordered chunks of at
+// most maxSize tasks, keeping the tasks of each single-host task group
+// in the same chunk. A chunk is only larger than maxSize if it holds a
+// single task group that is larger than maxSize.
+func (unit *Unit) splitTasks(maxSize int) [][]task.Task {
+	tasks := unit.orderedTasks()
+
+	// group the tasks that must stay together, in the order of their
+	// first task.
+	groups := [][]task.Task{}
+	groupIdx := map[string]int{}
+	for _, t := range tasks {
+		if !t.IsPartOfSingleHostTaskGroup() {
+			groups = append(groups, []task.Task{t})
+			continue
+		}
+
+		key := t.GetTaskGroupString()
+		if idx, ok := groupIdx[key]; ok {
+			groups[idx] = append(groups[idx], t)
+			continue
+		}
+		groupIdx[key] = len(groups)
+		groups = append(groups, []task.Task{t})
+	}
+
+	chunks := [][]task.Task{}
+	var current []task.Task
+	for _, group := range groups {
+		if len(current) > 0 && len(current)+len(group) > maxSize {
+			chunks = append(chunks, current)
+			current = nil
+		}
+		current = append(current, group...)
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, current)
+	}
+
+	return chunks
+}
+
+// Normalize returns the plan with any distinct units that have the same
+// ID, and therefore the same tasks, merged into a single unit, so that
+// each set of tasks appears in the plan once.
+func (tpl TaskPlan) Normalize() TaskPlan {
+	out, _ := tpl.normalize()
+	return out
+}
+
+// normalize merges the units with the same ID, returning the normalized
+// plan as well as the ID of each unit that was merged into another.
+func (tpl TaskPlan) normalize() (TaskPlan, []string) {
+	out := make(TaskPlan, 0, len(tpl))
+	byID := make(map[string]*Unit, len(tpl))
+	var merged []string
+	for _, unit := range tpl {
+		id := unit.ID()
+		existing, ok := byID[id]
+		if !ok {
+			byID[id] = unit
+			out = append(out, unit)
+			continue
+		}
+		if existing == unit {
+			continue
+		}
+
+		for _, t := range unit.tasks {
+			existing.Add(t)
+		}
+		existing.mergeTags(unit)
+		existing.invalidate()
+		merged = append(merged, id)
+	}
+
+	grip.InfoWhen(len(merged) > 0, message.Fields{
+		"message":    "merged duplicate units in plan",
+		"num_merged": len(merged),
+		"unit_ids":   merged,
+	})
+
+	return out, merged
+}
 
 func (tpl TaskPlan) Keys() []string {
 	out := []string{}
@@ -383,18 +1396,157 @@ func (tpl TaskPlan) Keys() []string {
 	return out
 }
 
+// WarmRankValues computes and caches the rank values of only the units
+// with the given IDs, as returned by Unit.ID, so that after some units
+// change, the next sort doesn't compute the values of those units while
+// comparing them. IDs that aren't in the plan are ignored.
+func (tpl TaskPlan) WarmRankValues(ids ...string) {
+	if len(ids) == 0 {
+		return
+	}
+
+	toWarm := StringSet{}
+	for _, id := range ids {
+		toWarm.Add(id)
+	}
+	for _, unit := range tpl {
+		if toWarm.Check(unit.ID()) {
+			unit.RankValue()
+		}
+	}
+}
+
 // PrepareTasksForPlanning takes a list of tasks for a distro and
-// returns a TaskPlan, grouping tasks into the appropriate units.
+// returns a TaskPlan, grouping tasks into the appropriate units. The
+// distro's planner settings are used as they are, so callers should
+// resolve them first.
 func PrepareTasksForPlanning(distro *distro.Distro, tasks []task.Task) TaskPlan {
+	return prepareTasksForPlanning(distro, tasks, nil)
+}
+
+// PrepareTasksForPlanningBy is the same as PrepareTasksForPlanning, but
+// groups tasks with the same key, as returned by the given function,
+// into the same unit instead of grouping them by task group or version.
+// Tasks with an empty key are planned on their own. As with the default
+// grouping, tasks are also added to the units of the tasks they depend
+// on.
+func PrepareTasksForPlanningBy(distro *distro.Distro, tasks []task.Task, keyFn func(task.Task) string) TaskPlan {
+	return prepareTasksForPlanning(distro, tasks, keyFn)
+}
+
+// prepareTasksForPlanning plans the tasks, grouping them by the key
+// function if it's set, and by the default grouping otherwise.
+func prepareTasksForPlanning(distro *distro.Distro, tasks []task.Task, keyFn func(task.Task) string) TaskPlan {
+	tasks, numPaused := withoutPausedTaskGroups(tasks)
+	grip.InfoWhen(numPaused > 0, message.Fields{
+		"message":     "excluded tasks in paused task groups from the plan",
+		"distro":      distro.Id,
+		"num_tasks":   numPaused,
+		"task_groups": PausedTaskGroups(),
+	})
+
+	tasks, blocked := withoutTasksBlockedByFailedDependencies(tasks)
+	grip.InfoWhen(len(blocked) > 0, message.Fields{
+		"message":   "excluded tasks blocked by failed dependencies from the plan",
+		"distro":    distro.Id,
+		"num_tasks": len(blocked),
+		"tasks":     blocked,
+	})
+
+	var cache UnitCache
+	if keyFn != nil {
+		cache = makeUnitCacheBy(distro, tasks, keyFn)
+	} else {
+		cache = makeUnitCache(distro, tasks)
+	}
+	plan := cache.Export()
+	if maxSize := distro.PlannerSettings.GetMaxUnitSize(); maxSize > 0 {
+		plan = plan.SplitUnits(maxSize)
+	}
+
+	return plan
+}
+
+// withoutTasksBlockedByFailedDependencies returns the tasks that don't
+// depend on a failed task in the set, since those tasks can never run,
+// along with the IDs of the tasks that were excluded. Dependencies that
+// are satisfied by a failure, and tasks that override their
+// dependencies, don't exclude a task.
+func withoutTasksBlockedByFailedDependencies(tasks []task.Task) ([]task.Task, []string) {
+	failed := map[string]task.Task{}
+	for _, t := range tasks {
+		if t.Status == evergreen.TaskFailed {
+			failed[t.Id] = t
+		}
+	}
+	if len(failed) == 0 {
+		return tasks, nil
+	}
+
+	out := make([]task.Task, 0, len(tasks))
+	blocked := []string{}
+	for _, t := range tasks {
+		if isBlockedByFailedDependency(t, failed) {
+			blocked = append(blocked, t.Id)
+			continue
+		}
+		out = append(out, t)
+	}
+
+	return out, blocked
+}
+
+// isBlockedByFailedDependency returns whether any of the task's
+// dependencies is one of the failed tasks and requires a status other
+// than failure.
+func isBlockedByFailedDependency(t task.Task, failed map[string]task.Task) bool {
+	if t.OverrideDependencies {
+		return false
+	}
+
+	for _, dep := range t.DependsOn {
+		depTask, ok := failed[dep.TaskId]
+		if !ok {
+			continue
+		}
+		if !t.SatisfiesDependency(&depTask) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isValidTaskGroupKey returns whether the task has the version and
+// variant context of its task group key, without which the key could
+// refer to task groups with the same name in other versions or
+// variants.
+func isValidTaskGroupKey(t task.Task) bool {
+	return t.TaskGroup != "" && t.BuildVariant != "" && t.Version != ""
+}
+
+// makeUnitCache groups the tasks for a distro into units, returning
+// the cache of units for the tasks.
+func makeUnitCache(distro *distro.Distro, tasks []task.Task) UnitCache {
 	cache := UnitCache{}
 
 	for _, t := range tasks {
 		var unit *Unit
-		if t.TaskGroup != "" {
+		groupVersions := distro.PlannerSettings.ShouldGroupVersionsForRequester(t.Requester)
+		if t.TaskGroup != "" && !isValidTaskGroupKey(t) {
+			grip.Warning(message.Fields{
+				"message":    "task group key is malformed, planning task on its own",
+				"task_id":    t.Id,
+				"task_group": t.TaskGroup,
+				"key":        t.GetTaskGroupString(),
+				"distro":     distro.Id,
+			})
+			unit = cache.Create(t.Id, t)
+		} else if t.TaskGroup != "" {
 			unit = cache.Create(t.GetTaskGroupString(), t)
 			cache.AddNew(t.Id, unit)
-			cache.AddWhen(distro.PlannerSettings.ShouldGroupVersions(), t.Version, t)
-		} else if distro.PlannerSettings.ShouldGroupVersions() {
+			cache.AddWhen(groupVersions, t.Version, t)
+		} else if groupVersions {
 			unit = cache.Create(t.Version, t)
 			cache.AddNew(t.Id, unit)
 		} else {
@@ -403,35 +1555,216 @@ func PrepareTasksForPlanning(distro *distro.Distro, tasks []task.Task) TaskPlan
 		unit.SetDistro(distro)
 	}
 
+	cache.addDependents(tasks)
+
+	return cache
+}
+
+// makeUnitCacheBy groups the tasks for a distro into units by the key
+// that the function returns for each task, returning the cache of units
+// for the tasks.
+func makeUnitCacheBy(distro *distro.Distro, tasks []task.Task, keyFn func(task.Task) string) UnitCache {
+	cache := UnitCache{}
+
 	for _, t := range tasks {
-		// if it has dependencies:
-		if len(t.DependsOn) > 0 {
-			for _, dep := range t.DependsOn {
-				cache.AddWhen(cache.Exists(dep.TaskId), dep.TaskId, t)
-			}
+		key := keyFn(t)
+		if key == "" {
+			key = t.Id
 		}
+		unit := cache.Create(key, t)
+		if key != t.Id {
+			cache.AddNew(t.Id, unit)
+		}
+		unit.SetDistro(distro)
 	}
 
-	return cache.Export()
+	cache.addDependents(tasks)
+
+	return cache
+}
+
+// addDependents adds each task to the units of the tasks it depends on,
+// so that dependencies are planned along with their dependents.
+func (cache UnitCache) addDependents(tasks []task.Task) {
+	for _, t := range tasks {
+		for _, dep := range t.DependsOn {
+			cache.AddWhen(cache.Exists(dep.TaskId), dep.TaskId, t)
+		}
+	}
 }
 
 // Export sorts the TaskPlan returning a unique list of tasks.
 func (tpl TaskPlan) Export() []task.Task {
+	output := []task.Task{}
+	for _, group := range tpl.ExportGroups() {
+		output = append(output, group...)
+	}
+
+	return output
+}
+
+// ExportGroups sorts the TaskPlan and applies the registered plan
+// processors, returning the unique tasks of each unit as a separate,
+// ordered group, so that callers dispatching tasks can keep the tasks
+// of a unit together. A task that appears in more than one unit is only
+// included in the group for the highest-ranked unit, and units without
+// any remaining tasks are omitted.
+func (tpl TaskPlan) ExportGroups() [][]task.Task {
 	sort.Sort(tpl)
 
-	output := []task.Task{}
+	units := tpl
+	if len(tpl) > 0 && tpl[0].distro != nil {
+		units = tpl.reserveMainline(tpl[0].distro.PlannerSettings.GetMainlineReservationRatio())
+	}
+	units = units.process()
+
+	output := [][]task.Task{}
 	seen := StringSet{}
-	for _, unit := range tpl {
-		tasks := unit.Export()
-		sort.Sort(tasks)
+	for _, unit := range units {
+		tasks := unit.orderedTasks()
+
+		group := make([]task.Task, 0, len(tasks))
 		for _, t := range tasks {
 			if seen.Visit(t.Id) {
 				continue
 			}
 
-			output = append(output, t)
+			group = append(group, t)
+		}
+
+		if len(group) > 0 {
+			output = append(output, group)
 		}
 	}
 
 	return output
 }
+
+// reserveMainline returns the sorted plan reordered so that, for every
+// prefix of the plan, at least the given fraction of its units are
+// mainline units, for as long as mainline units remain. Otherwise,
+// units keep their order, so mainline units are interleaved into the
+// patch units rather than moved to the front of the plan.
+func (tpl TaskPlan) reserveMainline(ratio float64) TaskPlan {
+	if ratio <= 0 {
+		return tpl
+	}
+
+	// mainline and other hold the positions of the units in the
+	// sorted plan.
+	var mainline, other []int
+	for idx, unit := range tpl {
+		if unit.isMainline() {
+			mainline = append(mainline, idx)
+		} else {
+			other = append(other, idx)
+		}
+	}
+	if len(mainline) == 0 || len(other) == 0 {
+		return tpl
+	}
+
+	output := make(TaskPlan, 0, len(tpl))
+	numMainline := 0
+	for len(output) < len(tpl) {
+		numOther := len(output) - numMainline
+
+		var useMainline bool
+		switch {
+		case numMainline == len(mainline):
+			useMainline = false
+		case numOther == len(other):
+			useMainline = true
+		case numMainline < int(math.Floor(ratio*float64(len(output)+1))):
+			useMainline = true
+		default:
+			useMainline = mainline[numMainline] < other[numOther]
+		}
+
+		if useMainline {
+			output = append(output, tpl[mainline[numMainline]])
+			numMainline++
+		} else {
+			output = append(output, tpl[other[numOther]])
+		}
+	}
+
+	return output
+}
+
+// Validate checks that the exported plan is a permutation of the input
+// tasks: every input task must appear in the output exactly once, and
+// the output may not contain any task that was not in the input. It
+// exports a copy of the plan, so the plan itself isn't reordered.
+func (tpl TaskPlan) Validate(inputTasks []task.Task) error {
+	return validateExport(append(TaskPlan{}, tpl...).Export(), inputTasks)
+}
+
+// validateExport checks the exported tasks of a plan against the input
+// tasks, reporting each missing, duplicated, and extra task.
+func validateExport(output, inputTasks []task.Task) error {
+	expected := StringSet{}
+	for _, t := range inputTasks {
+		expected.Add(t.Id)
+	}
+
+	counts := map[string]int{}
+	for _, t := range output {
+		counts[t.Id]++
+	}
+
+	var missing, duplicated, extra []string
+	for id := range expected {
+		if counts[id] == 0 {
+			missing = append(missing, id)
+		}
+	}
+	for id, count := range counts {
+		if !expected.Check(id) {
+			extra = append(extra, id)
+		}
+		if count > 1 {
+			duplicated = append(duplicated, id)
+		}
+	}
+
+	if len(missing) == 0 && len(duplicated) == 0 && len(extra) == 0 {
+		return nil
+	}
+
+	sort.Strings(missing)
+	sort.Strings(duplicated)
+	sort.Strings(extra)
+
+	return errors.Errorf("plan does not match input tasks: missing [%s], duplicated [%s], extra [%s]",
+		strings.Join(missing, ", "), strings.Join(duplicated, ", "), strings.Join(extra, ", "))
+}
+
+// VerifyDependencyOrdering checks that the ranked plan never puts one of
+// the given tasks before a task that it depends on, which ranking units
+// independently of each other can do. It returns each violation as
+// "<dependent> -> <dependency>", sorted. Dependencies that aren't in the
+// plan are ignored. It checks the positions of the tasks in an exported
+// copy of the plan, so the plan itself isn't reordered.
+func (tpl TaskPlan) VerifyDependencyOrdering(tasks []task.Task) []string {
+	positions := map[string]int{}
+	for idx, t := range append(TaskPlan{}, tpl...).Export() {
+		positions[t.Id] = idx
+	}
+
+	var violations []string
+	for _, t := range tasks {
+		position, ok := positions[t.Id]
+		if !ok {
+			continue
+		}
+		for _, dep := range t.DependsOn {
+			if depPosition, ok := positions[dep.TaskId]; ok && depPosition > position {
+				violations = append(violations, fmt.Sprintf("%s -> %s", t.Id, dep.TaskId))
+			}
+		}
+	}
+	sort.Strings(violations)
+
+	return violations
+}
@@ -0,0 +1,192 @@
+package scheduler
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/evergreen-ci/evergreen"
+	"github.com/evergreen-ci/evergreen/model/distro"
+	"github.com/evergreen-ci/evergreen/model/task"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// plannerFixtureOptions describe the tasks generated by
+// makePlannerFixture. Percentages are exact when NumTasks is a multiple
+// of 100.
+type plannerFixtureOptions struct {
+	NumTasks    int
+	NumVersions int
+	// TaskGroupSize is the number of tasks in each task group.
+	TaskGroupSize int
+	// TaskGroupPercent is the percentage of tasks in a task group.
+	TaskGroupPercent int
+	// DependencyPercent is the percentage of tasks that depend on
+	// another task in the same version.
+	DependencyPercent int
+	// PatchPercent and CommitQueuePercent are the percentages of tasks
+	// from patches and from the commit queue. The rest are mainline
+	// tasks.
+	PatchPercent       int
+	CommitQueuePercent int
+}
+
+// defaultPlannerFixtureOptions are representative of a large distro's
+// queue.
+var defaultPlannerFixtureOptions = plannerFixtureOptions{
+	NumTasks:           10000,
+	NumVersions:        50,
+	TaskGroupSize:      4,
+	TaskGroupPercent:   20,
+	DependencyPercent:  30,
+	PatchPercent:       50,
+	CommitQueuePercent: 10,
+}
+
+// makePlannerFixture generates tasks for planning with the given
+// composition. The tasks are spread evenly across the versions, and the
+// output is deterministic.
+func makePlannerFixture(opts plannerFixtureOptions) []task.Task {
+	now := time.Now()
+	tasks := make([]task.Task, opts.NumTasks)
+	for i := range tasks {
+		t := task.Task{
+			Id:            fmt.Sprintf("task-%d", i),
+			Version:       fmt.Sprintf("version-%d", i%opts.NumVersions),
+			BuildVariant:  fmt.Sprintf("variant-%d", (i/opts.NumVersions)%5),
+			Project:       "project",
+			Activated:     true,
+			ActivatedTime: now.Add(-time.Duration(i%120) * time.Minute),
+			Priority:      int64(i % 3),
+		}
+		t.DurationPrediction.Value = time.Duration(1+i%30) * time.Minute
+		t.DurationPrediction.TTL = 24 * time.Hour
+		t.DurationPrediction.CollectedAt = now
+
+		switch bucket := i % 100; {
+		case bucket < opts.PatchPercent:
+			t.Requester = evergreen.PatchVersionRequester
+		case bucket < opts.PatchPercent+opts.CommitQueuePercent:
+			t.Requester = evergreen.MergeTestRequester
+		default:
+			t.Requester = evergreen.RepotrackerVersionRequester
+		}
+
+		// the multipliers are coprime with 100, so each percentage
+		// selects a different, evenly spread set of tasks.
+		if (i*7)%100 < opts.TaskGroupPercent {
+			t.TaskGroup = fmt.Sprintf("group-%d", i/(opts.NumVersions*opts.TaskGroupSize))
+			t.TaskGroupOrder = 1 + (i/opts.NumVersions)%opts.TaskGroupSize
+		}
+		if (i*13)%100 < opts.DependencyPercent {
+			// the next task in the same version.
+			t.DependsOn = []task.Dependency{{TaskId: fmt.Sprintf("task-%d", (i+opts.NumVersions)%opts.NumTasks)}}
+		}
+
+		tasks[i] = t
+	}
+
+	for _, t := range tasks {
+		for _, dep := range t.DependsOn {
+			var idx int
+			_, _ = fmt.Sscanf(dep.TaskId, "task-%d", &idx)
+			tasks[idx].NumDependents++
+		}
+	}
+
+	return tasks
+}
+
+func TestPlannerFixture(t *testing.T) {
+	opts := plannerFixtureOptions{
+		NumTasks:           1000,
+		NumVersions:        10,
+		TaskGroupSize:      4,
+		TaskGroupPercent:   20,
+		DependencyPercent:  30,
+		PatchPercent:       50,
+		CommitQueuePercent: 10,
+	}
+	tasks := makePlannerFixture(opts)
+	require.Len(t, tasks, opts.NumTasks)
+
+	versions := StringSet{}
+	ids := StringSet{}
+	var numInGroups, numWithDeps, numPatches, numCommitQueue, numMainline int
+	for _, tsk := range tasks {
+		versions.Add(tsk.Version)
+		assert.False(t, ids.Visit(tsk.Id))
+		if tsk.TaskGroup != "" {
+			numInGroups++
+		}
+		for _, dep := range tsk.DependsOn {
+			numWithDeps++
+			var idx int
+			_, err := fmt.Sscanf(dep.TaskId, "task-%d", &idx)
+			require.NoError(t, err)
+			assert.Equal(t, tsk.Version, tasks[idx].Version)
+		}
+		switch tsk.Requester {
+		case evergreen.PatchVersionRequester:
+			numPatches++
+		case evergreen.MergeTestRequester:
+			numCommitQueue++
+		case evergreen.RepotrackerVersionRequester:
+			numMainline++
+		}
+	}
+
+	assert.Len(t, versions, opts.NumVersions)
+	assert.Equal(t, 200, numInGroups)
+	assert.Equal(t, 300, numWithDeps)
+	assert.Equal(t, 500, numPatches)
+	assert.Equal(t, 100, numCommitQueue)
+	assert.Equal(t, 400, numMainline)
+}
+
+func BenchmarkPlanner(b *testing.B) {
+	groupVersions := true
+	d := &distro.Distro{
+		PlannerSettings:       distro.PlannerSettings{GroupVersions: &groupVersions},
+		HostAllocatorSettings: distro.HostAllocatorSettings{MaximumHosts: 100},
+	}
+	tasks := makePlannerFixture(defaultPlannerFixtureOptions)
+
+	b.Run("Grouping", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			makeUnitCache(d, tasks).Export()
+		}
+	})
+	b.Run("Ranking", func(b *testing.B) {
+		plan := makeUnitCache(d, tasks).Export()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for _, unit := range plan {
+				unit.invalidate()
+				unit.RankValue()
+			}
+		}
+	})
+	b.Run("Sorting", func(b *testing.B) {
+		plan := makeUnitCache(d, tasks).Export()
+		shuffled := make(TaskPlan, len(plan))
+		random := rand.New(rand.NewSource(1))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			copy(shuffled, plan)
+			random.Shuffle(len(shuffled), shuffled.Swap)
+			b.StartTimer()
+
+			sort.Sort(shuffled)
+		}
+	})
+	b.Run("PrepareAndExport", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			PrepareTasksForPlanning(d, tasks).Export()
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package scheduler
+
+import (
+	"sort"
+	"strings"
+)
+
+// UnitComparator compares two units for planning. It returns a negative
+// number if a should be planned before b, a positive number if b
+// should be planned before a, and 0 if it has no preference between
+// them.
+type UnitComparator func(a, b *Unit) int
+
+// defaultUnitComparator is the comparator that TaskPlan uses to order
+// its units.
+var defaultUnitComparator = ComposeUnitComparators(
+	ComparePriorityTier,
+	CompareRankValue,
+	CompareID,
+)
+
+// ComposeUnitComparators returns a comparator that applies each of the
+// comparators in order, returning the result of the first one that has
+// a preference between the units.
+func ComposeUnitComparators(comparators ...UnitComparator) UnitComparator {
+	return func(a, b *Unit) int {
+		for _, compare := range comparators {
+			if result := compare(a, b); result != 0 {
+				return result
+			}
+		}
+
+		return 0
+	}
+}
+
+// ComparePriorityTier orders units with higher priority tasks first when
+// the distro's planner settings use strict priority tiers, and has no
+// preference otherwise.
+func ComparePriorityTier(a, b *Unit) int {
+	if a.distro == nil || !a.distro.PlannerSettings.GetStrictPriorityTiers() {
+		return 0
+	}
+
+	return compareInt64Desc(a.maxPriority(), b.maxPriority())
+}
+
+// CompareRankValue orders units with higher RankValues first. When the
+// distro's planner settings have a rank value epsilon, it compares the
+// RankValues in buckets of the epsilon's width and has no preference
+// between units in the same bucket, so that small changes in RankValues
+// don't reorder the plan. Bucketing, rather than comparing the difference
+// to the epsilon, keeps the ordering transitive.
+func CompareRankValue(a, b *Unit) int {
+	aValue, bValue := a.RankValue(), b.RankValue()
+	if a.distro != nil {
+		if epsilon := a.distro.PlannerSettings.GetRankValueEpsilon(); epsilon > 0 {
+			aValue, bValue = rankValueBucket(aValue, epsilon), rankValueBucket(bValue, epsilon)
+		}
+	}
+
+	return compareInt64Desc(aValue, bValue)
+}
+
+// rankValueBucket returns the bucket of width epsilon that the value falls
+// into, rounding down so that buckets of negative values have the same
+// width as the rest.
+func rankValueBucket(value, epsilon int64) int64 {
+	bucket := value / epsilon
+	if value%epsilon < 0 {
+		bucket--
+	}
+
+	return bucket
+}
+
+// CompareID orders units by their IDs, which makes the order of units
+// that are otherwise equivalent deterministic.
+func CompareID(a, b *Unit) int {
+	return strings.Compare(a.ID(), b.ID())
+}
+
+func compareInt64Desc(a, b int64) int {
+	switch {
+	case a > b:
+		return -1
+	case a < b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// SortWith orders the units in the plan using the given comparator,
+// rather than the default ordering.
+func (tpl TaskPlan) SortWith(compare UnitComparator) {
+	sort.SliceStable(tpl, func(i, j int) bool { return compare(tpl[i], tpl[j]) < 0 })
+}
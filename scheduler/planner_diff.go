@@ -0,0 +1,72 @@
+package scheduler
+
+import (
+	"sort"
+
+	"github.com/evergreen-ci/evergreen/model/task"
+)
+
+// TaskRankChange describes how the position of a single task differs
+// between two exported plans.
+type TaskRankChange struct {
+	TaskID string `json:"task_id"`
+	// OldPosition is the index of the task in the old plan, or -1 if
+	// the task is not in the old plan.
+	OldPosition int `json:"old_position"`
+	// NewPosition is the index of the task in the new plan, or -1 if
+	// the task is not in the new plan.
+	NewPosition int `json:"new_position"`
+	// Delta is the change in position, where negative values indicate
+	// that the task moved toward the front of the plan. Delta is zero
+	// if the task is missing from either plan.
+	Delta int `json:"delta"`
+}
+
+// DiffPlans compares the output of two calls to TaskPlan.Export, and
+// returns the change in position of every task. Changes are ordered by
+// the task's position in the new plan, followed by the tasks that only
+// exist in the old plan.
+func DiffPlans(oldPlan, newPlan []task.Task) []TaskRankChange {
+	oldPositions := make(map[string]int, len(oldPlan))
+	for idx, t := range oldPlan {
+		if _, ok := oldPositions[t.Id]; !ok {
+			oldPositions[t.Id] = idx
+		}
+	}
+
+	out := make([]TaskRankChange, 0, len(newPlan))
+	seen := StringSet{}
+	for idx, t := range newPlan {
+		if seen.Visit(t.Id) {
+			continue
+		}
+
+		change := TaskRankChange{
+			TaskID:      t.Id,
+			OldPosition: -1,
+			NewPosition: idx,
+		}
+		if oldIdx, ok := oldPositions[t.Id]; ok {
+			change.OldPosition = oldIdx
+			change.Delta = idx - oldIdx
+		}
+
+		out = append(out, change)
+	}
+
+	removed := []TaskRankChange{}
+	for id, idx := range oldPositions {
+		if seen.Check(id) {
+			continue
+		}
+
+		removed = append(removed, TaskRankChange{
+			TaskID:      id,
+			OldPosition: idx,
+			NewPosition: -1,
+		})
+	}
+	sort.Slice(removed, func(i, j int) bool { return removed[i].OldPosition < removed[j].OldPosition })
+
+	return append(out, removed...)
+}
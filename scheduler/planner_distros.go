@@ -0,0 +1,100 @@
+package scheduler
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/evergreen-ci/evergreen/model/distro"
+	"github.com/evergreen-ci/evergreen/model/task"
+	"github.com/mongodb/grip"
+	"github.com/pkg/errors"
+)
+
+// PrepareAllDistros runs PrepareTasksForPlanning for the tasks of each
+// distro, returning the plans keyed by distro ID. It returns an error
+// without planning any distro if one of the distros is nil or more than
+// one distro has the same ID, since their plans can't be keyed by ID.
+func PrepareAllDistros(inputs map[*distro.Distro][]task.Task) (map[string]TaskPlan, error) {
+	return PrepareAllDistrosWithWorkers(inputs, 1)
+}
+
+// PrepareAllDistrosWithWorkers is the same as PrepareAllDistros, but
+// plans up to the given number of distros concurrently.
+func PrepareAllDistrosWithWorkers(inputs map[*distro.Distro][]task.Task, workers int) (map[string]TaskPlan, error) {
+	if err := validateDistroInputs(inputs); err != nil {
+		return nil, err
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		out = make(map[string]TaskPlan, len(inputs))
+	)
+	pool := make(chan struct{}, workers)
+	for d, tasks := range inputs {
+		wg.Add(1)
+		pool <- struct{}{}
+		go func(d *distro.Distro, tasks []task.Task) {
+			defer func() {
+				<-pool
+				wg.Done()
+			}()
+
+			plan := PrepareTasksForPlanning(d, tasks)
+
+			mu.Lock()
+			defer mu.Unlock()
+			out[d.Id] = plan
+		}(d, tasks)
+	}
+	wg.Wait()
+
+	return out, nil
+}
+
+// validateDistroInputs checks that none of the distros are nil and that
+// each of their IDs is unique.
+func validateDistroInputs(inputs map[*distro.Distro][]task.Task) error {
+	catcher := grip.NewBasicCatcher()
+	counts := map[string]int{}
+	for d := range inputs {
+		if d == nil {
+			catcher.New("cannot plan tasks for a nil distro")
+			continue
+		}
+		counts[d.Id]++
+	}
+
+	var duplicates []string
+	for id, count := range counts {
+		if count > 1 {
+			duplicates = append(duplicates, id)
+		}
+	}
+	sort.Strings(duplicates)
+	catcher.ErrorfWhen(len(duplicates) > 0, "cannot plan more than one distro with the same ID: %s", strings.Join(duplicates, ", "))
+
+	return errors.Wrap(catcher.Resolve(), "invalid distros")
+}
+
+// Distros returns the sorted, distinct IDs of the distros of the units in
+// the plan. Units without a distro are skipped.
+func (tpl TaskPlan) Distros() []string {
+	seen := StringSet{}
+	out := []string{}
+	for _, unit := range tpl {
+		if unit.distro == nil {
+			continue
+		}
+		if !seen.Visit(unit.distro.Id) {
+			out = append(out, unit.distro.Id)
+		}
+	}
+	sort.Strings(out)
+
+	return out
+}
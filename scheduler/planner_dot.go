@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ToDOT renders the plan as a Graphviz graph, to help debug how tasks
+// are folded into units. Each unit is a node labeled with its number
+// of tasks and rank value, with a cluster showing the order of the
+// unit's tasks, and edges point from units to the units with tasks
+// that depend on them. A task in more than one unit is attributed to
+// the first of its units in the plan when drawing edges.
+func (tpl TaskPlan) ToDOT() string {
+	var b strings.Builder
+	b.WriteString("digraph plan {\n")
+
+	taskUnits := map[string]string{}
+	for _, unit := range tpl {
+		for _, id := range unit.Keys() {
+			if _, ok := taskUnits[id]; !ok {
+				taskUnits[id] = unit.ID()
+			}
+		}
+	}
+
+	edges := StringSet{}
+	for idx, unit := range tpl {
+		unitID := unit.ID()
+		fmt.Fprintf(&b, "\t%q [label=%q];\n", unitID, fmt.Sprintf("%d tasks\nrank %d", len(unit.tasks), unit.RankValue()))
+
+		tasks := unit.orderedTasks()
+
+		fmt.Fprintf(&b, "\tsubgraph \"cluster_%d\" {\n", idx)
+		fmt.Fprintf(&b, "\t\tlabel=%q;\n", unitID)
+		for _, t := range tasks {
+			fmt.Fprintf(&b, "\t\t%q [label=%q];\n", unitID+"/"+t.Id, t.Id)
+		}
+		for i := 1; i < len(tasks); i++ {
+			fmt.Fprintf(&b, "\t\t%q -> %q;\n", unitID+"/"+tasks[i-1].Id, unitID+"/"+tasks[i].Id)
+		}
+		b.WriteString("\t}\n")
+
+		for _, t := range tasks {
+			for _, dep := range t.DependsOn {
+				depUnitID, ok := taskUnits[dep.TaskId]
+				if !ok || depUnitID == unitID {
+					continue
+				}
+				edges.Add(fmt.Sprintf("\t%q -> %q;\n", depUnitID, unitID))
+			}
+		}
+	}
+
+	sortedEdges := make([]string, 0, len(edges))
+	for edge := range edges {
+		sortedEdges = append(sortedEdges, edge)
+	}
+	sort.Strings(sortedEdges)
+	for _, edge := range sortedEdges {
+		b.WriteString(edge)
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -0,0 +1,232 @@
+package scheduler
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/evergreen-ci/evergreen/model/task"
+)
+
+// TotalExpectedRuntime returns the sum of the expected durations of all
+// tasks in the unit.
+func (unit *Unit) TotalExpectedRuntime() time.Duration {
+	return unit.info().TotalAverageRuntime
+}
+
+// EstimateMakespan returns an estimate of how long it will take to run
+// all of the units in the plan, assuming that the work is evenly
+// divided across the given number of hosts.
+func (tpl TaskPlan) EstimateMakespan(hostCount int) time.Duration {
+	return estimateMakespan(tpl, hostCount)
+}
+
+// Waves sorts the plan and partitions its units, in rank order, into
+// waves of at most hostsPerWave units, so that each wave can run at the
+// same time on that many hosts. Only the last wave may have fewer units.
+// If hostsPerWave is not positive, all of the units are in one wave.
+func (tpl TaskPlan) Waves(hostsPerWave int) [][]*Unit {
+	if len(tpl) == 0 {
+		return nil
+	}
+
+	sort.Sort(tpl)
+	if hostsPerWave < 1 {
+		return [][]*Unit{tpl}
+	}
+
+	waves := make([][]*Unit, 0, (len(tpl)+hostsPerWave-1)/hostsPerWave)
+	for start := 0; start < len(tpl); start += hostsPerWave {
+		end := start + hostsPerWave
+		if end > len(tpl) {
+			end = len(tpl)
+		}
+		waves = append(waves, tpl[start:end:end])
+	}
+
+	return waves
+}
+
+// EstimatedStartPosition sorts the plan and returns the position of the
+// unit containing the task, along with an estimate of how long it will
+// take before the task's unit starts, based on the expected runtimes of
+// the higher-ranked units divided across the given number of hosts. If
+// the task is not in the plan, the position is -1.
+func (tpl TaskPlan) EstimatedStartPosition(taskID string, hostCount int) (int, time.Duration) {
+	sort.Sort(tpl)
+
+	for idx, unit := range tpl {
+		if _, ok := unit.tasks[taskID]; ok {
+			return idx, estimatedStarts(tpl[:idx+1], hostCount)[idx]
+		}
+	}
+
+	return -1, 0
+}
+
+// SetDeadline sets the time by which the unit's tasks should finish,
+// which is used to flag units that are at risk of missing it. It doesn't
+// affect the unit's rank.
+func (unit *Unit) SetDeadline(deadline time.Time) {
+	unit.deadline = deadline
+}
+
+// AtRisk returns whether the unit was expected to finish after its
+// deadline when the plan was last flagged with FlagAtRisk.
+func (unit *Unit) AtRisk() bool {
+	return unit.atRisk
+}
+
+// FlagAtRisk sorts the plan and flags each unit with a deadline that is
+// expected to finish after it, given its estimated start in the plan
+// with the given number of hosts, so that hosts can be added before the
+// deadline is missed. Units without a deadline are never at risk.
+func (tpl TaskPlan) FlagAtRisk(hostCount int, now time.Time) {
+	sort.Sort(tpl)
+
+	starts := estimatedStarts(tpl, hostCount)
+	for idx, unit := range tpl {
+		unit.atRisk = !unit.deadline.IsZero() && len(unit.tasks) > 0 &&
+			now.Add(starts[idx]+unit.TotalExpectedRuntime()).After(unit.deadline)
+	}
+}
+
+// CriticalPath returns the unit that begins the longest chain of units
+// that depend on each other, along with the total expected runtime of
+// the units in the chain. A unit depends on another if any of its tasks
+// depend on a task in the other unit, and a task in more than one unit
+// is attributed to the first of its units in the plan. Dependency cycles
+// are broken where they're first found. Ties go to the unit that's
+// earliest in the plan, and an empty plan returns a nil unit.
+func (tpl TaskPlan) CriticalPath() (*Unit, time.Duration) {
+	taskUnits := map[string]int{}
+	for idx, unit := range tpl {
+		for id := range unit.tasks {
+			if _, ok := taskUnits[id]; !ok {
+				taskUnits[id] = idx
+			}
+		}
+	}
+
+	dependents := make([][]int, len(tpl))
+	for idx, unit := range tpl {
+		for _, t := range unit.tasks {
+			for _, dep := range t.DependsOn {
+				depIdx, ok := taskUnits[dep.TaskId]
+				if !ok || depIdx == idx {
+					continue
+				}
+				dependents[depIdx] = append(dependents[depIdx], idx)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(tpl))
+	lengths := make([]time.Duration, len(tpl))
+	var chainLength func(int) time.Duration
+	chainLength = func(idx int) time.Duration {
+		switch state[idx] {
+		case visiting:
+			return 0
+		case visited:
+			return lengths[idx]
+		}
+
+		state[idx] = visiting
+		var longest time.Duration
+		for _, next := range dependents[idx] {
+			if length := chainLength(next); length > longest {
+				longest = length
+			}
+		}
+		lengths[idx] = tpl[idx].TotalExpectedRuntime() + longest
+		state[idx] = visited
+
+		return lengths[idx]
+	}
+
+	var critical *Unit
+	var criticalLength time.Duration
+	for idx, unit := range tpl {
+		if length := chainLength(idx); critical == nil || length > criticalLength {
+			critical = unit
+			criticalLength = length
+		}
+	}
+
+	return critical, criticalLength
+}
+
+func estimateMakespan(units []*Unit, hostCount int) time.Duration {
+	if hostCount < 1 {
+		hostCount = 1
+	}
+
+	var total time.Duration
+	for _, unit := range units {
+		total += unit.TotalExpectedRuntime()
+	}
+
+	return total / time.Duration(hostCount)
+}
+
+// estimatedStarts returns an estimate of how long it will take before
+// each of the units starts, in order, based on the expected runtimes of
+// the units ahead of it divided across the given number of hosts.
+func estimatedStarts(units []*Unit, hostCount int) []time.Duration {
+	if hostCount < 1 {
+		hostCount = 1
+	}
+
+	starts := make([]time.Duration, 0, len(units))
+	var ahead time.Duration
+	for _, unit := range units {
+		starts = append(starts, ahead/time.Duration(hostCount))
+		ahead += unit.TotalExpectedRuntime()
+	}
+
+	return starts
+}
+
+// PlanGiniCoefficient measures how evenly the front of an ordered plan is
+// shared between groups of tasks, such as the tasks of each requester
+// or of each user, given a function that returns each task's group.
+// Each task scores higher the closer it is to the front of the plan,
+// and the result is the Gini coefficient of the groups' average
+// scores: 0 means every group has the same average position, and
+// values closer to 1 mean that some groups are consistently ahead of
+// others. Plans with fewer than two groups are perfectly fair.
+func PlanGiniCoefficient(plan []task.Task, groupOf func(task.Task) string) float64 {
+	totals := map[string]float64{}
+	counts := map[string]float64{}
+	for idx, t := range plan {
+		group := groupOf(t)
+		totals[group] += float64(len(plan) - idx)
+		counts[group]++
+	}
+	if len(totals) < 2 {
+		return 0
+	}
+
+	averages := make([]float64, 0, len(totals))
+	var sum float64
+	for group, total := range totals {
+		average := total / counts[group]
+		averages = append(averages, average)
+		sum += average
+	}
+
+	var diffs float64
+	for _, x := range averages {
+		for _, y := range averages {
+			diffs += math.Abs(x - y)
+		}
+	}
+
+	return diffs / (2 * float64(len(averages)) * sum)
+}
@@ -0,0 +1,98 @@
+package scheduler
+
+import (
+	"container/list"
+	"sync"
+)
+
+// unitIDCache is a bounded, least-recently-used cache of unit IDs,
+// keyed by the sorted IDs of the tasks in the unit. The cache makes it
+// possible to reuse hashes across units and plans with the same
+// composition.
+type unitIDCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	items    map[string]*list.Element
+	hits     int64
+	misses   int64
+}
+
+type unitIDCacheEntry struct {
+	key string
+	id  string
+}
+
+var unitIDs = &unitIDCache{
+	order: list.New(),
+	items: map[string]*list.Element{},
+}
+
+// SetUnitIDCacheCapacity sets the maximum number of unit IDs that are
+// cached across units and plans. A capacity of zero, which is the
+// default, disables the cache. Changing the capacity clears the cache.
+func SetUnitIDCacheCapacity(capacity int) {
+	unitIDs.mu.Lock()
+	defer unitIDs.mu.Unlock()
+
+	if capacity < 0 {
+		capacity = 0
+	}
+
+	unitIDs.capacity = capacity
+	unitIDs.order.Init()
+	unitIDs.items = map[string]*list.Element{}
+	unitIDs.hits = 0
+	unitIDs.misses = 0
+}
+
+func (c *unitIDCache) enabled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.capacity > 0
+}
+
+func (c *unitIDCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return "", false
+	}
+
+	c.hits++
+	c.order.MoveToFront(elem)
+	return elem.Value.(*unitIDCacheEntry).id, true
+}
+
+func (c *unitIDCache) put(key, id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.capacity <= 0 {
+		return
+	}
+
+	if elem, ok := c.items[key]; ok {
+		elem.Value.(*unitIDCacheEntry).id = id
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&unitIDCacheEntry{key: key, id: id})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*unitIDCacheEntry).key)
+	}
+}
+
+func (c *unitIDCache) stats() (hits, misses int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.hits, c.misses
+}
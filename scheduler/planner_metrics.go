@@ -0,0 +1,72 @@
+package scheduler
+
+import "sync/atomic"
+
+// RankValueMetrics reports counters for calls to Unit.RankValue, which
+// makes it possible to evaluate whether caching the computed rank
+// values is effective.
+type RankValueMetrics struct {
+	// Calls is the total number of calls to RankValue.
+	Calls int64 `json:"calls"`
+	// CacheHits is the number of calls that returned a cached value.
+	CacheHits int64 `json:"cache_hits"`
+	// Recomputations is the number of calls that computed the value.
+	Recomputations int64 `json:"recomputations"`
+	// InfoComputations is the number of times a unit's info, which
+	// its rank value and runtime estimates are computed from, was
+	// computed rather than read from the unit's cache.
+	InfoComputations int64 `json:"info_computations"`
+}
+
+type rankValueCounters struct {
+	enabled        atomic.Bool
+	calls          atomic.Int64
+	cacheHits      atomic.Int64
+	recomputations atomic.Int64
+	infoComputes   atomic.Int64
+}
+
+var rankValueStats rankValueCounters
+
+// EnableRankValueMetrics turns collection of the RankValue counters on
+// or off. Collection is disabled by default.
+func EnableRankValueMetrics(enabled bool) { rankValueStats.enabled.Store(enabled) }
+
+// GetRankValueMetrics returns a snapshot of the RankValue counters.
+func GetRankValueMetrics() RankValueMetrics {
+	return RankValueMetrics{
+		Calls:            rankValueStats.calls.Load(),
+		CacheHits:        rankValueStats.cacheHits.Load(),
+		Recomputations:   rankValueStats.recomputations.Load(),
+		InfoComputations: rankValueStats.infoComputes.Load(),
+	}
+}
+
+// ResetRankValueMetrics sets all of the RankValue counters to zero.
+func ResetRankValueMetrics() {
+	rankValueStats.calls.Store(0)
+	rankValueStats.cacheHits.Store(0)
+	rankValueStats.recomputations.Store(0)
+	rankValueStats.infoComputes.Store(0)
+}
+
+func (c *rankValueCounters) record(cacheHit bool) {
+	if !c.enabled.Load() {
+		return
+	}
+
+	c.calls.Add(1)
+	if cacheHit {
+		c.cacheHits.Add(1)
+	} else {
+		c.recomputations.Add(1)
+	}
+}
+
+func (c *rankValueCounters) recordInfoComputation() {
+	if !c.enabled.Load() {
+		return
+	}
+
+	c.infoComputes.Add(1)
+}
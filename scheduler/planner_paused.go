@@ -0,0 +1,73 @@
+package scheduler
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/evergreen-ci/evergreen/model/task"
+)
+
+// pausedTaskGroupSet is the set of task groups that are excluded from
+// planning, keyed by their task group strings.
+type pausedTaskGroupSet struct {
+	mu   sync.RWMutex
+	keys StringSet
+}
+
+var pausedTaskGroups = &pausedTaskGroupSet{keys: StringSet{}}
+
+// PauseTaskGroup excludes the tasks in the task group with the given
+// key, as returned by task.Task.GetTaskGroupString, from plans until the
+// task group is resumed. The tasks aren't modified, so they're planned
+// as usual once the task group is resumed.
+func PauseTaskGroup(key string) {
+	pausedTaskGroups.mu.Lock()
+	defer pausedTaskGroups.mu.Unlock()
+
+	pausedTaskGroups.keys.Add(key)
+}
+
+// ResumeTaskGroup includes the tasks in a paused task group in plans
+// again. Resuming a task group that isn't paused has no effect.
+func ResumeTaskGroup(key string) {
+	pausedTaskGroups.mu.Lock()
+	defer pausedTaskGroups.mu.Unlock()
+
+	delete(pausedTaskGroups.keys, key)
+}
+
+// PausedTaskGroups returns the sorted keys of the paused task groups.
+func PausedTaskGroups() []string {
+	pausedTaskGroups.mu.RLock()
+	defer pausedTaskGroups.mu.RUnlock()
+
+	keys := make([]string, 0, len(pausedTaskGroups.keys))
+	for key := range pausedTaskGroups.keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// withoutPausedTaskGroups returns the tasks that aren't in a paused task
+// group, along with the number of tasks that were excluded. The input
+// is returned as is if no tasks are excluded.
+func withoutPausedTaskGroups(tasks []task.Task) ([]task.Task, int) {
+	pausedTaskGroups.mu.RLock()
+	defer pausedTaskGroups.mu.RUnlock()
+
+	if len(pausedTaskGroups.keys) == 0 {
+		return tasks, 0
+	}
+
+	out := make([]task.Task, 0, len(tasks))
+	for _, t := range tasks {
+		if t.TaskGroup != "" && pausedTaskGroups.keys.Check(t.GetTaskGroupString()) {
+			continue
+		}
+		out = append(out, t)
+	}
+
+	return out, len(tasks) - len(out)
+}
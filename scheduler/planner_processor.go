@@ -0,0 +1,57 @@
+package scheduler
+
+import "sync"
+
+// PlanProcessor reorders a plan after it's been ranked, so that
+// reordering heuristics, such as reserving capacity for a project, can
+// be composed as separate stages rather than built into the rank value.
+type PlanProcessor interface {
+	// Process returns the units of the ranked plan in their new order.
+	// It may modify the order of the plan it's passed.
+	Process(TaskPlan) TaskPlan
+}
+
+// PlanProcessorFunc adapts a function to a PlanProcessor.
+type PlanProcessorFunc func(TaskPlan) TaskPlan
+
+// Process calls the function with the plan.
+func (f PlanProcessorFunc) Process(tpl TaskPlan) TaskPlan { return f(tpl) }
+
+// planProcessorRegistry is the list of processors that are applied to
+// every plan when it's exported.
+type planProcessorRegistry struct {
+	mu         sync.RWMutex
+	processors []PlanProcessor
+}
+
+var planProcessors = &planProcessorRegistry{}
+
+// RegisterPlanProcessor adds the processor to the end of the processors
+// that are applied, in the order they were registered, to every plan
+// when it's exported.
+func RegisterPlanProcessor(p PlanProcessor) {
+	planProcessors.mu.Lock()
+	defer planProcessors.mu.Unlock()
+
+	planProcessors.processors = append(planProcessors.processors, p)
+}
+
+// ClearPlanProcessors removes all of the registered plan processors.
+func ClearPlanProcessors() {
+	planProcessors.mu.Lock()
+	defer planProcessors.mu.Unlock()
+
+	planProcessors.processors = nil
+}
+
+// process applies the registered processors to the ranked plan in order.
+func (tpl TaskPlan) process() TaskPlan {
+	planProcessors.mu.RLock()
+	defer planProcessors.mu.RUnlock()
+
+	for _, p := range planProcessors.processors {
+		tpl = p.Process(tpl)
+	}
+
+	return tpl
+}
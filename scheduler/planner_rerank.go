@@ -0,0 +1,100 @@
+package scheduler
+
+import (
+	"sort"
+
+	"github.com/evergreen-ci/evergreen/model/distro"
+	"github.com/evergreen-ci/evergreen/model/task"
+)
+
+// Rerank returns the units of the plan in the order they would have if
+// their rank values were computed with the given planner settings,
+// without changing the plan or the units' cached rank values. It reuses
+// the information already computed for each unit, so it's a cheap way
+// to estimate the impact of changing the planner's factors. The units
+// are ordered with the same comparators, mainline reservation, and
+// registered plan processors as an exported plan. Settings that affect how tasks are grouped into
+// units or how their runtimes are estimated only take effect in a full
+// re-plan.
+func (tpl TaskPlan) Rerank(settings distro.PlannerSettings) TaskPlan {
+	// The comparators and processors read the settings from the units'
+	// distros, so rank copies of the units with copies of their
+	// distros that have the new settings.
+	distros := map[*distro.Distro]*distro.Distro{}
+	originals := make(map[*Unit]*Unit, len(tpl))
+	reranked := make(TaskPlan, 0, len(tpl))
+	for _, unit := range tpl {
+		d, ok := distros[unit.distro]
+		if !ok {
+			d = &distro.Distro{}
+			if unit.distro != nil {
+				*d = *unit.distro
+			}
+			d.PlannerSettings = settings
+			distros[unit.distro] = d
+		}
+
+		info := unit.info()
+		info.Settings = settings
+		clone := *unit
+		clone.distro = d
+		clone.cachedInfo = &info
+		clone.cachedValue = info.value()
+		clone.valueComputed = true
+
+		originals[&clone] = unit
+		reranked = append(reranked, &clone)
+	}
+
+	sort.Stable(reranked)
+	reranked = reranked.reserveMainline(settings.GetMainlineReservationRatio()).process()
+
+	out := make(TaskPlan, 0, len(reranked))
+	for _, clone := range reranked {
+		out = append(out, originals[clone])
+	}
+
+	return out
+}
+
+// WhatIfPriority returns the position of the highest-ranked unit
+// containing the task in the sorted plan, along with the position it
+// would have if the task's priority were changed to the given priority.
+// The task's priority is changed in copies of every unit that contains
+// it, so neither the plan's order nor its units change. If the task is
+// not in the plan, both positions are -1.
+func (tpl TaskPlan) WhatIfPriority(taskID string, newPriority int64) (int, int) {
+	current := make(TaskPlan, len(tpl))
+	copy(current, tpl)
+	proposed := make(TaskPlan, 0, len(tpl))
+	for _, unit := range tpl {
+		if _, ok := unit.tasks[taskID]; ok {
+			clone := *unit
+			clone.tasks = make(map[string]task.Task, len(unit.tasks))
+			for id, t := range unit.tasks {
+				clone.tasks[id] = t
+			}
+			t := clone.tasks[taskID]
+			t.Priority = newPriority
+			clone.tasks[taskID] = t
+			clone.invalidate()
+			unit = &clone
+		}
+		proposed = append(proposed, unit)
+	}
+
+	return current.position(taskID), proposed.position(taskID)
+}
+
+// position sorts the plan and returns the index of the first unit in it
+// that contains the task.
+func (tpl TaskPlan) position(taskID string) int {
+	sort.Sort(tpl)
+	for idx, unit := range tpl {
+		if _, ok := unit.tasks[taskID]; ok {
+			return idx
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,43 @@
+package scheduler
+
+import "sort"
+
+// UnitSnapshot describes a single unit in a plan, for callers that
+// want to inspect or log a plan without holding on to the units.
+type UnitSnapshot struct {
+	ID        string            `json:"id"`
+	TaskIDs   []string          `json:"task_ids"`
+	RankValue int64             `json:"rank_value"`
+	Tags      map[string]string `json:"tags,omitempty"`
+	// AtRisk indicates that the unit is expected to miss its deadline.
+	AtRisk bool `json:"at_risk,omitempty"`
+}
+
+// Snapshot returns a description of each unit in the plan, in the
+// plan's current order. Task IDs are sorted, and the tags are copied
+// so that later changes to the units don't affect the snapshot.
+func (tpl TaskPlan) Snapshot() []UnitSnapshot {
+	out := make([]UnitSnapshot, 0, len(tpl))
+	for _, unit := range tpl {
+		taskIDs := unit.Keys()
+		sort.Strings(taskIDs)
+
+		var tags map[string]string
+		if len(unit.Tags) > 0 {
+			tags = make(map[string]string, len(unit.Tags))
+			for key, value := range unit.Tags {
+				tags[key] = value
+			}
+		}
+
+		out = append(out, UnitSnapshot{
+			ID:        unit.ID(),
+			TaskIDs:   taskIDs,
+			RankValue: unit.RankValue(),
+			Tags:      tags,
+			AtRisk:    unit.atRisk,
+		})
+	}
+
+	return out
+}
@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"sync"
+
+	"github.com/evergreen-ci/evergreen/model/distro"
+	"github.com/evergreen-ci/evergreen/model/task"
+)
+
+// SyncUnitCache is a UnitCache that's safe to populate from multiple
+// goroutines. The units it returns aren't safe for concurrent use, so
+// callers shouldn't modify them until the cache is fully populated.
+type SyncUnitCache struct {
+	mu    sync.Mutex
+	cache UnitCache
+}
+
+// NewSyncUnitCache returns an empty SyncUnitCache.
+func NewSyncUnitCache() *SyncUnitCache {
+	return &SyncUnitCache{cache: UnitCache{}}
+}
+
+// AddWhen is the same as UnitCache.AddWhen.
+func (c *SyncUnitCache) AddWhen(cond bool, id string, t task.Task) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cache.AddWhen(cond, id, t)
+}
+
+// AddNew is the same as UnitCache.AddNew.
+func (c *SyncUnitCache) AddNew(id string, unit *Unit) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cache.AddNew(id, unit)
+}
+
+// Create is the same as UnitCache.Create. Since the unit may be shared
+// with other goroutines, use CreateWithDistro rather than setting the
+// distro of the unit it returns.
+func (c *SyncUnitCache) Create(id string, t task.Task) *Unit {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.cache.Create(id, t)
+}
+
+// CreateWithDistro is the same as Create, but also sets the distro of
+// the unit while the cache is locked.
+func (c *SyncUnitCache) CreateWithDistro(id string, t task.Task, d *distro.Distro) *Unit {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	unit := c.cache.Create(id, t)
+	unit.SetDistro(d)
+	return unit
+}
+
+// Exists is the same as UnitCache.Exists.
+func (c *SyncUnitCache) Exists(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.cache.Exists(key)
+}
+
+// Export is the same as UnitCache.Export.
+func (c *SyncUnitCache) Export() TaskPlan {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.cache.Export()
+}
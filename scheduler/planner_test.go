@@ -3,13 +3,20 @@ package scheduler
 import (
 	"context"
 	"fmt"
+	"math"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/evergreen-ci/evergreen"
 	"github.com/evergreen-ci/evergreen/model/distro"
 	"github.com/evergreen-ci/evergreen/model/task"
+	"github.com/evergreen-ci/utility"
+	"github.com/mongodb/grip"
+	"github.com/mongodb/grip/level"
+	"github.com/mongodb/grip/send"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -98,6 +105,60 @@ func TestPlanner(t *testing.T) {
 				cache.AddNew("foo", &Unit{})
 				assert.Len(t, cache, 1)
 			})
+			t.Run("MergeMatchesSingleBuild", func(t *testing.T) {
+				d := &distro.Distro{PlannerSettings: distro.PlannerSettings{GroupVersions: func() *bool { b := true; return &b }()}}
+				tasks := []task.Task{
+					{Id: "one", Version: "v1"},
+					{Id: "two", Version: "v1"},
+					{Id: "three", Version: "v2"},
+					{Id: "four", Version: "v2", TaskGroup: "tg", BuildVariant: "bv", Project: "p"},
+					{Id: "five", Version: "v2", TaskGroup: "tg", BuildVariant: "bv", Project: "p"},
+					{Id: "six", Version: "v3"},
+				}
+
+				merged := makeUnitCache(d, tasks[:3])
+				merged.Merge(makeUnitCache(d, tasks[3:]))
+				single := makeUnitCache(d, tasks)
+
+				require.Len(t, merged, len(single))
+				for key, unit := range single {
+					require.Contains(t, merged, key)
+					assert.ElementsMatch(t, unit.Keys(), merged[key].Keys())
+					assert.Equal(t, unit.ID(), merged[key].ID())
+					assert.Equal(t, d, merged[key].distro)
+				}
+				assert.ElementsMatch(t, single.Export().Keys(), merged.Export().Keys())
+			})
+			t.Run("MergeSetsMissingDistro", func(t *testing.T) {
+				cache := UnitCache{}
+				cache.Create("foo", task.Task{Id: "one"})
+				other := UnitCache{}
+				other.Create("foo", task.Task{Id: "two"}).SetDistro(&distro.Distro{})
+
+				cache.Merge(other)
+				require.Len(t, cache, 1)
+				assert.NotNil(t, cache["foo"].distro)
+				assert.ElementsMatch(t, []string{"one", "two"}, cache["foo"].Keys())
+			})
+			t.Run("Stats", func(t *testing.T) {
+				d := &distro.Distro{}
+				cache := UnitCache{}
+				shared := cache.Create("one", task.Task{Id: "one"})
+				shared.Add(task.Task{Id: "two"})
+				shared.Add(task.Task{Id: "three"})
+				shared.SetDistro(d)
+				cache.AddNew("two", shared)
+				cache.Create("four", task.Task{Id: "four"}).SetDistro(d)
+				cache.Create("five", task.Task{Id: "five"})
+
+				assert.Equal(t, UnitCacheStats{
+					NumEntries:       4,
+					NumUnits:         3,
+					NumWithoutDistro: 1,
+					LargestUnitSize:  3,
+				}, cache.Stats())
+				assert.Equal(t, UnitCacheStats{}, UnitCache{}.Stats())
+			})
 			t.Run("CreateNew", func(t *testing.T) {
 				cache := UnitCache{}
 				unit := cache.Create("foo", task.Task{Id: "foo"})
@@ -118,6 +179,123 @@ func TestPlanner(t *testing.T) {
 				cache.Create("one", one)
 				assert.Len(t, cache.Export(), 0)
 			})
+			t.Run("ExportStrictReportsMissingDistroTasks", func(t *testing.T) {
+				cache := UnitCache{}
+				missing := cache.Create("one", task.Task{Id: "one"})
+				cache.Create("two", task.Task{Id: "two"}).SetDistro(&distro.Distro{})
+				plan, err := cache.ExportStrict()
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), missing.ID())
+				require.Len(t, plan, 1)
+				assert.Equal(t, []string{"two"}, plan.Keys())
+			})
+			t.Run("ExportStrictWithDistros", func(t *testing.T) {
+				cache := UnitCache{}
+				cache.Create("one", task.Task{Id: "one"}).SetDistro(&distro.Distro{})
+				plan, err := cache.ExportStrict()
+				assert.NoError(t, err)
+				assert.Len(t, plan, 1)
+			})
+			t.Run("ExportMergesDistinctUnitsWithSameTasks", func(t *testing.T) {
+				cache := UnitCache{}
+				d := &distro.Distro{}
+				first := cache.Create("first", task.Task{Id: "one"})
+				first.SetDistro(d)
+				first.Add(task.Task{Id: "two"})
+				second := cache.Create("second", task.Task{Id: "two"})
+				second.SetDistro(d)
+				second.Add(task.Task{Id: "one", Priority: 5})
+				require.NotSame(t, first, second)
+				require.Equal(t, first.ID(), second.ID())
+
+				plan := cache.Export()
+				require.Len(t, plan, 1)
+				assert.ElementsMatch(t, []string{"one", "two"}, plan[0].Keys())
+			})
+			t.Run("ExportWithDiagnosticsReportsDroppedUnits", func(t *testing.T) {
+				cache := UnitCache{}
+				d := &distro.Distro{}
+				first := cache.Create("first", task.Task{Id: "one"})
+				first.SetDistro(d)
+				first.Add(task.Task{Id: "two"})
+				second := cache.Create("second", task.Task{Id: "two"})
+				second.SetDistro(d)
+				second.Add(task.Task{Id: "one"})
+				missing := cache.Create("three", task.Task{Id: "three"})
+				cache.Create("four", task.Task{Id: "four"}).SetDistro(d)
+
+				plan, reasons := cache.ExportWithDiagnostics()
+				assert.Len(t, plan, 2)
+				expected := []DropReason{
+					{UnitID: first.ID(), Reason: DropReasonDuplicate},
+					{UnitID: missing.ID(), Reason: DropReasonNilDistro},
+				}
+				sort.Slice(expected, func(i, j int) bool { return expected[i].UnitID < expected[j].UnitID })
+				assert.Equal(t, expected, reasons)
+
+				_, reasons = UnitCache{"four": cache["four"]}.ExportWithDiagnostics()
+				assert.Empty(t, reasons)
+			})
+			t.Run("SyncUnitCacheConcurrentPopulation", func(t *testing.T) {
+				const numWorkers = 8
+				const numTasks = 50
+				d := &distro.Distro{}
+				cache := NewSyncUnitCache()
+
+				var wg sync.WaitGroup
+				for worker := 0; worker < numWorkers; worker++ {
+					wg.Add(1)
+					go func(worker int) {
+						defer wg.Done()
+						for i := 0; i < numTasks; i++ {
+							shared := NewUnit(task.Task{Id: fmt.Sprintf("shared-%d-%d", worker, i)})
+							shared.SetDistro(d)
+							cache.AddNew("shared", shared)
+
+							own := NewUnit(task.Task{Id: fmt.Sprintf("own-%d-%d", worker, i)})
+							own.SetDistro(d)
+							cache.AddNew(fmt.Sprintf("worker-%d", worker), own)
+							cache.AddWhen(i%2 == 0, fmt.Sprintf("worker-%d", worker), task.Task{Id: fmt.Sprintf("extra-%d-%d", worker, i)})
+							assert.True(t, cache.Exists("shared"))
+						}
+					}(worker)
+				}
+				wg.Wait()
+
+				plan := cache.Export()
+				require.Len(t, plan, numWorkers+1)
+				numKeys := 0
+				for _, unit := range plan {
+					numKeys += len(unit.Keys())
+				}
+				assert.Equal(t, numWorkers*numTasks*2+numWorkers*numTasks/2, numKeys)
+				for worker := 0; worker < numWorkers; worker++ {
+					assert.True(t, cache.Exists(fmt.Sprintf("worker-%d", worker)))
+				}
+			})
+			t.Run("SyncUnitCacheConcurrentCreateWithDistro", func(t *testing.T) {
+				const numWorkers = 8
+				const numTasks = 50
+				d := &distro.Distro{}
+				cache := NewSyncUnitCache()
+
+				var wg sync.WaitGroup
+				for worker := 0; worker < numWorkers; worker++ {
+					wg.Add(1)
+					go func(worker int) {
+						defer wg.Done()
+						for i := 0; i < numTasks; i++ {
+							cache.CreateWithDistro("shared", task.Task{Id: fmt.Sprintf("shared-%d-%d", worker, i)}, d)
+						}
+					}(worker)
+				}
+				wg.Wait()
+
+				plan := cache.Export()
+				require.Len(t, plan, 1)
+				assert.Len(t, plan[0].Keys(), numWorkers*numTasks)
+				assert.Equal(t, d, plan[0].distro)
+			})
 			t.Run("ExportPropogatesTasks", func(t *testing.T) {
 				cache := UnitCache{}
 				one := task.Task{Id: "one"}
@@ -160,6 +338,60 @@ func TestPlanner(t *testing.T) {
 				unit.SetDistro(nil)
 				assert.NotNil(t, unit.distro)
 			})
+			t.Run("Tags", func(t *testing.T) {
+				unit := NewUnit(task.Task{Id: "foo"})
+				_, ok := unit.GetTag("bucket")
+				assert.False(t, ok)
+
+				unit.SetTag("bucket", "a")
+				value, ok := unit.GetTag("bucket")
+				assert.True(t, ok)
+				assert.Equal(t, "a", value)
+
+				unit.SetTag("bucket", "b")
+				value, _ = unit.GetTag("bucket")
+				assert.Equal(t, "b", value)
+			})
+			t.Run("TagsMergeKeepsExistingValues", func(t *testing.T) {
+				cache := UnitCache{}
+				first := NewUnit(task.Task{Id: "one"})
+				first.SetTag("bucket", "a")
+				cache.AddNew("foo", first)
+
+				second := NewUnit(task.Task{Id: "two"})
+				second.SetTag("bucket", "b")
+				second.SetTag("cost_center", "ci")
+				cache.AddNew("foo", second)
+
+				assert.Equal(t, map[string]string{"bucket": "a", "cost_center": "ci"}, cache["foo"].Tags)
+				assert.Equal(t, map[string]string{"bucket": "b", "cost_center": "ci"}, second.Tags)
+			})
+			t.Run("TagsPreservedThroughExport", func(t *testing.T) {
+				cache := UnitCache{}
+				d := &distro.Distro{}
+				first := cache.Create("first", task.Task{Id: "one"})
+				first.SetDistro(d)
+				first.Add(task.Task{Id: "two"})
+				second := cache.Create("second", task.Task{Id: "two"})
+				second.SetDistro(d)
+				second.Add(task.Task{Id: "one"})
+				second.SetTag("bucket", "b")
+
+				plan := cache.Export()
+				require.Len(t, plan, 1)
+				value, ok := plan[0].GetTag("bucket")
+				assert.True(t, ok)
+				assert.Equal(t, "b", value)
+
+				snapshot := plan.Snapshot()
+				require.Len(t, snapshot, 1)
+				assert.Equal(t, plan[0].ID(), snapshot[0].ID)
+				assert.Equal(t, []string{"one", "two"}, snapshot[0].TaskIDs)
+				assert.Equal(t, map[string]string{"bucket": "b"}, snapshot[0].Tags)
+
+				plan[0].SetTag("bucket", "c")
+				assert.Equal(t, "b", snapshot[0].Tags["bucket"])
+			})
 			t.Run("AddOverwrites", func(t *testing.T) {
 				unit := NewUnit(task.Task{Id: "foo", Priority: 100})
 				assert.EqualValues(t, unit.tasks["foo"].Priority, 100)
@@ -193,6 +425,42 @@ func TestPlanner(t *testing.T) {
 
 				assert.Equal(t, unitOne.ID(), unitTwo.ID())
 			})
+			t.Run("HashCacheAcrossUnits", func(t *testing.T) {
+				SetUnitIDCacheCapacity(2)
+				defer SetUnitIDCacheCapacity(0)
+
+				first := NewUnit(task.Task{Id: "one"})
+				first.Add(task.Task{Id: "two"})
+				second := NewUnit(task.Task{Id: "two"})
+				second.Add(task.Task{Id: "one"})
+				other := NewUnit(task.Task{Id: "three"})
+
+				assert.Equal(t, first.ID(), second.ID())
+				hits, misses := unitIDs.stats()
+				assert.EqualValues(t, 1, hits)
+				assert.EqualValues(t, 1, misses)
+
+				assert.NotEqual(t, first.ID(), other.ID())
+				hits, misses = unitIDs.stats()
+				assert.EqualValues(t, 1, hits)
+				assert.EqualValues(t, 2, misses)
+
+				uncached := &Unit{tasks: map[string]task.Task{"one": {Id: "one"}, "two": {Id: "two"}}}
+				SetUnitIDCacheCapacity(0)
+				assert.Equal(t, first.ID(), uncached.ID())
+			})
+			t.Run("HashCacheEvictsLeastRecentlyUsed", func(t *testing.T) {
+				SetUnitIDCacheCapacity(2)
+				defer SetUnitIDCacheCapacity(0)
+
+				for _, id := range []string{"one", "two", "one", "three"} {
+					NewUnit(task.Task{Id: id}).ID()
+				}
+				assert.Len(t, unitIDs.items, 2)
+				assert.Contains(t, unitIDs.items, "one")
+				assert.Contains(t, unitIDs.items, "three")
+				assert.NotContains(t, unitIDs.items, "two")
+			})
 			t.Run("RankExpectedValues", func(t *testing.T) {
 				t.Run("SingleTask", func(t *testing.T) {
 					unit := NewUnit(task.Task{Id: "foo"})
@@ -215,6 +483,22 @@ func TestPlanner(t *testing.T) {
 					unit.SetDistro(&distro.Distro{})
 					assert.EqualValues(t, 2413, unit.RankValue())
 				})
+				t.Run("CommitQueueOverPatchMargin", func(t *testing.T) {
+					for _, margin := range []int64{0, 50, 500} {
+						d := &distro.Distro{PlannerSettings: distro.PlannerSettings{CommitQueueOverPatchMargin: margin}}
+						commitQueue := NewUnit(task.Task{Id: "foo", Requester: evergreen.MergeTestRequester})
+						commitQueue.SetDistro(d)
+						patch := NewUnit(task.Task{Id: "bar", Requester: evergreen.PatchVersionRequester})
+						patch.SetDistro(d)
+
+						// the margin is added to the priority, which
+						// multiplies the commit queue, base, and
+						// expected runtime terms.
+						expectedMargin := d.PlannerSettings.GetCommitQueueOverPatchMargin() * 12
+						assert.EqualValues(t, 13, patch.RankValue())
+						assert.EqualValues(t, expectedMargin, commitQueue.RankValue()-patch.RankValue())
+					}
+				})
 				t.Run("Patches", func(t *testing.T) {
 					t.Run("CLI", func(t *testing.T) {
 						unit := NewUnit(task.Task{Id: "foo", Requester: evergreen.PatchVersionRequester})
@@ -229,6 +513,28 @@ func TestPlanner(t *testing.T) {
 						assert.EqualValues(t, 22, unit.RankValue())
 					})
 				})
+				t.Run("RequesterPatchFactors", func(t *testing.T) {
+					d := &distro.Distro{
+						PlannerSettings: distro.PlannerSettings{
+							RequesterPatchFactors: map[string]int64{evergreen.GithubPRRequester: 10},
+						},
+					}
+					cli := NewUnit(task.Task{Id: "foo", Requester: evergreen.PatchVersionRequester})
+					cli.SetDistro(d)
+					github := NewUnit(task.Task{Id: "bar", Requester: evergreen.GithubPRRequester})
+					github.SetDistro(d)
+					assert.EqualValues(t, 13, cli.RankValue())
+					assert.EqualValues(t, 22, github.RankValue())
+
+					t.Run("PredominantRequester", func(t *testing.T) {
+						mixed := MakeUnit(d)
+						mixed.Add(task.Task{Id: "foo", Requester: evergreen.PatchVersionRequester})
+						mixed.Add(task.Task{Id: "bar", Requester: evergreen.GithubPRRequester})
+						mixed.Add(task.Task{Id: "baz", Requester: evergreen.GithubPRRequester})
+						info := mixed.info()
+						assert.Equal(t, evergreen.GithubPRRequester, info.PatchRequester)
+					})
+				})
 				t.Run("Priority", func(t *testing.T) {
 					unit := NewUnit(task.Task{Id: "foo", Priority: 10})
 					unit.SetDistro(&distro.Distro{})
@@ -239,6 +545,17 @@ func TestPlanner(t *testing.T) {
 					unit.SetDistro(&distro.Distro{})
 					assert.EqualValues(t, 73, unit.RankValue())
 				})
+				t.Run("TimeInQueueFixedClock", func(t *testing.T) {
+					now := time.Date(2023, time.October, 1, 12, 0, 0, 0, time.UTC)
+					defer func(original func() time.Time) { nowFunc = original }(nowFunc)
+					nowFunc = func() time.Time { return now }
+
+					unit := NewUnit(task.Task{Id: "foo", Requester: evergreen.PatchVersionRequester, ActivatedTime: now.Add(-90 * time.Minute)})
+					unit.SetDistro(&distro.Distro{})
+					info := unit.info()
+					assert.Equal(t, 90*time.Minute, info.TimeInQueue)
+					assert.EqualValues(t, 103, unit.RankValue())
+				})
 				t.Run("TimeInQueueMainline", func(t *testing.T) {
 					unit := NewUnit(task.Task{Id: "foo", Requester: evergreen.RepotrackerVersionRequester, ActivatedTime: time.Now().Add(-time.Hour)})
 					unit.SetDistro(&distro.Distro{})
@@ -264,6 +581,137 @@ func TestPlanner(t *testing.T) {
 					unit.SetDistro(&distro.Distro{})
 					assert.EqualValues(t, 182, unit.RankValue())
 				})
+				t.Run("DeactivatedTasks", func(t *testing.T) {
+					buildUnit := func(exclude bool) *Unit {
+						unit := NewUnit(task.Task{Id: "active", Activated: true})
+						unit.Add(task.Task{Id: "deactivated-one", Priority: 50, NumDependents: 1})
+						unit.Add(task.Task{Id: "deactivated-two", Priority: 50, ActivatedTime: time.Now().Add(-time.Hour)})
+						unit.SetDistro(&distro.Distro{PlannerSettings: distro.PlannerSettings{ExcludeDeactivatedTasks: &exclude}})
+						return unit
+					}
+					assert.EqualValues(t, 6055, buildUnit(false).RankValue())
+					assert.EqualValues(t, 181, buildUnit(true).RankValue())
+				})
+				t.Run("OnlyDeactivatedTasks", func(t *testing.T) {
+					exclude := true
+					unit := NewUnit(task.Task{Id: "foo", NumDependents: 2})
+					unit.SetDistro(&distro.Distro{PlannerSettings: distro.PlannerSettings{ExcludeDeactivatedTasks: &exclude}})
+					assert.EqualValues(t, 2, unit.RankValue())
+				})
+				t.Run("SchedulingWeight", func(t *testing.T) {
+					unit := NewUnit(task.Task{Id: "foo", NumDependents: 2, SchedulingWeight: 3})
+					unit.SetDistro(&distro.Distro{})
+					assert.EqualValues(t, 206, unit.RankValue())
+				})
+				t.Run("SchedulingWeightOnlyScalesWeightedTask", func(t *testing.T) {
+					unit := NewUnit(task.Task{Id: "foo", SchedulingWeight: 3})
+					unit.Add(task.Task{Id: "bar"})
+					unit.SetDistro(&distro.Distro{})
+					assert.EqualValues(t, 191, unit.RankValue())
+				})
+			})
+			t.Run("EffectivePriority", func(t *testing.T) {
+				d := &distro.Distro{PlannerSettings: distro.PlannerSettings{GenerateTaskFactor: 5}}
+				t.Run("Average", func(t *testing.T) {
+					unit := MakeUnit(d)
+					unit.Add(task.Task{Id: "foo", Priority: 10})
+					unit.Add(task.Task{Id: "bar", Priority: 20})
+					assert.EqualValues(t, 16, unit.EffectivePriority())
+				})
+				t.Run("TaskGroupBump", func(t *testing.T) {
+					unit := MakeUnit(d)
+					unit.Add(task.Task{Id: "foo", Priority: 10, TaskGroup: "tg"})
+					unit.Add(task.Task{Id: "bar", Priority: 20, TaskGroup: "tg"})
+					assert.EqualValues(t, 18, unit.EffectivePriority())
+				})
+				t.Run("GeneratorMultiply", func(t *testing.T) {
+					unit := MakeUnit(d)
+					unit.Add(task.Task{Id: "foo", Priority: 10, GenerateTask: true})
+					assert.EqualValues(t, 55, unit.EffectivePriority())
+				})
+				t.Run("TaskGroupGenerator", func(t *testing.T) {
+					unit := MakeUnit(d)
+					unit.Add(task.Task{Id: "foo", Priority: 10, TaskGroup: "tg", GenerateTask: true})
+					assert.EqualValues(t, 60, unit.EffectivePriority())
+				})
+				t.Run("NoTasks", func(t *testing.T) {
+					assert.EqualValues(t, 1, MakeUnit(d).EffectivePriority())
+				})
+			})
+			t.Run("DominantFactor", func(t *testing.T) {
+				old := time.Now().Add(-8 * 24 * time.Hour)
+				for name, test := range map[string]struct {
+					tasks    []task.Task
+					settings distro.PlannerSettings
+					poolSize int
+				}{
+					RankFactorPatch: {
+						tasks:    []task.Task{{Id: "foo", Requester: evergreen.PatchVersionRequester}},
+						settings: distro.PlannerSettings{PatchFactor: 100},
+					},
+					RankFactorPatchTimeInQueue: {
+						tasks: []task.Task{{Id: "foo", Requester: evergreen.PatchVersionRequester, ActivatedTime: time.Now().Add(-time.Hour)}},
+					},
+					RankFactorCommitQueue: {
+						tasks:    []task.Task{{Id: "foo", Requester: evergreen.MergeTestRequester}},
+						settings: distro.PlannerSettings{CommitQueueFactor: 20},
+					},
+					RankFactorMainlineTimeInQueue: {
+						tasks: []task.Task{{Id: "foo"}},
+					},
+					RankFactorStepback: {
+						tasks:    []task.Task{{Id: "foo", ActivatedTime: old, ActivatedBy: evergreen.StepbackTaskActivator}},
+						settings: distro.PlannerSettings{StepbackTaskFactor: 50},
+					},
+					RankFactorNumDeps: {
+						tasks: []task.Task{{Id: "foo", ActivatedTime: old, NumDependents: 100}},
+					},
+					RankFactorExpectedRuntime: {
+						tasks: []task.Task{{Id: "foo", ActivatedTime: old}},
+					},
+					RankFactorPriority: {
+						tasks:    []task.Task{{Id: "foo", ActivatedTime: old, Priority: 10}},
+						poolSize: 1,
+					},
+					RankFactorLength: {
+						tasks: []task.Task{
+							{Id: "foo", ActivatedTime: old},
+							{Id: "bar", ActivatedTime: old},
+							{Id: "baz", ActivatedTime: old},
+						},
+						poolSize: 1,
+					},
+				} {
+					t.Run(name, func(t *testing.T) {
+						unit := MakeUnit(&distro.Distro{
+							PlannerSettings:       test.settings,
+							HostAllocatorSettings: distro.HostAllocatorSettings{MaximumHosts: test.poolSize},
+						})
+						for _, tsk := range test.tasks {
+							unit.Add(tsk)
+						}
+						assert.Equal(t, name, unit.DominantFactor())
+					})
+				}
+			})
+			t.Run("ProjectPriority", func(t *testing.T) {
+				d := &distro.Distro{PlannerSettings: distro.PlannerSettings{ProjectPriorityFactor: 10}}
+				important := NewUnit(task.Task{Id: "foo", Project: "important"})
+				important.SetDistro(d)
+				other := NewUnit(task.Task{Id: "bar", Project: "other"})
+				other.SetDistro(d)
+				require.Equal(t, important.RankValue(), other.RankValue())
+
+				plan := TaskPlan{other, important}
+				plan.SetProjectPriorities(map[string]int64{"important": 2})
+				assert.EqualValues(t, 2, important.projectPriority)
+				assert.Zero(t, other.projectPriority)
+				assert.Equal(t, other.RankValue()+20, important.RankValue())
+
+				out := plan.Export()
+				require.Len(t, out, 2)
+				assert.Equal(t, "foo", out[0].Id)
+				assert.Equal(t, "bar", out[1].Id)
 			})
 			t.Run("RankCachesValue", func(t *testing.T) {
 				unit := NewUnit(task.Task{Id: "foo", Priority: 100})
@@ -273,6 +721,170 @@ func TestPlanner(t *testing.T) {
 				unit.Add(task.Task{Id: "bar"})
 				assert.EqualValues(t, 18080, unit.RankValue())
 			})
+			t.Run("SaturatingDurationSums", func(t *testing.T) {
+				now := time.Now()
+				unit := MakeUnit(&distro.Distro{})
+				for i := 0; i < 3; i++ {
+					tsk := task.Task{
+						Id:            fmt.Sprint("long", i),
+						Requester:     evergreen.PatchVersionRequester,
+						ActivatedTime: now.Add(-time.Duration(math.MaxInt64 / 2)),
+					}
+					tsk.DurationPrediction.Value = time.Duration(math.MaxInt64 / 2)
+					tsk.DurationPrediction.TTL = 24 * time.Hour
+					tsk.DurationPrediction.CollectedAt = now
+					unit.Add(tsk)
+				}
+
+				info := unit.info()
+				assert.Equal(t, time.Duration(math.MaxInt64), info.ExpectedRuntime)
+				assert.Equal(t, time.Duration(math.MaxInt64), info.TimeInQueue)
+				assert.Positive(t, unit.RankValue())
+
+				assert.Equal(t, time.Duration(math.MinInt64), addDurationSaturating(math.MinInt64+1, -2))
+				assert.Equal(t, 3*time.Second, addDurationSaturating(time.Second, 2*time.Second))
+				assert.Equal(t, time.Duration(math.MaxInt64), scaleDurationSaturating(math.MaxInt64/2, 3))
+			})
+			t.Run("TimeInQueueCurves", func(t *testing.T) {
+				ages := []float64{1, 2, 60, 120, 1000}
+				for _, curve := range distro.ValidTimeInQueueCurves {
+					t.Run(curve, func(t *testing.T) {
+						info := unitInfo{Settings: distro.PlannerSettings{TimeInQueueCurve: curve}}
+						values := make([]float64, 0, len(ages))
+						for _, age := range ages {
+							values = append(values, info.timeInQueueCurve(age))
+						}
+						for i := 1; i < len(values); i++ {
+							assert.Greater(t, values[i], values[i-1], "contribution should grow with time in queue")
+						}
+						// compare the growth per minute of waiting
+						// between a short wait and a long one.
+						shortSlope := (values[1] - values[0]) / (ages[1] - ages[0])
+						longSlope := (values[3] - values[2]) / (ages[3] - ages[2])
+						if curve == distro.TimeInQueueCurveLinear {
+							assert.InDelta(t, shortSlope, longSlope, 0.001)
+						} else {
+							assert.Greater(t, shortSlope, longSlope, "each minute of a long wait should matter less than each minute of a short one")
+						}
+					})
+				}
+				t.Run("SublinearCurvesSaturate", func(t *testing.T) {
+					sqrt := unitInfo{Settings: distro.PlannerSettings{TimeInQueueCurve: distro.TimeInQueueCurveSqrt}}
+					log := unitInfo{Settings: distro.PlannerSettings{TimeInQueueCurve: distro.TimeInQueueCurveLog}}
+					for _, age := range ages[1:] {
+						assert.Less(t, sqrt.timeInQueueCurve(age), age)
+						assert.Less(t, log.timeInQueueCurve(age), sqrt.timeInQueueCurve(age))
+					}
+				})
+				t.Run("RankValue", func(t *testing.T) {
+					now := time.Now()
+					rankValue := func(curve string, age time.Duration) int64 {
+						unit := MakeUnit(&distro.Distro{PlannerSettings: distro.PlannerSettings{TimeInQueueCurve: curve}})
+						unit.Add(task.Task{Id: "patch", Requester: evergreen.PatchVersionRequester, ActivatedTime: now.Add(-age)})
+						return unit.RankValue()
+					}
+					for _, curve := range distro.ValidTimeInQueueCurves {
+						assert.Less(t, rankValue(curve, time.Minute), rankValue(curve, time.Hour), curve)
+					}
+					assert.Greater(t, rankValue(distro.TimeInQueueCurveLinear, time.Hour), rankValue(distro.TimeInQueueCurveSqrt, time.Hour))
+					assert.Greater(t, rankValue(distro.TimeInQueueCurveSqrt, time.Hour), rankValue(distro.TimeInQueueCurveLog, time.Hour))
+				})
+			})
+			t.Run("PlanGiniCoefficient", func(t *testing.T) {
+				byUser := func(t task.Task) string { return t.ActivatedBy }
+				makePlan := func(users ...string) []task.Task {
+					plan := make([]task.Task, 0, len(users))
+					for idx, user := range users {
+						plan = append(plan, task.Task{Id: fmt.Sprint("t", idx), ActivatedBy: user})
+					}
+					return plan
+				}
+
+				skewed := PlanGiniCoefficient(makePlan("a", "a", "a", "a", "a", "b", "b", "b", "b", "b"), byUser)
+				balanced := PlanGiniCoefficient(makePlan("a", "b", "a", "b", "a", "b", "a", "b", "a", "b"), byUser)
+				assert.InDelta(t, 10.0/44, skewed, 0.0001)
+				assert.InDelta(t, 2.0/44, balanced, 0.0001)
+				assert.Greater(t, skewed, balanced)
+
+				assert.Zero(t, PlanGiniCoefficient(makePlan("a", "a", "a"), byUser))
+				assert.Zero(t, PlanGiniCoefficient(nil, byUser))
+			})
+			t.Run("RankValueMetrics", func(t *testing.T) {
+				ResetRankValueMetrics()
+				defer ResetRankValueMetrics()
+
+				unit := NewUnit(task.Task{Id: "foo"})
+				unit.SetDistro(&distro.Distro{})
+
+				t.Run("DisabledByDefault", func(t *testing.T) {
+					unit.RankValue()
+					assert.Zero(t, GetRankValueMetrics())
+				})
+
+				EnableRankValueMetrics(true)
+				defer EnableRankValueMetrics(false)
+
+				unit.valueComputed = false
+				unit.RankValue()
+				assert.Equal(t, RankValueMetrics{Calls: 1, Recomputations: 1}, GetRankValueMetrics())
+
+				unit.RankValue()
+				assert.Equal(t, RankValueMetrics{Calls: 2, CacheHits: 1, Recomputations: 1}, GetRankValueMetrics())
+
+				unit.valueComputed = false
+				unit.RankValue()
+				unit.RankValue()
+				assert.Equal(t, RankValueMetrics{Calls: 4, CacheHits: 2, Recomputations: 2}, GetRankValueMetrics())
+
+				// Rank values that aren't positive are cached too.
+				unit.cachedValue = -1
+				assert.EqualValues(t, -1, unit.RankValue())
+				assert.Equal(t, RankValueMetrics{Calls: 5, CacheHits: 3, Recomputations: 2}, GetRankValueMetrics())
+			})
+			t.Run("InfoComputedOnce", func(t *testing.T) {
+				ResetRankValueMetrics()
+				defer ResetRankValueMetrics()
+				EnableRankValueMetrics(true)
+				defer EnableRankValueMetrics(false)
+
+				unit := MakeUnit(&distro.Distro{})
+				unit.Add(task.Task{Id: "foo", Priority: 1, ExpectedDuration: time.Minute})
+				unit.RankValue()
+				unit.EffectivePriority()
+				unit.DominantFactor()
+				assert.Equal(t, time.Minute, unit.TotalExpectedRuntime())
+				assert.EqualValues(t, 1, GetRankValueMetrics().InfoComputations)
+
+				unit.Add(task.Task{Id: "bar", Priority: 1, ExpectedDuration: time.Minute})
+				assert.Equal(t, 2*time.Minute, unit.TotalExpectedRuntime())
+				unit.RankValue()
+				unit.DominantFactor()
+				assert.EqualValues(t, 2, GetRankValueMetrics().InfoComputations)
+			})
+			t.Run("RequesterClassificationCached", func(t *testing.T) {
+				calls := 0
+				defer func(original func(string) requesterClass) { classifyRequester = original }(classifyRequester)
+				original := classifyRequester
+				classifyRequester = func(requester string) requesterClass {
+					calls++
+					return original(requester)
+				}
+
+				unit := MakeUnit(&distro.Distro{})
+				unit.Add(task.Task{Id: "one", Requester: evergreen.PatchVersionRequester})
+				unit.Add(task.Task{Id: "two", Requester: evergreen.GithubPRRequester})
+				unit.Add(task.Task{Id: "three", Requester: evergreen.RepotrackerVersionRequester})
+				plan := TaskPlan{unit}
+				for i := 0; i < 3; i++ {
+					plan.SetIdleHosts(i)
+					assert.True(t, unit.info().ContainsInPatch)
+				}
+				assert.Equal(t, 3, calls)
+
+				unit.Add(task.Task{Id: "four", Requester: evergreen.MergeTestRequester})
+				assert.True(t, unit.info().ContainsInCommitQueue)
+				assert.Equal(t, 7, calls)
+			})
 			t.Run("RankForCommitQueue", func(t *testing.T) {
 				unit := NewUnit(task.Task{Id: "foo", Requester: evergreen.MergeTestRequester})
 				unit.SetDistro(&distro.Distro{})
@@ -305,6 +917,1090 @@ func TestPlanner(t *testing.T) {
 				plan := buildPlan(NewUnit(task.Task{Id: "foo"}), NewUnit(task.Task{Id: "foo"}))
 				assert.Len(t, plan.Export(), 1)
 			})
+			t.Run("Normalize", func(t *testing.T) {
+				d := &distro.Distro{}
+				first := NewUnit(task.Task{Id: "one"})
+				first.SetDistro(d)
+				second := NewUnit(task.Task{Id: "one", Priority: 5})
+				second.SetDistro(d)
+				other := NewUnit(task.Task{Id: "two"})
+				other.SetDistro(d)
+				require.Equal(t, first.ID(), second.ID())
+				first.RankValue()
+
+				plan := TaskPlan{first, other, second, first}.Normalize()
+				require.Len(t, plan, 2)
+				assert.Same(t, first, plan[0])
+				assert.Same(t, other, plan[1])
+				assert.EqualValues(t, 5, first.tasks["one"].Priority)
+				assert.False(t, first.valueComputed)
+			})
+			t.Run("ExportGroups", func(t *testing.T) {
+				first := NewUnit(task.Task{Id: "first-one", Priority: 10, TaskGroupOrder: 2})
+				first.Add(task.Task{Id: "first-two", Priority: 10, TaskGroupOrder: 1})
+				second := NewUnit(task.Task{Id: "second-one", TaskGroupOrder: 1})
+				second.Add(task.Task{Id: "second-two", TaskGroupOrder: 2})
+				second.Add(task.Task{Id: "first-one", Priority: 10, TaskGroupOrder: 2})
+				plan := buildPlan(second, first)
+
+				groups := plan.ExportGroups()
+				require.Len(t, groups, 2)
+				require.Len(t, groups[0], 2)
+				assert.Equal(t, "first-two", groups[0][0].Id)
+				assert.Equal(t, "first-one", groups[0][1].Id)
+				require.Len(t, groups[1], 2)
+				assert.Equal(t, "second-one", groups[1][0].Id)
+				assert.Equal(t, "second-two", groups[1][1].Id)
+
+				var flattened []string
+				for _, group := range groups {
+					for _, t := range group {
+						flattened = append(flattened, t.Id)
+					}
+				}
+				exported := plan.Export()
+				require.Len(t, exported, len(flattened))
+				for idx := range exported {
+					assert.Equal(t, flattened[idx], exported[idx].Id)
+				}
+			})
+			t.Run("EstimatedStartPosition", func(t *testing.T) {
+				withDuration := func(tsk task.Task, duration time.Duration) task.Task {
+					tsk.DurationPrediction.Value = duration
+					tsk.DurationPrediction.TTL = 24 * time.Hour
+					tsk.DurationPrediction.CollectedAt = time.Now()
+					return tsk
+				}
+				first := NewUnit(withDuration(task.Task{Id: "first", Priority: 20}, 30*time.Minute))
+				second := NewUnit(withDuration(task.Task{Id: "second-one", Priority: 10}, 5*time.Minute))
+				second.Add(withDuration(task.Task{Id: "second-two", Priority: 10}, 5*time.Minute))
+				third := NewUnit(withDuration(task.Task{Id: "third"}, 10*time.Minute))
+				plan := buildPlan(third, second, first)
+
+				pos, eta := plan.EstimatedStartPosition("third", 2)
+				assert.Equal(t, 2, pos)
+				assert.Equal(t, 20*time.Minute, eta)
+				assert.Equal(t, 40*time.Minute, plan[:pos].EstimateMakespan(1))
+
+				pos, eta = plan.EstimatedStartPosition("second-two", 1)
+				assert.Equal(t, 1, pos)
+				assert.Equal(t, 30*time.Minute, eta)
+
+				pos, eta = plan.EstimatedStartPosition("first", 4)
+				assert.Equal(t, 0, pos)
+				assert.Zero(t, eta)
+
+				pos, eta = plan.EstimatedStartPosition("missing", 4)
+				assert.Equal(t, -1, pos)
+				assert.Zero(t, eta)
+			})
+			t.Run("Waves", func(t *testing.T) {
+				units := make([]*Unit, 0, 5)
+				for i := 0; i < 5; i++ {
+					units = append(units, NewUnit(task.Task{Id: fmt.Sprint("p", i), Priority: int64(10 * (i + 1))}))
+				}
+				plan := buildPlan(units...)
+				ids := func(wave []*Unit) []string {
+					var out []string
+					for _, unit := range wave {
+						out = append(out, unit.Keys()...)
+					}
+					return out
+				}
+
+				waves := plan.Waves(2)
+				require.Len(t, waves, 3)
+				assert.Equal(t, []string{"p4", "p3"}, ids(waves[0]))
+				assert.Equal(t, []string{"p2", "p1"}, ids(waves[1]))
+				assert.Equal(t, []string{"p0"}, ids(waves[2]))
+
+				waves = plan.Waves(5)
+				require.Len(t, waves, 1)
+				assert.Len(t, waves[0], 5)
+
+				waves = plan.Waves(0)
+				require.Len(t, waves, 1)
+				assert.Len(t, waves[0], 5)
+
+				assert.Empty(t, TaskPlan{}.Waves(2))
+			})
+			t.Run("CriticalPath", func(t *testing.T) {
+				withDuration := func(tsk task.Task, duration time.Duration) task.Task {
+					tsk.DurationPrediction.Value = duration
+					tsk.DurationPrediction.TTL = 24 * time.Hour
+					tsk.DurationPrediction.CollectedAt = time.Now()
+					return tsk
+				}
+				// a -> b -> c is 10+20+30 minutes, which is longer
+				// than the single long unit and the d -> c chain.
+				a := NewUnit(withDuration(task.Task{Id: "a"}, 10*time.Minute))
+				b := NewUnit(withDuration(task.Task{Id: "b", DependsOn: []task.Dependency{{TaskId: "a"}}}, 20*time.Minute))
+				c := NewUnit(withDuration(task.Task{Id: "c", DependsOn: []task.Dependency{{TaskId: "b"}, {TaskId: "d"}}}, 30*time.Minute))
+				d := NewUnit(withDuration(task.Task{Id: "d"}, 15*time.Minute))
+				long := NewUnit(withDuration(task.Task{Id: "long"}, 50*time.Minute))
+				plan := TaskPlan{c, long, d, b, a}
+
+				unit, length := plan.CriticalPath()
+				assert.Equal(t, a, unit)
+				assert.Equal(t, time.Hour, length)
+
+				t.Run("Cycle", func(t *testing.T) {
+					x := NewUnit(withDuration(task.Task{Id: "x", DependsOn: []task.Dependency{{TaskId: "y"}}}, 10*time.Minute))
+					y := NewUnit(withDuration(task.Task{Id: "y", DependsOn: []task.Dependency{{TaskId: "x"}}}, 10*time.Minute))
+					unit, length := TaskPlan{x, y}.CriticalPath()
+					assert.Equal(t, x, unit)
+					assert.Equal(t, 20*time.Minute, length)
+				})
+				t.Run("Empty", func(t *testing.T) {
+					unit, length := TaskPlan{}.CriticalPath()
+					assert.Nil(t, unit)
+					assert.Zero(t, length)
+				})
+			})
+			t.Run("FlagAtRisk", func(t *testing.T) {
+				withDuration := func(tsk task.Task, duration time.Duration) task.Task {
+					tsk.DurationPrediction.Value = duration
+					tsk.DurationPrediction.TTL = 24 * time.Hour
+					tsk.DurationPrediction.CollectedAt = time.Now()
+					return tsk
+				}
+				now := time.Now()
+				first := NewUnit(withDuration(task.Task{Id: "first", Priority: 20}, time.Hour))
+				first.SetDeadline(now.Add(2 * time.Hour))
+				second := NewUnit(withDuration(task.Task{Id: "second", Priority: 10}, time.Hour))
+				third := NewUnit(withDuration(task.Task{Id: "third"}, 10*time.Minute))
+				third.SetDeadline(now.Add(time.Hour))
+				plan := buildPlan(third, second, first)
+
+				plan.FlagAtRisk(1, now)
+				assert.False(t, first.AtRisk())
+				assert.False(t, second.AtRisk())
+				assert.True(t, third.AtRisk())
+
+				snapshot := plan.Snapshot()
+				require.Len(t, snapshot, 3)
+				assert.Equal(t, third.ID(), snapshot[2].ID)
+				assert.True(t, snapshot[2].AtRisk)
+				assert.False(t, snapshot[0].AtRisk)
+
+				// with enough hosts, the third unit starts right
+				// away.
+				plan.FlagAtRisk(3, now)
+				assert.False(t, third.AtRisk())
+			})
+			t.Run("SingleHostDistroShortestFirst", func(t *testing.T) {
+				buildRuntimePlan := func(d *distro.Distro) TaskPlan {
+					short := task.Task{Id: "short"}
+					short.DurationPrediction.Value = time.Minute
+					short.DurationPrediction.TTL = 24 * time.Hour
+					short.DurationPrediction.CollectedAt = time.Now()
+					long := task.Task{Id: "long"}
+					long.DurationPrediction.Value = time.Hour
+					long.DurationPrediction.TTL = 24 * time.Hour
+					long.DurationPrediction.CollectedAt = time.Now()
+
+					plan := TaskPlan{NewUnit(long), NewUnit(short)}
+					for _, unit := range plan {
+						unit.SetDistro(d)
+					}
+					return plan
+				}
+				t.Run("MultipleHosts", func(t *testing.T) {
+					out := buildRuntimePlan(&distro.Distro{HostAllocatorSettings: distro.HostAllocatorSettings{MaximumHosts: 10}}).Export()
+					assert.Equal(t, "long", out[0].Id)
+					assert.Equal(t, "short", out[1].Id)
+				})
+				t.Run("SingleHost", func(t *testing.T) {
+					out := buildRuntimePlan(&distro.Distro{HostAllocatorSettings: distro.HostAllocatorSettings{MaximumHosts: 1}}).Export()
+					assert.Equal(t, "short", out[0].Id)
+					assert.Equal(t, "long", out[1].Id)
+				})
+				t.Run("LatencyObjective", func(t *testing.T) {
+					out := buildRuntimePlan(&distro.Distro{
+						PlannerSettings:       distro.PlannerSettings{SchedulingObjective: distro.SchedulingObjectiveLatency},
+						HostAllocatorSettings: distro.HostAllocatorSettings{MaximumHosts: 10},
+					}).Export()
+					assert.Equal(t, "short", out[0].Id)
+					assert.Equal(t, "long", out[1].Id)
+				})
+				t.Run("MakespanObjective", func(t *testing.T) {
+					out := buildRuntimePlan(&distro.Distro{
+						PlannerSettings:       distro.PlannerSettings{SchedulingObjective: distro.SchedulingObjectiveMakespan},
+						HostAllocatorSettings: distro.HostAllocatorSettings{MaximumHosts: 10},
+					}).Export()
+					assert.Equal(t, "long", out[0].Id)
+					assert.Equal(t, "short", out[1].Id)
+				})
+			})
+			t.Run("DistroDefaultTaskDuration", func(t *testing.T) {
+				buildUnit := func(defaultDuration time.Duration, tasks ...task.Task) *Unit {
+					unit := NewUnit(tasks[0])
+					for _, t := range tasks[1:] {
+						unit.Add(t)
+					}
+					unit.SetDistro(&distro.Distro{
+						PlannerSettings: distro.PlannerSettings{DefaultTaskDuration: defaultDuration},
+					})
+					return unit
+				}
+				t.Run("NewTasks", func(t *testing.T) {
+					unit := buildUnit(40*time.Minute, task.Task{Id: "one"}, task.Task{Id: "two"})
+					assert.Equal(t, 80*time.Minute, unit.info().ExpectedRuntime)
+					assert.Equal(t, 80*time.Minute, unit.TotalExpectedRuntime())
+				})
+				t.Run("Unconfigured", func(t *testing.T) {
+					unit := buildUnit(0, task.Task{Id: "one"}, task.Task{Id: "two"})
+					assert.Equal(t, 20*time.Minute, unit.info().ExpectedRuntime)
+				})
+				t.Run("TasksWithHistory", func(t *testing.T) {
+					unit := buildUnit(40*time.Minute, task.Task{Id: "one", ExpectedDuration: time.Minute})
+					assert.Equal(t, time.Minute, unit.info().ExpectedRuntime)
+				})
+			})
+			t.Run("ThroughputFeedback", func(t *testing.T) {
+				buildThroughputPlan := func(scale bool, throughput float64) TaskPlan {
+					d := &distro.Distro{
+						PlannerSettings: distro.PlannerSettings{
+							ExpectedRuntimeFactor: 1,
+							ScaleByThroughput:     &scale,
+							ExpectedThroughput:    10,
+						},
+					}
+					plan := TaskPlan{
+						NewUnit(task.Task{Id: "long", ExpectedDuration: time.Hour}),
+						NewUnit(task.Task{Id: "blocking", ExpectedDuration: 10 * time.Minute, NumDependents: 50}),
+					}
+					for _, unit := range plan {
+						unit.SetDistro(d)
+					}
+					plan.SetThroughput(throughput)
+					sort.Sort(plan)
+					return plan
+				}
+				t.Run("HighThroughputFavorsLongerUnits", func(t *testing.T) {
+					plan := buildThroughputPlan(true, 40)
+					assert.Equal(t, "long", plan[0].Export()[0].Id)
+				})
+				t.Run("LowThroughputFavorsShorterUnits", func(t *testing.T) {
+					plan := buildThroughputPlan(true, 2)
+					assert.Equal(t, "blocking", plan[0].Export()[0].Id)
+				})
+				t.Run("AdjustmentIsBounded", func(t *testing.T) {
+					high := buildThroughputPlan(true, 20)
+					higher := buildThroughputPlan(true, 1000)
+					for idx := range high {
+						assert.Equal(t, high[idx].RankValue(), higher[idx].RankValue())
+					}
+				})
+				t.Run("Disabled", func(t *testing.T) {
+					high := buildThroughputPlan(false, 40)
+					low := buildThroughputPlan(false, 2)
+					for idx := range high {
+						assert.Equal(t, high[idx].RankValue(), low[idx].RankValue())
+					}
+				})
+			})
+			t.Run("StepbackDecay", func(t *testing.T) {
+				stepbackValue := func(decay float64, iterations int) int64 {
+					unit := NewUnit(task.Task{
+						Id:           "stepback",
+						ActivatedBy:  evergreen.StepbackTaskActivator,
+						StepbackInfo: &task.StepbackInfo{StepbackIterations: iterations},
+					})
+					unit.SetDistro(&distro.Distro{
+						PlannerSettings: distro.PlannerSettings{StepbackTaskFactor: 100, StepbackDecayFactor: decay},
+					})
+					info := unit.info()
+					for _, term := range info.terms() {
+						if term.Name == RankFactorStepback {
+							return term.Value
+						}
+					}
+					return 0
+				}
+
+				first := stepbackValue(0.5, 0)
+				tenth := stepbackValue(0.5, 9)
+				assert.NotZero(t, tenth)
+				assert.Less(t, tenth, first)
+				assert.Equal(t, stepbackValue(0, 0), first)
+				assert.Equal(t, first, stepbackValue(0, 9), "the boost shouldn't decay without a decay factor")
+			})
+			t.Run("ExpectedRuntimePercentile", func(t *testing.T) {
+				buildVariancePlan := func(percentile int) TaskPlan {
+					d := &distro.Distro{
+						PlannerSettings:       distro.PlannerSettings{ExpectedRuntimePercentile: percentile},
+						HostAllocatorSettings: distro.HostAllocatorSettings{MaximumHosts: 10},
+					}
+					steady := task.Task{Id: "steady"}
+					steady.DurationPrediction.Value = 40 * time.Minute
+					steady.DurationPrediction.TTL = 24 * time.Hour
+					steady.DurationPrediction.CollectedAt = time.Now()
+					variable := task.Task{Id: "variable"}
+					variable.DurationPrediction.Value = 30 * time.Minute
+					variable.DurationPrediction.StdDev = 20 * time.Minute
+					variable.DurationPrediction.TTL = 24 * time.Hour
+					variable.DurationPrediction.CollectedAt = time.Now()
+
+					plan := TaskPlan{NewUnit(steady), NewUnit(variable)}
+					for _, unit := range plan {
+						unit.SetDistro(d)
+					}
+					return plan
+				}
+				t.Run("Average", func(t *testing.T) {
+					out := buildVariancePlan(0).Export()
+					assert.Equal(t, "steady", out[0].Id)
+					assert.Equal(t, "variable", out[1].Id)
+				})
+				t.Run("P90", func(t *testing.T) {
+					out := buildVariancePlan(90).Export()
+					assert.Equal(t, "variable", out[0].Id)
+					assert.Equal(t, "steady", out[1].Id)
+				})
+			})
+			t.Run("Rerank", func(t *testing.T) {
+				tasks := []task.Task{
+					{Id: "patch", Requester: evergreen.PatchVersionRequester},
+					{Id: "mainline", Requester: evergreen.RepotrackerVersionRequester},
+					{Id: "other-mainline", Requester: evergreen.RepotrackerVersionRequester, Priority: 1},
+				}
+				ids := func(plan TaskPlan) []string {
+					var out []string
+					for _, unit := range plan {
+						out = append(out, unit.Keys()...)
+					}
+					return out
+				}
+
+				plan := PrepareTasksForPlanning(&distro.Distro{}, tasks)
+				sort.Sort(plan)
+				original := ids(plan)
+				require.Equal(t, "patch", original[len(original)-1])
+
+				newSettings := distro.PlannerSettings{PatchFactor: 10000}
+				reranked := plan.Rerank(newSettings)
+				replanned := PrepareTasksForPlanning(&distro.Distro{PlannerSettings: newSettings}, tasks)
+				sort.Sort(replanned)
+				assert.Equal(t, ids(replanned), ids(reranked))
+				assert.Equal(t, "patch", ids(reranked)[0])
+
+				assert.Equal(t, original, ids(plan), "the original plan should not be reordered")
+				for _, unit := range plan {
+					assert.Zero(t, unit.info().Settings.PatchFactor)
+				}
+
+				t.Run("UsesPlanProcessors", func(t *testing.T) {
+					defer ClearPlanProcessors()
+					RegisterPlanProcessor(PlanProcessorFunc(func(tpl TaskPlan) TaskPlan {
+						out := TaskPlan{}
+						for _, unit := range tpl {
+							out = append(TaskPlan{unit}, out...)
+						}
+						return out
+					}))
+
+					reversed := ids(plan.Rerank(newSettings))
+					require.Len(t, reversed, 3)
+					assert.Equal(t, "patch", reversed[len(reversed)-1])
+					assert.Equal(t, original, ids(plan))
+				})
+				t.Run("UsesPriorityTiers", func(t *testing.T) {
+					strict := true
+					tiered := plan.Rerank(distro.PlannerSettings{PatchFactor: 10000, StrictPriorityTiers: &strict})
+					assert.Equal(t, "other-mainline", ids(tiered)[0])
+				})
+				t.Run("UsesMainlineReservation", func(t *testing.T) {
+					reserved := distro.PlannerSettings{PatchFactor: 10000, MainlineReservationRatio: 1}
+					reranked := ids(plan.Rerank(reserved))
+					require.Len(t, reranked, 3)
+					assert.Equal(t, "patch", reranked[len(reranked)-1])
+
+					var exported []string
+					for _, t := range PrepareTasksForPlanning(&distro.Distro{PlannerSettings: reserved}, tasks).Export() {
+						exported = append(exported, t.Id)
+					}
+					assert.Equal(t, exported, reranked)
+				})
+			})
+			t.Run("WhatIfPriority", func(t *testing.T) {
+				tasks := []task.Task{
+					{Id: "low"},
+					{Id: "mid", Priority: 10},
+					{Id: "high", Priority: 50},
+				}
+				positionOf := func(plan TaskPlan, taskID string) int {
+					sort.Sort(plan)
+					for idx, unit := range plan {
+						if _, ok := unit.tasks[taskID]; ok {
+							return idx
+						}
+					}
+					return -1
+				}
+				plan := PrepareTasksForPlanning(&distro.Distro{}, tasks)
+				original := make(TaskPlan, len(plan))
+				copy(original, plan)
+
+				oldPos, newPos := plan.WhatIfPriority("low", 100)
+				assert.Equal(t, positionOf(PrepareTasksForPlanning(&distro.Distro{}, tasks), "low"), oldPos)
+				changed := []task.Task{{Id: "low", Priority: 100}, tasks[1], tasks[2]}
+				assert.Equal(t, positionOf(PrepareTasksForPlanning(&distro.Distro{}, changed), "low"), newPos)
+				assert.Equal(t, 2, oldPos)
+				assert.Equal(t, 0, newPos)
+
+				assert.Equal(t, original, plan, "the plan should not be reordered")
+				for _, unit := range plan {
+					for _, tsk := range unit.tasks {
+						assert.NotEqual(t, int64(100), tsk.Priority)
+					}
+				}
+
+				oldPos, newPos = plan.WhatIfPriority("missing", 100)
+				assert.Equal(t, -1, oldPos)
+				assert.Equal(t, -1, newPos)
+
+				t.Run("TaskInMultipleUnits", func(t *testing.T) {
+					d := &distro.Distro{}
+					build := func(sharedPriority int64) TaskPlan {
+						shared := task.Task{Id: "shared", Priority: sharedPriority}
+						first := NewUnit(shared)
+						second := NewUnit(shared)
+						second.Add(task.Task{Id: "second"})
+						plan := TaskPlan{first, second, NewUnit(task.Task{Id: "other", Priority: 50})}
+						for _, unit := range plan {
+							unit.SetDistro(d)
+						}
+						return plan
+					}
+
+					oldPos, newPos := build(60).WhatIfPriority("shared", 0)
+					assert.Equal(t, positionOf(build(60), "shared"), oldPos)
+					assert.Equal(t, positionOf(build(0), "shared"), newPos)
+					assert.Equal(t, 0, oldPos)
+					assert.Equal(t, 1, newPos)
+				})
+			})
+			t.Run("WarmRankValues", func(t *testing.T) {
+				d := &distro.Distro{}
+				plan := TaskPlan{
+					NewUnit(task.Task{Id: "one"}),
+					NewUnit(task.Task{Id: "two"}),
+					NewUnit(task.Task{Id: "three"}),
+				}
+				for _, unit := range plan {
+					unit.SetDistro(d)
+				}
+
+				plan.WarmRankValues(plan[0].ID(), plan[2].ID(), "not-in-plan")
+				assert.True(t, plan[0].valueComputed)
+				assert.False(t, plan[1].valueComputed)
+				assert.True(t, plan[2].valueComputed)
+
+				plan.WarmRankValues()
+				assert.False(t, plan[1].valueComputed)
+			})
+			t.Run("PreserveTaskOrder", func(t *testing.T) {
+				ids := func(tasks []task.Task) []string {
+					out := make([]string, 0, len(tasks))
+					for _, t := range tasks {
+						out = append(out, t.Id)
+					}
+					return out
+				}
+				buildOrderedUnit := func(preserve bool, taskGroup string) *Unit {
+					unit := NewUnit(task.Task{Id: "first", TaskGroup: taskGroup})
+					unit.Add(task.Task{Id: "second", TaskGroup: taskGroup, Priority: 10})
+					unit.Add(task.Task{Id: "third", TaskGroup: taskGroup, Priority: 5})
+					unit.Add(task.Task{Id: "first", TaskGroup: taskGroup})
+					unit.SetDistro(&distro.Distro{
+						PlannerSettings: distro.PlannerSettings{PreserveTaskOrder: &preserve},
+					})
+					return unit
+				}
+				t.Run("Enabled", func(t *testing.T) {
+					groups := TaskPlan{buildOrderedUnit(true, "")}.ExportGroups()
+					require.Len(t, groups, 1)
+					assert.Equal(t, []string{"first", "second", "third"}, ids(groups[0]))
+				})
+				t.Run("Disabled", func(t *testing.T) {
+					groups := TaskPlan{buildOrderedUnit(false, "")}.ExportGroups()
+					require.Len(t, groups, 1)
+					assert.Equal(t, []string{"second", "third", "first"}, ids(groups[0]))
+				})
+				t.Run("TaskGroupsKeepSortOrder", func(t *testing.T) {
+					groups := TaskPlan{buildOrderedUnit(true, "tg")}.ExportGroups()
+					require.Len(t, groups, 1)
+					assert.Equal(t, []string{"second", "third", "first"}, ids(groups[0]))
+				})
+				t.Run("DependenciesFirst", func(t *testing.T) {
+					unit := buildOrderedUnit(true, "")
+					unit.Add(task.Task{Id: "fourth"})
+					unit.Add(task.Task{Id: "first", DependsOn: []task.Dependency{{TaskId: "fourth"}}})
+					assert.Equal(t, []string{"second", "third", "fourth", "first"}, ids(unit.orderedTasks()))
+				})
+			})
+			t.Run("Distros", func(t *testing.T) {
+				first := &distro.Distro{Id: "first"}
+				second := &distro.Distro{Id: "second"}
+				plan := TaskPlan{
+					NewUnit(task.Task{Id: "one"}),
+					NewUnit(task.Task{Id: "two"}),
+					NewUnit(task.Task{Id: "three"}),
+					NewUnit(task.Task{Id: "four"}),
+				}
+				plan[0].SetDistro(second)
+				plan[1].SetDistro(first)
+				plan[2].SetDistro(second)
+
+				assert.Equal(t, []string{"first", "second"}, plan.Distros())
+				assert.Empty(t, TaskPlan{NewUnit(task.Task{Id: "one"})}.Distros())
+			})
+			t.Run("PlanProcessors", func(t *testing.T) {
+				defer ClearPlanProcessors()
+
+				buildPlan := func() TaskPlan {
+					return PrepareTasksForPlanning(&distro.Distro{}, []task.Task{
+						{Id: "low"},
+						{Id: "mid", Priority: 10},
+						{Id: "high", Priority: 50},
+					})
+				}
+				ids := func(tasks []task.Task) []string {
+					out := make([]string, 0, len(tasks))
+					for _, t := range tasks {
+						out = append(out, t.Id)
+					}
+					return out
+				}
+				defaultOrder := ids(buildPlan().Export())
+				require.Equal(t, []string{"high", "mid", "low"}, defaultOrder)
+
+				calls := 0
+				RegisterPlanProcessor(PlanProcessorFunc(func(tpl TaskPlan) TaskPlan {
+					calls++
+					return tpl
+				}))
+				assert.Equal(t, defaultOrder, ids(buildPlan().Export()))
+				assert.Equal(t, 1, calls)
+
+				RegisterPlanProcessor(PlanProcessorFunc(func(tpl TaskPlan) TaskPlan {
+					out := TaskPlan{}
+					for _, unit := range tpl {
+						if _, ok := unit.tasks["low"]; ok {
+							out = append(TaskPlan{unit}, out...)
+							continue
+						}
+						out = append(out, unit)
+					}
+					return out
+				}))
+				assert.Equal(t, []string{"low", "high", "mid"}, ids(buildPlan().Export()))
+				assert.Equal(t, 2, calls)
+
+				ClearPlanProcessors()
+				assert.Equal(t, defaultOrder, ids(buildPlan().Export()))
+				assert.Equal(t, 2, calls)
+			})
+			t.Run("MinPriorityMultiplier", func(t *testing.T) {
+				buildPriorityPlan := func(floor int64) TaskPlan {
+					d := &distro.Distro{
+						PlannerSettings: distro.PlannerSettings{MinPriorityMultiplier: floor},
+					}
+					deprioritized := NewUnit(task.Task{Id: "deprioritized0", Priority: -100, ExpectedDuration: time.Hour})
+					deprioritized.Add(task.Task{Id: "deprioritized1", Priority: -100, ExpectedDuration: time.Hour})
+					plan := TaskPlan{
+						deprioritized,
+						NewUnit(task.Task{Id: "normal", ExpectedDuration: time.Minute}),
+					}
+					for _, unit := range plan {
+						unit.SetDistro(d)
+					}
+					return plan
+				}
+				t.Run("Default", func(t *testing.T) {
+					plan := buildPriorityPlan(0)
+					deprioritized := plan[0]
+					assert.EqualValues(t, 1, deprioritized.EffectivePriority())
+					info := deprioritized.info()
+					for _, term := range info.terms() {
+						if term.Name == RankFactorNegativePriority {
+							assert.EqualValues(t, -100*negativePriorityPenalty, term.Value)
+							continue
+						}
+						assert.GreaterOrEqual(t, term.Value, int64(0), term.Name)
+					}
+
+					out := plan.Export()
+					require.Len(t, out, 3)
+					assert.Equal(t, "normal", out[0].Id)
+				})
+				t.Run("Configured", func(t *testing.T) {
+					plan := buildPriorityPlan(5)
+					assert.EqualValues(t, 5, plan[0].EffectivePriority())
+					assert.EqualValues(t, 5, plan[1].EffectivePriority())
+				})
+			})
+			t.Run("BuildCompletion", func(t *testing.T) {
+				buildCompletionPlan := func(enabled bool) TaskPlan {
+					d := &distro.Distro{
+						PlannerSettings:       distro.PlannerSettings{PrioritizeBuildCompletion: &enabled},
+						HostAllocatorSettings: distro.HostAllocatorSettings{MaximumHosts: 10},
+					}
+					plan := TaskPlan{
+						NewUnit(task.Task{Id: "a-starts-fresh-build", BuildId: "fresh"}),
+						NewUnit(task.Task{Id: "b-finishes-build", BuildId: "nearly-done"}),
+					}
+					for _, unit := range plan {
+						unit.SetDistro(d)
+					}
+					// 9 of the 10 tasks in the nearly done build have
+					// finished, and none of the fresh build's have.
+					plan.SetRemainingBuildTasks(map[string]int{"fresh": 10, "nearly-done": 1})
+					return plan
+				}
+				t.Run("Enabled", func(t *testing.T) {
+					plan := buildCompletionPlan(true)
+					assert.EqualValues(t, 1, plan[1].completedBuilds)
+					assert.Zero(t, plan[0].completedBuilds)
+					assert.Greater(t, plan[1].RankValue(), plan[0].RankValue())
+					out := plan.Export()
+					require.Len(t, out, 2)
+					assert.Equal(t, "b-finishes-build", out[0].Id)
+				})
+				t.Run("Disabled", func(t *testing.T) {
+					plan := buildCompletionPlan(false)
+					assert.Equal(t, plan[0].RankValue(), plan[1].RankValue())
+				})
+			})
+			t.Run("IdleHosts", func(t *testing.T) {
+				buildIdleHostPlan := func(idleHosts *int) TaskPlan {
+					d := &distro.Distro{
+						PlannerSettings: distro.PlannerSettings{
+							ScaleByIdleHosts: func() *bool { b := true; return &b }(),
+						},
+						HostAllocatorSettings: distro.HostAllocatorSettings{MaximumHosts: 10},
+					}
+					large := NewUnit(task.Task{Id: "large0"})
+					large.Add(task.Task{Id: "large1"})
+					large.Add(task.Task{Id: "large2"})
+					small := NewUnit(task.Task{Id: "small", NumDependents: 5})
+
+					plan := TaskPlan{small, large}
+					for _, unit := range plan {
+						unit.SetDistro(d)
+					}
+					if idleHosts != nil {
+						plan.SetIdleHosts(*idleHosts)
+					}
+					return plan
+				}
+				t.Run("ManyIdleHosts", func(t *testing.T) {
+					out := buildIdleHostPlan(utility.ToIntPtr(10)).Export()
+					require.Len(t, out, 4)
+					assert.True(t, strings.HasPrefix(out[0].Id, "large"))
+					assert.Equal(t, "small", out[3].Id)
+				})
+				t.Run("FewIdleHosts", func(t *testing.T) {
+					out := buildIdleHostPlan(utility.ToIntPtr(0)).Export()
+					require.Len(t, out, 4)
+					assert.Equal(t, "small", out[0].Id)
+					assert.True(t, strings.HasPrefix(out[1].Id, "large"))
+				})
+				t.Run("Disabled", func(t *testing.T) {
+					plan := buildIdleHostPlan(utility.ToIntPtr(10))
+					for _, unit := range plan {
+						unit.distro.PlannerSettings.ScaleByIdleHosts = nil
+						unit.invalidate()
+					}
+					out := plan.Export()
+					require.Len(t, out, 4)
+					assert.Equal(t, "small", out[0].Id)
+				})
+				t.Run("UnknownCount", func(t *testing.T) {
+					plan := buildIdleHostPlan(nil)
+					for _, unit := range plan {
+						info := unit.info()
+						for _, term := range info.terms() {
+							assert.NotEqual(t, RankFactorIdleHosts, term.Name)
+						}
+					}
+					out := plan.Export()
+					require.Len(t, out, 4)
+					assert.Equal(t, "small", out[0].Id)
+				})
+			})
+			t.Run("LimitGeneratorBoost", func(t *testing.T) {
+				d := &distro.Distro{PlannerSettings: distro.PlannerSettings{GenerateTaskFactor: 10}}
+				buildGeneratorPlan := func() TaskPlan {
+					plan := TaskPlan{}
+					for i := 0; i < 4; i++ {
+						unit := NewUnit(task.Task{Id: fmt.Sprint("generator", i), GenerateTask: true, Priority: int64(i)})
+						unit.SetDistro(d)
+						plan = append(plan, unit)
+					}
+					other := NewUnit(task.Task{Id: "other"})
+					other.SetDistro(d)
+					return append(plan, other)
+				}
+
+				plan := buildGeneratorPlan()
+				full := map[string]int64{}
+				for _, unit := range plan {
+					full[unit.Keys()[0]] = unit.RankValue()
+				}
+
+				plan.LimitGeneratorBoost(2)
+				var boosted []string
+				for _, unit := range plan {
+					if unit.RankValue() == full[unit.Keys()[0]] && unit.info().ContainsGenerateTask {
+						boosted = append(boosted, unit.Keys()[0])
+					}
+				}
+				assert.ElementsMatch(t, []string{"generator2", "generator3"}, boosted)
+				assert.Less(t, plan[0].RankValue(), full["generator0"])
+				assert.Less(t, plan[1].RankValue(), full["generator1"])
+				assert.Equal(t, full["other"], plan[4].RankValue())
+
+				t.Run("Unlimited", func(t *testing.T) {
+					plan.LimitGeneratorBoost(0)
+					for _, unit := range plan {
+						assert.Equal(t, full[unit.Keys()[0]], unit.RankValue())
+					}
+				})
+			})
+			t.Run("Quarantine", func(t *testing.T) {
+				d := &distro.Distro{}
+				normal := NewUnit(task.Task{Id: "normal", Requester: evergreen.PatchVersionRequester, NumDependents: 2})
+				quarantined := NewUnit(task.Task{Id: "quarantined", Requester: evergreen.PatchVersionRequester, NumDependents: 2, Quarantined: true})
+				plan := TaskPlan{quarantined, normal}
+				for _, unit := range plan {
+					unit.SetDistro(d)
+				}
+
+				assert.Equal(t, normal.RankValue()/distro.DefaultQuarantineFactor, quarantined.RankValue())
+				out := plan.Export()
+				assert.Equal(t, "normal", out[0].Id)
+				assert.Equal(t, "quarantined", out[1].Id)
+
+				t.Run("OnlyWhenAllTasksQuarantined", func(t *testing.T) {
+					mixed := NewUnit(task.Task{Id: "normal"})
+					mixed.Add(task.Task{Id: "quarantined", Quarantined: true})
+					mixed.SetDistro(d)
+					assert.False(t, mixed.info().Quarantined)
+				})
+			})
+			t.Run("SplitUnits", func(t *testing.T) {
+				d := &distro.Distro{}
+				t.Run("SplitsLargeUnits", func(t *testing.T) {
+					unit := MakeUnit(d)
+					for i := 0; i < 5; i++ {
+						unit.Add(task.Task{Id: fmt.Sprint("t", i), Version: "v1"})
+					}
+					unit.SetTag("bucket", "a")
+
+					plan := TaskPlan{unit}.SplitUnits(2)
+					require.Len(t, plan, 3)
+					keys := []string{}
+					for _, part := range plan {
+						assert.LessOrEqual(t, len(part.tasks), 2)
+						assert.Equal(t, d, part.distro)
+						assert.Equal(t, "a", part.Tags["bucket"])
+						keys = append(keys, part.Keys()...)
+					}
+					assert.ElementsMatch(t, unit.Keys(), keys)
+				})
+				t.Run("KeepsSingleHostTaskGroupsWhole", func(t *testing.T) {
+					unit := MakeUnit(d)
+					for i := 0; i < 4; i++ {
+						unit.Add(task.Task{Id: fmt.Sprint("tg", i), Version: "v1", TaskGroup: "tg", TaskGroupMaxHosts: 1, TaskGroupOrder: i + 1})
+					}
+					unit.Add(task.Task{Id: "other0", Version: "v1"})
+					unit.Add(task.Task{Id: "other1", Version: "v1"})
+
+					plan := TaskPlan{unit}.SplitUnits(2)
+					require.Len(t, plan, 2)
+					var group *Unit
+					for _, part := range plan {
+						if _, ok := part.tasks["tg0"]; ok {
+							group = part
+						}
+					}
+					require.NotNil(t, group)
+					assert.ElementsMatch(t, []string{"tg0", "tg1", "tg2", "tg3"}, group.Keys())
+				})
+				t.Run("SplitsMultiHostTaskGroups", func(t *testing.T) {
+					unit := MakeUnit(d)
+					for i := 0; i < 4; i++ {
+						unit.Add(task.Task{Id: fmt.Sprint("tg", i), Version: "v1", TaskGroup: "tg", TaskGroupMaxHosts: 2})
+					}
+
+					assert.Len(t, TaskPlan{unit}.SplitUnits(2), 2)
+				})
+				t.Run("NoopWithoutLimit", func(t *testing.T) {
+					unit := MakeUnit(d)
+					for i := 0; i < 4; i++ {
+						unit.Add(task.Task{Id: fmt.Sprint("t", i)})
+					}
+
+					plan := TaskPlan{unit}.SplitUnits(0)
+					require.Len(t, plan, 1)
+					assert.Same(t, unit, plan[0])
+				})
+			})
+			t.Run("ToDOT", func(t *testing.T) {
+				d := &distro.Distro{}
+				dep := NewUnit(task.Task{Id: "b"})
+				dependent := NewUnit(task.Task{Id: "a", DependsOn: []task.Dependency{{TaskId: "b"}}})
+				dependent.Add(task.Task{Id: "c", DependsOn: []task.Dependency{{TaskId: "a"}}})
+				plan := TaskPlan{dep, dependent}
+				for _, unit := range plan {
+					unit.SetDistro(d)
+				}
+
+				dot := plan.ToDOT()
+				assert.True(t, strings.HasPrefix(dot, "digraph plan {\n"))
+				assert.Contains(t, dot, fmt.Sprintf("\t%q [label=\"1 tasks\\nrank %d\"];\n", dep.ID(), dep.RankValue()))
+				assert.Contains(t, dot, fmt.Sprintf("\t%q [label=\"2 tasks\\nrank %d\"];\n", dependent.ID(), dependent.RankValue()))
+				assert.Contains(t, dot, fmt.Sprintf("\t%q -> %q;\n", dep.ID(), dependent.ID()))
+				assert.NotContains(t, dot, fmt.Sprintf("\t%q -> %q;\n", dependent.ID(), dep.ID()))
+				assert.Contains(t, dot, fmt.Sprintf("\t\t%q -> %q;\n", dependent.ID()+"/a", dependent.ID()+"/c"))
+				assert.Contains(t, dot, "subgraph \"cluster_0\"")
+				assert.Contains(t, dot, "subgraph \"cluster_1\"")
+			})
+			t.Run("StrictPriorityTiers", func(t *testing.T) {
+				buildTieredPlan := func(strict bool) TaskPlan {
+					d := &distro.Distro{
+						PlannerSettings: distro.PlannerSettings{
+							StrictPriorityTiers: &strict,
+						},
+					}
+					low := NewUnit(task.Task{Id: "commit-queue", Requester: evergreen.MergeTestRequester})
+					high := NewUnit(task.Task{Id: "mainline", Requester: evergreen.RepotrackerVersionRequester, Priority: 5})
+					low.SetDistro(d)
+					high.SetDistro(d)
+					require.True(t, low.RankValue() > high.RankValue())
+
+					return TaskPlan{low, high}
+				}
+				t.Run("Disabled", func(t *testing.T) {
+					out := buildTieredPlan(false).Export()
+					assert.Equal(t, "commit-queue", out[0].Id)
+					assert.Equal(t, "mainline", out[1].Id)
+				})
+				t.Run("Enabled", func(t *testing.T) {
+					out := buildTieredPlan(true).Export()
+					assert.Equal(t, "mainline", out[0].Id)
+					assert.Equal(t, "commit-queue", out[1].Id)
+				})
+			})
+			t.Run("MainlineReservationRatio", func(t *testing.T) {
+				buildMixedPlan := func(ratio float64) TaskPlan {
+					d := &distro.Distro{
+						PlannerSettings: distro.PlannerSettings{
+							MainlineReservationRatio: ratio,
+						},
+					}
+					tasks := []task.Task{}
+					for i := 0; i < 12; i++ {
+						tasks = append(tasks, task.Task{
+							Id:        fmt.Sprintf("patch-%d", i),
+							Requester: evergreen.PatchVersionRequester,
+						})
+					}
+					for i := 0; i < 3; i++ {
+						tasks = append(tasks, task.Task{
+							Id:         fmt.Sprintf("mainline-%d", i),
+							Requester:  evergreen.RepotrackerVersionRequester,
+							IngestTime: time.Now().Add(-8 * 24 * time.Hour),
+						})
+					}
+
+					return PrepareTasksForPlanning(d, tasks)
+				}
+				isMainline := func(t task.Task) bool { return t.Requester == evergreen.RepotrackerVersionRequester }
+				t.Run("Disabled", func(t *testing.T) {
+					out := buildMixedPlan(0).Export()
+					require.Len(t, out, 15)
+					for _, tsk := range out[:12] {
+						assert.False(t, isMainline(tsk), tsk.Id)
+					}
+				})
+				t.Run("Enabled", func(t *testing.T) {
+					plan := buildMixedPlan(0.25)
+					out := plan.Export()
+					require.Len(t, out, 15)
+					numMainline := 0
+					for idx, tsk := range out[:12] {
+						if isMainline(tsk) {
+							numMainline++
+						}
+						assert.True(t, numMainline >= (idx+1)/4, "position %d", idx)
+					}
+					assert.Equal(t, 3, numMainline)
+					assert.NoError(t, plan.Validate(out))
+				})
+			})
+		})
+		t.Run("DiffPlans", func(t *testing.T) {
+			t.Run("Reordered", func(t *testing.T) {
+				oldPlan := []task.Task{{Id: "one"}, {Id: "two"}, {Id: "three"}}
+				newPlan := []task.Task{{Id: "three"}, {Id: "one"}, {Id: "two"}}
+
+				changes := DiffPlans(oldPlan, newPlan)
+				require.Len(t, changes, 3)
+				assert.Equal(t, TaskRankChange{TaskID: "three", OldPosition: 2, NewPosition: 0, Delta: -2}, changes[0])
+				assert.Equal(t, TaskRankChange{TaskID: "one", OldPosition: 0, NewPosition: 1, Delta: 1}, changes[1])
+				assert.Equal(t, TaskRankChange{TaskID: "two", OldPosition: 1, NewPosition: 2, Delta: 1}, changes[2])
+			})
+			t.Run("Unchanged", func(t *testing.T) {
+				plan := []task.Task{{Id: "one"}, {Id: "two"}}
+				for _, change := range DiffPlans(plan, plan) {
+					assert.Zero(t, change.Delta)
+				}
+			})
+			t.Run("AddedAndRemoved", func(t *testing.T) {
+				changes := DiffPlans([]task.Task{{Id: "one"}, {Id: "two"}}, []task.Task{{Id: "three"}, {Id: "one"}})
+				require.Len(t, changes, 3)
+				assert.Equal(t, TaskRankChange{TaskID: "three", OldPosition: -1, NewPosition: 0}, changes[0])
+				assert.Equal(t, TaskRankChange{TaskID: "one", OldPosition: 0, NewPosition: 1, Delta: 1}, changes[1])
+				assert.Equal(t, TaskRankChange{TaskID: "two", OldPosition: 1, NewPosition: -1}, changes[2])
+			})
+		})
+		t.Run("Comparators", func(t *testing.T) {
+			strict := true
+			tiered := &distro.Distro{PlannerSettings: distro.PlannerSettings{StrictPriorityTiers: &strict}}
+			makeUnit := func(d *distro.Distro, t task.Task) *Unit {
+				unit := NewUnit(t)
+				unit.SetDistro(d)
+				return unit
+			}
+			t.Run("PriorityTier", func(t *testing.T) {
+				t.Run("Disabled", func(t *testing.T) {
+					low := makeUnit(&distro.Distro{}, task.Task{Id: "low"})
+					high := makeUnit(&distro.Distro{}, task.Task{Id: "high", Priority: 5})
+					assert.Zero(t, ComparePriorityTier(high, low))
+				})
+				t.Run("Enabled", func(t *testing.T) {
+					low := makeUnit(tiered, task.Task{Id: "low"})
+					high := makeUnit(tiered, task.Task{Id: "high", Priority: 5})
+					assert.Equal(t, -1, ComparePriorityTier(high, low))
+					assert.Equal(t, 1, ComparePriorityTier(low, high))
+					assert.Zero(t, ComparePriorityTier(low, makeUnit(tiered, task.Task{Id: "other"})))
+				})
+			})
+			t.Run("RankValue", func(t *testing.T) {
+				mainline := makeUnit(&distro.Distro{}, task.Task{Id: "mainline"})
+				commitQueue := makeUnit(&distro.Distro{}, task.Task{Id: "commit-queue", Requester: evergreen.MergeTestRequester})
+				assert.Equal(t, -1, CompareRankValue(commitQueue, mainline))
+				assert.Equal(t, 1, CompareRankValue(mainline, commitQueue))
+				assert.Zero(t, CompareRankValue(mainline, makeUnit(&distro.Distro{}, task.Task{Id: "other"})))
+			})
+			t.Run("RankValueEpsilon", func(t *testing.T) {
+				buildPlan := func(epsilon int64) TaskPlan {
+					d := &distro.Distro{PlannerSettings: distro.PlannerSettings{RankValueEpsilon: epsilon}}
+					// "a" has a lower ID than "b", but a slightly
+					// lower RankValue.
+					a := makeUnit(d, task.Task{Id: "a"})
+					b := makeUnit(d, task.Task{Id: "b", NumDependents: 1})
+					require.Equal(t, int64(1), b.RankValue()-a.RankValue())
+					require.Equal(t, -1, CompareID(a, b))
+					return TaskPlan{b, a}
+				}
+				t.Run("Disabled", func(t *testing.T) {
+					plan := buildPlan(0)
+					sort.Sort(plan)
+					assert.Equal(t, []string{"b"}, plan[0].Keys())
+				})
+				t.Run("WithinEpsilon", func(t *testing.T) {
+					plan := buildPlan(5)
+					assert.Zero(t, CompareRankValue(plan[0], plan[1]))
+					sort.Sort(plan)
+					assert.Equal(t, []string{"a"}, plan[0].Keys())
+				})
+				t.Run("OutsideEpsilon", func(t *testing.T) {
+					plan := buildPlan(1)
+					assert.Equal(t, -1, CompareRankValue(plan[0], plan[1]))
+				})
+				t.Run("Transitive", func(t *testing.T) {
+					// Each unit's RankValue is within the epsilon of
+					// the next, but the first and last aren't.
+					d := &distro.Distro{PlannerSettings: distro.PlannerSettings{RankValueEpsilon: 6}}
+					a := makeUnit(d, task.Task{Id: "a"})
+					b := makeUnit(d, task.Task{Id: "b", NumDependents: 5})
+					c := makeUnit(d, task.Task{Id: "c", NumDependents: 10})
+					require.Equal(t, int64(5), b.RankValue()-a.RankValue())
+					require.Equal(t, int64(10), c.RankValue()-a.RankValue())
+
+					units := []*Unit{a, b, c}
+					for _, x := range units {
+						for _, y := range units {
+							for _, z := range units {
+								if CompareRankValue(x, y) <= 0 && CompareRankValue(y, z) <= 0 {
+									assert.LessOrEqual(t, CompareRankValue(x, z), 0)
+								}
+							}
+						}
+					}
+
+					var expected []string
+					for _, order := range [][]*Unit{{a, b, c}, {a, c, b}, {b, a, c}, {b, c, a}, {c, a, b}, {c, b, a}} {
+						plan := TaskPlan(append([]*Unit{}, order...))
+						sort.Sort(plan)
+						ids := []string{}
+						for _, unit := range plan {
+							ids = append(ids, unit.Keys()[0])
+						}
+						if expected == nil {
+							expected = ids
+						}
+						assert.Equal(t, expected, ids)
+					}
+					assert.Equal(t, "c", expected[0])
+				})
+			})
+			t.Run("ID", func(t *testing.T) {
+				a := NewUnit(task.Task{Id: "a"})
+				b := NewUnit(task.Task{Id: "b"})
+				expected := -1
+				if a.ID() > b.ID() {
+					expected = 1
+				}
+				assert.Equal(t, expected, CompareID(a, b))
+				assert.Equal(t, -expected, CompareID(b, a))
+				assert.Zero(t, CompareID(a, NewUnit(task.Task{Id: "a"})))
+			})
+			t.Run("Composite", func(t *testing.T) {
+				always := func(result int) UnitComparator {
+					return func(_, _ *Unit) int { return result }
+				}
+				a := NewUnit(task.Task{Id: "a"})
+				b := NewUnit(task.Task{Id: "b"})
+				t.Run("Empty", func(t *testing.T) {
+					assert.Zero(t, ComposeUnitComparators()(a, b))
+				})
+				t.Run("FirstPreferenceWins", func(t *testing.T) {
+					assert.Equal(t, 1, ComposeUnitComparators(always(1), always(-1))(a, b))
+				})
+				t.Run("FallsThroughTies", func(t *testing.T) {
+					assert.Equal(t, -1, ComposeUnitComparators(always(0), always(-1))(a, b))
+				})
+				t.Run("Default", func(t *testing.T) {
+					low := makeUnit(tiered, task.Task{Id: "commit-queue", Requester: evergreen.MergeTestRequester})
+					high := makeUnit(tiered, task.Task{Id: "mainline", Priority: 5})
+					assert.Equal(t, -1, defaultUnitComparator(high, low))
+
+					first := makeUnit(tiered, task.Task{Id: "first"})
+					second := makeUnit(tiered, task.Task{Id: "second"})
+					require.Equal(t, first.RankValue(), second.RankValue())
+					assert.Equal(t, CompareID(first, second), defaultUnitComparator(first, second))
+				})
+				t.Run("SortWith", func(t *testing.T) {
+					plan := TaskPlan{a, b}
+					plan.SortWith(func(x, y *Unit) int { return -CompareID(x, y) })
+					assert.Equal(t, -1, CompareID(plan[1], plan[0]))
+				})
+			})
 		})
 		t.Run("TaskList", func(t *testing.T) {
 			t.Run("NoChange", func(t *testing.T) {
@@ -352,6 +2048,39 @@ func TestPlanner(t *testing.T) {
 				assert.Equal(t, "first", plan[0].Id)
 				assert.Equal(t, "second", plan[1].Id)
 			})
+			t.Run("DependenciesFirst", func(t *testing.T) {
+				plan := TaskList{
+					{Id: "a", DependsOn: []task.Dependency{{TaskId: "b"}}},
+					{Id: "b"},
+				}
+				plan[0].DurationPrediction.Value = time.Hour
+				plan[0].DurationPrediction.TTL = time.Hour * 24
+				plan[0].DurationPrediction.CollectedAt = time.Now()
+
+				plan.sortWithDependencies()
+				assert.Equal(t, "b", plan[0].Id)
+				assert.Equal(t, "a", plan[1].Id)
+			})
+			t.Run("DependenciesKeepSortedOrder", func(t *testing.T) {
+				plan := TaskList{
+					{Id: "c", DependsOn: []task.Dependency{{TaskId: "b"}, {TaskId: "missing"}}, Priority: 100},
+					{Id: "b", Priority: 10},
+					{Id: "a", Priority: 50},
+				}
+
+				plan.sortWithDependencies()
+				assert.Equal(t, []string{"a", "b", "c"}, []string{plan[0].Id, plan[1].Id, plan[2].Id})
+			})
+			t.Run("DependencyCycle", func(t *testing.T) {
+				plan := TaskList{
+					{Id: "a", DependsOn: []task.Dependency{{TaskId: "b"}}, Priority: 100},
+					{Id: "b", DependsOn: []task.Dependency{{TaskId: "a"}}},
+				}
+
+				plan.sortWithDependencies()
+				assert.Equal(t, "a", plan[0].Id)
+				assert.Equal(t, "b", plan[1].Id)
+			})
 		})
 	})
 	t.Run("PrepareTaskPlan", func(t *testing.T) {
@@ -360,14 +2089,80 @@ func TestPlanner(t *testing.T) {
 		})
 		t.Run("TaskGroupsGrouped", func(t *testing.T) {
 			plan := PrepareTasksForPlanning(&distro.Distro{}, []task.Task{
-				{Id: "one", TaskGroup: "first"},
-				{Id: "two", TaskGroup: "first"},
+				{Id: "one", TaskGroup: "first", BuildVariant: "bv", Version: "v1"},
+				{Id: "two", TaskGroup: "first", BuildVariant: "bv", Version: "v1"},
 				{Id: "three"},
 			})
 
 			assert.Len(t, plan, 2)
 			assert.Len(t, plan.Export(), 3)
 		})
+		t.Run("PausedTaskGroupsExcluded", func(t *testing.T) {
+			tasks := []task.Task{
+				{Id: "one", TaskGroup: "first", BuildVariant: "bv", Version: "v1"},
+				{Id: "two", TaskGroup: "first", BuildVariant: "bv", Version: "v1"},
+				{Id: "three", TaskGroup: "second", BuildVariant: "bv", Version: "v1"},
+				{Id: "four"},
+			}
+			key := tasks[0].GetTaskGroupString()
+			PauseTaskGroup(key)
+			defer ResumeTaskGroup(key)
+			assert.Equal(t, []string{key}, PausedTaskGroups())
+
+			ids := func(plan TaskPlan) []string {
+				var out []string
+				for _, tsk := range plan.Export() {
+					out = append(out, tsk.Id)
+				}
+				return out
+			}
+			assert.ElementsMatch(t, []string{"three", "four"}, ids(PrepareTasksForPlanning(&distro.Distro{}, tasks)))
+			assert.Len(t, tasks, 4)
+
+			ResumeTaskGroup(key)
+			assert.Empty(t, PausedTaskGroups())
+			assert.ElementsMatch(t, []string{"one", "two", "three", "four"}, ids(PrepareTasksForPlanning(&distro.Distro{}, tasks)))
+		})
+		t.Run("BlockedByFailedDependencyExcluded", func(t *testing.T) {
+			tasks := []task.Task{
+				{Id: "failed", Status: evergreen.TaskFailed},
+				{Id: "blocked", DependsOn: []task.Dependency{{TaskId: "failed"}}},
+				{Id: "runs-on-failure", DependsOn: []task.Dependency{{TaskId: "failed", Status: evergreen.TaskFailed}}},
+				{Id: "runs-regardless", DependsOn: []task.Dependency{{TaskId: "failed", Status: task.AllStatuses}}},
+				{Id: "overridden", OverrideDependencies: true, DependsOn: []task.Dependency{{TaskId: "failed"}}},
+				{Id: "outside-set", DependsOn: []task.Dependency{{TaskId: "missing"}}},
+			}
+
+			var ids []string
+			for _, tsk := range PrepareTasksForPlanning(&distro.Distro{}, tasks).Export() {
+				ids = append(ids, tsk.Id)
+			}
+			assert.ElementsMatch(t, []string{"failed", "runs-on-failure", "runs-regardless", "overridden", "outside-set"}, ids)
+			assert.Len(t, tasks, 6)
+		})
+		t.Run("MalformedTaskGroupKeysNotGrouped", func(t *testing.T) {
+			sender := send.MakeInternalLogger()
+			require.NoError(t, grip.SetSender(sender))
+			defer func() {
+				assert.NoError(t, grip.SetSender(send.MakeNative()))
+			}()
+
+			cache := makeUnitCache(&distro.Distro{}, []task.Task{
+				{Id: "one", TaskGroup: "first", BuildVariant: "bv"},
+				{Id: "two", TaskGroup: "first", Version: "v1"},
+			})
+			assert.Len(t, cache.Export(), 2)
+
+			for _, id := range []string{"one", "two"} {
+				msg, ok := sender.GetMessageSafe()
+				require.True(t, ok)
+				assert.Equal(t, level.Warning, msg.Priority)
+				assert.Contains(t, msg.Rendered, "task group key is malformed")
+				assert.Contains(t, msg.Rendered, id)
+			}
+			_, ok := sender.GetMessageSafe()
+			assert.False(t, ok)
+		})
 		t.Run("VersionsGrouped", func(t *testing.T) {
 			plan := PrepareTasksForPlanning(&distro.Distro{
 				PlannerSettings: distro.PlannerSettings{
@@ -382,6 +2177,32 @@ func TestPlanner(t *testing.T) {
 			assert.Len(t, plan, 2)
 			assert.Len(t, plan.Export(), 3)
 		})
+		t.Run("VersionsGroupedByRequester", func(t *testing.T) {
+			plan := PrepareTasksForPlanning(&distro.Distro{
+				PlannerSettings: distro.PlannerSettings{
+					RequesterGroupVersions: map[string]bool{
+						evergreen.RepotrackerVersionRequester: true,
+						evergreen.PatchVersionRequester:       false,
+					},
+				},
+			}, []task.Task{
+				{Id: "mainline1", Version: "first", Requester: evergreen.RepotrackerVersionRequester},
+				{Id: "mainline2", Version: "first", Requester: evergreen.RepotrackerVersionRequester},
+				{Id: "mainline3", Version: "first", Requester: evergreen.RepotrackerVersionRequester},
+				{Id: "patch1", Version: "first", Requester: evergreen.PatchVersionRequester},
+				{Id: "patch2", Version: "first", Requester: evergreen.PatchVersionRequester},
+			})
+
+			require.Len(t, plan, 3)
+			for _, unit := range plan {
+				keys := unit.Keys()
+				if strings.HasPrefix(keys[0], "mainline") {
+					assert.ElementsMatch(t, []string{"mainline1", "mainline2", "mainline3"}, keys)
+				} else {
+					assert.Len(t, keys, 1)
+				}
+			}
+		})
 		t.Run("VersionsAndTaskGroupsGrouped", func(t *testing.T) {
 			plan := PrepareTasksForPlanning(&distro.Distro{
 				PlannerSettings: distro.PlannerSettings{
@@ -391,8 +2212,8 @@ func TestPlanner(t *testing.T) {
 				{Id: "three", Version: "second"},
 				{Id: "four", Version: "second"},
 				{Id: "five", Version: "second"},
-				{Id: "one", Version: "first", TaskGroup: "one"},
-				{Id: "two", Version: "first", TaskGroup: "one"},
+				{Id: "one", Version: "first", TaskGroup: "one", BuildVariant: "bv"},
+				{Id: "two", Version: "first", TaskGroup: "one", BuildVariant: "bv"},
 				{Id: "extra", Version: "first", Priority: 1},
 			})
 
@@ -421,6 +2242,172 @@ func TestPlanner(t *testing.T) {
 			assert.Contains(t, head, "other")
 
 		})
+		t.Run("AllDistros", func(t *testing.T) {
+			first := &distro.Distro{Id: "first"}
+			second := &distro.Distro{
+				Id: "second",
+				PlannerSettings: distro.PlannerSettings{
+					GroupVersions: func() *bool { b := true; return &b }(),
+				},
+			}
+			inputs := map[*distro.Distro][]task.Task{
+				first: {
+					{Id: "one", Version: "v1"},
+					{Id: "two", Version: "v1"},
+				},
+				second: {
+					{Id: "three", Version: "v2"},
+					{Id: "four", Version: "v2"},
+					{Id: "five", Version: "v3"},
+				},
+			}
+			for name, prepare := range map[string]func(map[*distro.Distro][]task.Task) (map[string]TaskPlan, error){
+				"Sequential": PrepareAllDistros,
+				"Concurrent": func(inputs map[*distro.Distro][]task.Task) (map[string]TaskPlan, error) {
+					return PrepareAllDistrosWithWorkers(inputs, 4)
+				},
+			} {
+				t.Run(name, func(t *testing.T) {
+					t.Run("NilDistro", func(t *testing.T) {
+						plans, err := prepare(map[*distro.Distro][]task.Task{
+							first: inputs[first],
+							nil:   {{Id: "six"}},
+						})
+						require.Error(t, err)
+						assert.Contains(t, err.Error(), "nil distro")
+						assert.Nil(t, plans)
+					})
+					t.Run("DuplicateIDs", func(t *testing.T) {
+						plans, err := prepare(map[*distro.Distro][]task.Task{
+							first:                       inputs[first],
+							second:                      inputs[second],
+							&distro.Distro{Id: "first"}: {{Id: "six"}},
+							&distro.Distro{Id: "ok"}:    {{Id: "seven"}},
+						})
+						require.Error(t, err)
+						assert.Contains(t, err.Error(), "same ID: first")
+						assert.Nil(t, plans)
+					})
+
+					plans, err := prepare(inputs)
+					require.NoError(t, err)
+					require.Len(t, plans, 2)
+
+					require.Len(t, plans["first"], 2)
+					assert.NoError(t, plans["first"].Validate(inputs[first]))
+					for _, unit := range plans["first"] {
+						assert.Equal(t, first, unit.distro)
+					}
+
+					require.Len(t, plans["second"], 2)
+					assert.NoError(t, plans["second"].Validate(inputs[second]))
+					for _, unit := range plans["second"] {
+						assert.Equal(t, second, unit.distro)
+					}
+				})
+			}
+		})
+		t.Run("FactorOverrides", func(t *testing.T) {
+			t.Setenv(distro.PatchFactorEnvVar, "10")
+			tasks := []task.Task{{Id: "one", Requester: evergreen.PatchVersionRequester}}
+
+			// The planner uses the settings as they are, so the
+			// overrides only apply once they're resolved.
+			unresolved := PrepareTasksForPlanning(&distro.Distro{}, tasks)
+			require.Len(t, unresolved, 1)
+			assert.NotEqualValues(t, 22, unresolved[0].RankValue())
+
+			d := &distro.Distro{}
+			d.PlannerSettings.ApplyFactorOverrides()
+			plan := PrepareTasksForPlanning(d, tasks)
+			require.Len(t, plan, 1)
+			assert.EqualValues(t, 22, plan[0].RankValue())
+		})
+		t.Run("Validate", func(t *testing.T) {
+			tasks := []task.Task{
+				{Id: "one", DependsOn: []task.Dependency{{TaskId: "two"}}},
+				{Id: "two"},
+				{Id: "three", TaskGroup: "first", BuildVariant: "bv", Version: "first"},
+				{Id: "four", TaskGroup: "first", BuildVariant: "bv", Version: "first"},
+				{Id: "five", Version: "second"},
+			}
+			t.Run("Healthy", func(t *testing.T) {
+				plan := PrepareTasksForPlanning(&distro.Distro{}, tasks)
+				unsorted := append(TaskPlan{}, plan...)
+				assert.NoError(t, plan.Validate(tasks))
+				assert.Equal(t, unsorted, plan, "validating should not reorder the plan")
+			})
+			t.Run("MissingTask", func(t *testing.T) {
+				plan := PrepareTasksForPlanning(&distro.Distro{}, tasks)
+				err := plan.Validate(append(tasks, task.Task{Id: "six"}))
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "missing [six]")
+			})
+			t.Run("ExtraTask", func(t *testing.T) {
+				plan := PrepareTasksForPlanning(&distro.Distro{}, tasks)
+				unit := NewUnit(task.Task{Id: "six"})
+				unit.SetDistro(&distro.Distro{})
+				plan = append(plan, unit)
+				err := plan.Validate(tasks)
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "extra [six]")
+			})
+			t.Run("DroppedUnit", func(t *testing.T) {
+				plan := PrepareTasksForPlanning(&distro.Distro{}, tasks)
+				sort.Sort(plan)
+				err := plan[1:].Validate(tasks)
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "missing")
+			})
+			t.Run("DuplicatedTask", func(t *testing.T) {
+				plan := PrepareTasksForPlanning(&distro.Distro{}, tasks)
+				output := plan.Export()
+				require.NoError(t, validateExport(output, tasks))
+				output = append(output, output[0])
+				err := validateExport(output, tasks)
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), fmt.Sprintf("duplicated [%s]", output[0].Id))
+				assert.Contains(t, err.Error(), "missing [], ")
+				assert.Contains(t, err.Error(), "extra []")
+			})
+		})
+		t.Run("VerifyDependencyOrdering", func(t *testing.T) {
+			tasks := []task.Task{
+				{Id: "dependent", Priority: 100, DependsOn: []task.Dependency{{TaskId: "dependency"}, {TaskId: "missing"}}},
+				{Id: "dependency"},
+			}
+			t.Run("OutrankedDependency", func(t *testing.T) {
+				plan := TaskPlan{NewUnit(tasks[1]), NewUnit(tasks[0])}
+				for _, unit := range plan {
+					unit.SetDistro(&distro.Distro{})
+				}
+				require.Greater(t, plan[1].RankValue(), plan[0].RankValue())
+				assert.Equal(t, []string{"dependent -> dependency"}, plan.VerifyDependencyOrdering(tasks))
+				assert.Equal(t, []string{"dependency"}, plan[0].Keys(), "verifying should not reorder the plan")
+			})
+			t.Run("UsesPlanProcessors", func(t *testing.T) {
+				defer ClearPlanProcessors()
+				RegisterPlanProcessor(PlanProcessorFunc(func(tpl TaskPlan) TaskPlan {
+					out := TaskPlan{}
+					for _, unit := range tpl {
+						out = append(TaskPlan{unit}, out...)
+					}
+					return out
+				}))
+
+				plan := TaskPlan{NewUnit(tasks[1]), NewUnit(tasks[0])}
+				for _, unit := range plan {
+					unit.SetDistro(&distro.Distro{})
+				}
+				assert.Empty(t, plan.VerifyDependencyOrdering(tasks))
+			})
+			t.Run("SameUnit", func(t *testing.T) {
+				unit := NewUnit(tasks[0])
+				unit.Add(tasks[1])
+				unit.SetDistro(&distro.Distro{})
+				assert.Empty(t, TaskPlan{unit}.VerifyDependencyOrdering(tasks))
+			})
+		})
 		t.Run("ExternalDependenciesIgnored", func(t *testing.T) {
 			plan := PrepareTasksForPlanning(&distro.Distro{}, []task.Task{
 				{Id: "one", DependsOn: []task.Dependency{{TaskId: "missing"}}},
@@ -431,5 +2418,46 @@ func TestPlanner(t *testing.T) {
 			assert.Len(t, plan, 3)
 			assert.Len(t, plan.Export(), 3)
 		})
+		t.Run("CustomGroupingKey", func(t *testing.T) {
+			plan := PrepareTasksForPlanningBy(&distro.Distro{}, []task.Task{
+				{Id: "one", BuildVariant: "first"},
+				{Id: "two", BuildVariant: "first", Version: "v1"},
+				{Id: "three", BuildVariant: "second", Version: "v1"},
+				{Id: "four", BuildVariant: "second", DependsOn: []task.Dependency{{TaskId: "one"}}},
+				{Id: "five"},
+			}, func(t task.Task) string { return t.BuildVariant })
+			require.Len(t, plan, 3)
+
+			var units []string
+			for _, unit := range plan {
+				ids := unit.Keys()
+				sort.Strings(ids)
+				units = append(units, strings.Join(ids, ","))
+			}
+			sort.Strings(units)
+			// the dependent is also folded into its dependency's unit.
+			assert.Equal(t, []string{"five", "four,one,two", "four,three"}, units)
+			assert.Len(t, plan.Export(), 5)
+		})
 	})
 }
+
+func BenchmarkUnitID(b *testing.B) {
+	unit := MakeUnit(nil)
+	for i := 0; i < 100; i++ {
+		unit.Add(task.Task{Id: fmt.Sprintf("task-%d", i)})
+	}
+
+	for _, capacity := range []int{0, 1024} {
+		b.Run(fmt.Sprintf("Capacity%d", capacity), func(b *testing.B) {
+			SetUnitIDCacheCapacity(capacity)
+			defer SetUnitIDCacheCapacity(0)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				unit.id = ""
+				unit.ID()
+			}
+		})
+	}
+}
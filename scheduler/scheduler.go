@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/evergreen-ci/evergreen"
+	"github.com/evergreen-ci/evergreen/db"
 	"github.com/evergreen-ci/evergreen/model"
 	"github.com/evergreen-ci/evergreen/model/distro"
 	"github.com/evergreen-ci/evergreen/model/host"
@@ -12,6 +13,7 @@ import (
 	"github.com/mongodb/grip"
 	"github.com/mongodb/grip/message"
 	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 type TaskPlannerOptions struct {
@@ -19,6 +21,12 @@ type TaskPlannerOptions struct {
 	IsSecondaryQueue     bool
 	IncludesDependencies bool
 	StartedAt            time.Time
+	// IdleHosts is the number of the distro's hosts that are currently
+	// idle, if it's known.
+	IdleHosts *int
+	// Throughput is the number of tasks per minute the distro has
+	// recently been finishing, if it's known.
+	Throughput float64
 }
 
 type TaskPlanner func(*distro.Distro, []task.Task, TaskPlannerOptions) ([]task.Task, error)
@@ -42,7 +50,37 @@ func runTunablePlanner(d *distro.Distro, tasks []task.Task, opts TaskPlannerOpti
 		return nil, errors.WithStack(err)
 	}
 
-	plan := PrepareTasksForPlanning(d, tasks).Export()
+	taskPlan := PrepareTasksForPlanning(d, tasks)
+	// project priorities only break ties between projects, so the
+	// plan is still usable without them, and there's no need to look
+	// them up for distros that don't weigh them.
+	if d.PlannerSettings.ShouldWeighProjectPriority() {
+		priorities, err := getProjectSchedulingPriorities(tasks)
+		grip.Warning(message.WrapError(err, message.Fields{
+			"message": "could not get project scheduling priorities",
+			"distro":  d.Id,
+			"planner": opts.ID,
+		}))
+		taskPlan.SetProjectPriorities(priorities)
+	}
+	if opts.IdleHosts != nil {
+		taskPlan.SetIdleHosts(*opts.IdleHosts)
+	}
+	taskPlan.SetThroughput(opts.Throughput)
+	if d.PlannerSettings.ShouldPrioritizeBuildCompletion() {
+		// without the remaining task counts, no unit gets the boost
+		// for finishing a build.
+		remaining, err := getRemainingBuildTasks(tasks)
+		grip.Warning(message.WrapError(err, message.Fields{
+			"message": "could not get remaining tasks in builds",
+			"distro":  d.Id,
+			"planner": opts.ID,
+		}))
+		taskPlan.SetRemainingBuildTasks(remaining)
+	}
+	taskPlan.LimitGeneratorBoost(d.PlannerSettings.GetMaxBoostedGenerators())
+
+	plan := taskPlan.Export()
 	info := GetDistroQueueInfo(d.Id, plan, d.GetTargetTime(), opts)
 	info.SecondaryQueue = opts.IsSecondaryQueue
 	info.PlanCreatedAt = opts.StartedAt
@@ -58,6 +96,60 @@ func runTunablePlanner(d *distro.Distro, tasks []task.Task, opts TaskPlannerOpti
 //
 // UseLegacy Scheduler Implementation
 
+// getProjectSchedulingPriorities returns the scheduling priorities of the
+// projects of the tasks, keyed by project ID.
+func getProjectSchedulingPriorities(tasks []task.Task) (map[string]int64, error) {
+	projectIDs := []string{}
+	seen := StringSet{}
+	for _, t := range tasks {
+		if t.Project != "" && !seen.Visit(t.Project) {
+			projectIDs = append(projectIDs, t.Project)
+		}
+	}
+
+	projectRefs, err := model.FindMergedProjectRefsByIds(projectIDs...)
+	if err != nil {
+		return nil, errors.Wrap(err, "finding project refs")
+	}
+
+	priorities := make(map[string]int64, len(projectRefs))
+	for _, pRef := range projectRefs {
+		priorities[pRef.Id] = pRef.SchedulingPriority
+	}
+
+	return priorities, nil
+}
+
+// getRemainingBuildTasks returns the number of activated tasks that
+// haven't finished in each of the tasks' builds, by build ID.
+func getRemainingBuildTasks(tasks []task.Task) (map[string]int, error) {
+	buildIDs := []string{}
+	seen := StringSet{}
+	for _, t := range tasks {
+		if t.BuildId != "" && !seen.Visit(t.BuildId) {
+			buildIDs = append(buildIDs, t.BuildId)
+		}
+	}
+	if len(buildIDs) == 0 {
+		return nil, nil
+	}
+
+	query := task.ByBuildIds(buildIDs)
+	query[task.ActivatedKey] = true
+	query[task.StatusKey] = bson.M{"$nin": evergreen.TaskCompletedStatuses}
+	remainingTasks, err := task.FindAll(db.Query(query).WithFields(task.BuildIdKey))
+	if err != nil {
+		return nil, errors.Wrap(err, "finding remaining tasks in builds")
+	}
+
+	remaining := make(map[string]int, len(buildIDs))
+	for _, t := range remainingTasks {
+		remaining[t.BuildId]++
+	}
+
+	return remaining, nil
+}
+
 func runLegacyPlanner(d *distro.Distro, tasks []task.Task, opts TaskPlannerOptions) ([]task.Task, error) {
 	runnableTasks, versions, err := FilterTasksWithVersionCache(tasks)
 	if err != nil {
@@ -10,6 +10,7 @@ import (
 	"github.com/evergreen-ci/evergreen/model/distro"
 	"github.com/evergreen-ci/evergreen/model/event"
 	"github.com/evergreen-ci/evergreen/model/host"
+	"github.com/evergreen-ci/evergreen/model/task"
 	"github.com/evergreen-ci/utility"
 	"github.com/mongodb/grip"
 	"github.com/mongodb/grip/message"
@@ -19,6 +20,9 @@ import (
 
 const (
 	dynamicDistroRuntimeAlertThreshold = 24 * time.Hour
+	// throughputWindow is how far back the scheduler looks for finished
+	// tasks when measuring a distro's throughput.
+	throughputWindow = 30 * time.Minute
 )
 
 type Configuration struct {
@@ -74,6 +78,8 @@ func PlanDistro(ctx context.Context, conf Configuration, s *evergreen.Settings)
 		return nil
 	}
 
+	// resolve the planner settings, including the factor overrides,
+	// once, so that everything after this plans with the same settings.
 	if _, err = distro.GetResolvedPlannerSettings(s); err != nil {
 		return errors.WithStack(err)
 	}
@@ -102,10 +108,39 @@ func PlanDistro(ctx context.Context, conf Configuration, s *evergreen.Settings)
 	/////////////////
 
 	planningPhaseBegins := time.Now()
+	var numIdleHosts *int
+	if distro.PlannerSettings.ShouldScaleByIdleHosts() {
+		// if the idle hosts can't be found, the planner doesn't scale
+		// units by them rather than treating the distro as having none.
+		idleHosts, err := host.IdleHostsWithDistroID(ctx, distro.Id)
+		grip.Warning(message.WrapError(err, message.Fields{
+			"message":  "could not find idle hosts",
+			"runner":   RunnerName,
+			"distro":   distro.Id,
+			"instance": schedulerInstanceID,
+		}))
+		if err == nil {
+			numIdleHosts = utility.ToIntPtr(len(idleHosts))
+		}
+	}
+	var throughput float64
+	if distro.PlannerSettings.ShouldScaleByThroughput() {
+		// like the idle hosts, the throughput only adjusts the ranking
+		// of units.
+		throughput, err = getDistroThroughput(distro.Id, taskFindingBegins)
+		grip.Warning(message.WrapError(err, message.Fields{
+			"message":  "could not get distro throughput",
+			"runner":   RunnerName,
+			"distro":   distro.Id,
+			"instance": schedulerInstanceID,
+		}))
+	}
 	prioritizedTasks, err := PrioritizeTasks(distro, tasks, TaskPlannerOptions{
 		StartedAt:        taskFindingBegins,
 		ID:               schedulerInstanceID,
 		IsSecondaryQueue: false,
+		IdleHosts:        numIdleHosts,
+		Throughput:       throughput,
 	})
 	if err != nil {
 		return errors.WithStack(err)
@@ -127,6 +162,17 @@ func PlanDistro(ctx context.Context, conf Configuration, s *evergreen.Settings)
 	return nil
 }
 
+// getDistroThroughput returns the number of tasks per minute that finished
+// in the distro during the throughputWindow before now.
+func getDistroThroughput(distroID string, now time.Time) (float64, error) {
+	finished, err := task.CountFinishedTasksForDistroSince(distroID, now.Add(-throughputWindow))
+	if err != nil {
+		return 0, errors.Wrapf(err, "counting finished tasks for distro '%s'", distroID)
+	}
+
+	return float64(finished) / throughputWindow.Minutes(), nil
+}
+
 func UpdateStaticDistro(ctx context.Context, d distro.Distro) error {
 	if d.Provider != evergreen.ProviderNameStatic {
 		return nil
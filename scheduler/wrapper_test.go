@@ -13,6 +13,7 @@ import (
 	"github.com/evergreen-ci/evergreen/db"
 	"github.com/evergreen-ci/evergreen/model/distro"
 	"github.com/evergreen-ci/evergreen/model/host"
+	"github.com/evergreen-ci/evergreen/model/task"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -644,3 +645,29 @@ func TestDoStaticHostUpdate(t *testing.T) {
 		})
 	}
 }
+
+func TestGetDistroThroughput(t *testing.T) {
+	require.NoError(t, db.Clear(task.Collection))
+	defer func() {
+		assert.NoError(t, db.Clear(task.Collection))
+	}()
+
+	now := time.Now()
+	for _, tsk := range []task.Task{
+		{Id: "recent_success", DistroId: "distro", Status: evergreen.TaskSucceeded, FinishTime: now.Add(-time.Minute)},
+		{Id: "recent_failure", DistroId: "distro", Status: evergreen.TaskFailed, FinishTime: now.Add(-10 * time.Minute)},
+		{Id: "running", DistroId: "distro", Status: evergreen.TaskStarted},
+		{Id: "old", DistroId: "distro", Status: evergreen.TaskSucceeded, FinishTime: now.Add(-2 * throughputWindow)},
+		{Id: "other_distro", DistroId: "other", Status: evergreen.TaskSucceeded, FinishTime: now.Add(-time.Minute)},
+	} {
+		require.NoError(t, tsk.Insert())
+	}
+
+	throughput, err := getDistroThroughput("distro", now)
+	require.NoError(t, err)
+	assert.Equal(t, 2/throughputWindow.Minutes(), throughput)
+
+	throughput, err = getDistroThroughput("nonexistent", now)
+	require.NoError(t, err)
+	assert.Zero(t, throughput)
+}
@@ -3,7 +3,11 @@ package units
 import (
 	"context"
 	"fmt"
+	"net/url"
+	"sort"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/evergreen-ci/evergreen"
 	"github.com/evergreen-ci/evergreen/db"
@@ -12,30 +16,153 @@ import (
 	"github.com/evergreen-ci/evergreen/model/patch"
 	"github.com/evergreen-ci/evergreen/model/task"
 	"github.com/evergreen-ci/evergreen/thirdparty"
+	"github.com/evergreen-ci/utility"
 	"github.com/mongodb/amboy"
 	"github.com/mongodb/amboy/job"
 	"github.com/mongodb/amboy/registry"
 	"github.com/mongodb/grip"
 	"github.com/mongodb/grip/level"
+	"github.com/mongodb/grip/logging"
 	"github.com/mongodb/grip/message"
 	"github.com/mongodb/grip/send"
 	"github.com/mongodb/grip/sometimes"
 	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 const (
 	githubStatusRefreshJobName = "github-status-refresh"
+
+	// githubStatusDescriptionMaxLength is the maximum number of
+	// characters GitHub accepts in a status description.
+	githubStatusDescriptionMaxLength = 140
+	// githubStatusContextMaxLength is the maximum number of characters
+	// GitHub accepts in a status context.
+	githubStatusContextMaxLength = 255
+
+	githubStatusEllipsis = "..."
+
+	// noTasksScheduledDescription is the description for a GitHub status
+	// of a finalized patch that did not schedule any builds.
+	noTasksScheduledDescription = "no tasks were scheduled for this patch"
+
+	requiredVariantsSucceededDescription = "all required variants succeeded"
+	requiredVariantsFailedDescription    = "a required variant failed"
+	// skippedVariantDescription is the description for a required variant
+	// that the patch didn't run. GitHub statuses don't have a neutral
+	// state, so these are reported as successful to avoid blocking the
+	// PR.
+	skippedVariantDescription = "skipped: variant was not run for this patch"
+	// requiredVariantIndicator is appended to the description of a
+	// required variant's status, so that reviewers can tell which
+	// variants' statuses block the PR.
+	requiredVariantIndicator = " (required)"
+	// exceededTimeBudgetDescription is the description for a running
+	// build that has taken longer than its variant's time budget.
+	exceededTimeBudgetDescription = "exceeded time budget"
+	// shortCommitLength is the number of characters of a commit's SHA
+	// that are shown in a status description.
+	shortCommitLength = 7
+	// waitingInQueueDescription is the description for a patch whose
+	// tasks are activated but haven't started running yet.
+	waitingInQueueDescription = "waiting in queue"
+	// setupFailuresDescription and testFailuresDescription describe a
+	// failed version, depending on whether most of its failed tasks
+	// failed during setup or while running tests.
+	setupFailuresDescription = "version failed: setup errors"
+	testFailuresDescription  = "version failed: test failures"
+	// collapsedStatusDescription is the description for a successful
+	// variant whose status is collapsed into the overall status.
+	// Statuses can't be removed from a commit, so they're overridden
+	// with a short success that points to the overall status instead.
+	collapsedStatusDescription = "passed, see the evergreen status"
+	// awaitingApprovalDescription is the description for a patch with
+	// tasks that won't run until a user approves them.
+	awaitingApprovalDescription = "awaiting manual approval"
+	// parentFailedDescription is the description for a child patch that
+	// never started because its parent patch already failed.
+	parentFailedDescription = "not run — parent failed"
+	// successDescriptionDurationPlaceholder is replaced with the patch's
+	// duration in a project's custom success description.
+	successDescriptionDurationPlaceholder = "{duration}"
+
+	// spruceRedirectParam is the query parameter on UI links that
+	// redirects users to Spruce.
+	spruceRedirectParam = "redirect_spruce_users"
+
+	// githubStatusFlushTimeout bounds how long the job waits for the
+	// sender to deliver the statuses it's holding.
+	githubStatusFlushTimeout = 30 * time.Second
 )
 
+// githubStatusErrorCategory classifies the errors encountered by the
+// refresh job, so that failures reading data from the database can be
+// handled differently from failures sending statuses to GitHub.
+type githubStatusErrorCategory string
+
+const (
+	githubStatusErrorCategoryFetch githubStatusErrorCategory = "fetch"
+	githubStatusErrorCategorySend  githubStatusErrorCategory = "send"
+)
+
+// githubStatusError is an error encountered by the refresh job, along
+// with its category.
+type githubStatusError struct {
+	Category githubStatusErrorCategory
+	Err      error
+}
+
+func newGithubStatusError(category githubStatusErrorCategory, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &githubStatusError{Category: category, Err: err}
+}
+
+func (e *githubStatusError) Error() string { return fmt.Sprintf("%s error: %s", e.Category, e.Err) }
+func (e *githubStatusError) Unwrap() error { return e.Err }
+func (e *githubStatusError) Cause() error  { return e.Err }
+
 func init() {
 	registry.AddJobType(githubStatusRefreshJobName, func() amboy.Job { return makeGithubStatusRefreshJob() })
 }
 
 // NewGithubStatusRefreshJob is a job that re-sends github statuses to the PR associated with the given patch.
 func NewGithubStatusRefreshJob(p *patch.Patch) amboy.Job {
+	return NewGithubStatusRefreshJobWithOptions(p, GithubStatusRefreshOptions{})
+}
+
+// GithubStatusRefreshOptions represent options to control how a GitHub
+// status refresh job sends statuses.
+type GithubStatusRefreshOptions struct {
+	// Sender, if set, receives the messages that the job would otherwise
+	// send to GitHub, which makes it possible to verify them without
+	// GitHub. Each status is a message.Composer whose Raw value is a
+	// *message.GithubStatus with the Owner, Repo, and Ref of the PR's
+	// head commit, along with the Context, State, URL, and Description
+	// of the status. The summary comment, if the project enables it, is
+	// a message.Composer whose Raw value implements message.Generic.
+	// The sender is not persisted with the job, so it only applies
+	// when the job runs in the same process that created it.
+	Sender send.Sender
+	// DryRun, if true, logs each status and summary comment that the
+	// job would send instead of sending it, so that changes to the job
+	// can be checked against real patches. Like Sender, it only
+	// applies when the job runs in the same process that created it.
+	DryRun bool
+}
+
+// NewGithubStatusRefreshJobWithOptions is the same as
+// NewGithubStatusRefreshJob, but with options to control how statuses
+// are sent.
+func NewGithubStatusRefreshJobWithOptions(p *patch.Patch, opts GithubStatusRefreshOptions) amboy.Job {
 	job := makeGithubStatusRefreshJob()
 	job.FetchID = p.Version
 	job.patch = p
+	job.sender = opts.Sender
+	job.senderOverridden = opts.Sender != nil
+	job.dryRun = opts.DryRun
 
 	job.SetID(fmt.Sprintf("%s:%s-%s", githubStatusRefreshJobName, p.Version, time.Now().String()))
 	return job
@@ -45,11 +172,66 @@ type githubStatusRefreshJob struct {
 	job.Base `bson:"job_base" json:"job_base" yaml:"job_base"`
 	env      evergreen.Environment
 	sender   send.Sender
+	// senderOverridden indicates that the sender was provided when the
+	// job was created, so every message goes to it instead of GitHub.
+	senderOverridden bool
+	// dryRun indicates that messages are logged to the logger rather
+	// than sent.
+	dryRun bool
+	logger grip.Journaler
 
 	urlBase      string
 	patch        *patch.Patch
 	builds       []build.Build
 	childPatches []patch.Patch
+	// projectRef is the patch's project, if it exists.
+	projectRef *model.ProjectRef
+	// noTasksScheduled indicates that the patch was finalized without
+	// creating any builds or child patches, so it will never finish.
+	noTasksScheduled bool
+	// waitingInQueue indicates that the patch has activated tasks, but
+	// none of them have started yet.
+	waitingInQueue bool
+	// remainingEstimate is the estimated time until the patch's
+	// remaining tasks finish, if the project reports it and it could
+	// be estimated.
+	remainingEstimate time.Duration
+	// numSetupFailures and numTestFailures are the number of the failed
+	// patch's tasks that failed during setup, including system
+	// failures, and that failed otherwise.
+	numSetupFailures int
+	numTestFailures  int
+	// numRetriedSuccesses is the number of the finished patch's tasks
+	// that only succeeded after being retried, if the project reports
+	// them.
+	numRetriedSuccesses int
+	// restartedBy is the user who most recently restarted the patch's
+	// tasks, if it wasn't the patch's author.
+	restartedBy string
+	// categorizedErrors are the errors added to the job that have a
+	// category.
+	categorizedErrors []*githubStatusError
+	// clock returns the current time for computing durations. If nil,
+	// the wall clock is used.
+	clock func() time.Time
+	// queuedStatuses are the statuses waiting to be sent, by context,
+	// and queuedContexts are their contexts in the order they were
+	// first queued.
+	queuedStatuses map[string]message.GithubStatus
+	queuedContexts []string
+	// unflushedContexts are the contexts of the statuses handed to the
+	// sender since it was last flushed.
+	unflushedContexts []string
+	// collapseSuccesses indicates that the patch succeeded and the
+	// project collapses the statuses of successful variants into the
+	// overall status.
+	collapseSuccesses bool
+	// moduleVariants are the variants that use each module changed by
+	// the patch, by module name, if the project sends module statuses.
+	moduleVariants map[string][]string
+	// omitSpruceRedirect indicates that the redirect_spruce_users query
+	// parameter should be dropped from status URLs.
+	omitSpruceRedirect bool
 
 	FetchID string `bson:"fetch_id" json:"fetch_id" yaml:"fetch_id"`
 }
@@ -83,6 +265,83 @@ func (j *githubStatusRefreshJob) shouldUpdate(ctx context.Context) (bool, error)
 	return true, nil
 }
 
+func (j *githubStatusRefreshJob) now() time.Time {
+	if j.clock == nil {
+		return time.Now()
+	}
+
+	return j.clock()
+}
+
+// addError adds the error to the job, keeping track of its category if
+// it has one. Categorized errors are also logged with their category, so
+// that fetch and send failures can be told apart in the logs.
+func (j *githubStatusRefreshJob) addError(err error) {
+	if err == nil {
+		return
+	}
+
+	var statusErr *githubStatusError
+	if errors.As(err, &statusErr) {
+		j.categorizedErrors = append(j.categorizedErrors, statusErr)
+		j.logger.Error(message.WrapError(statusErr.Err, message.Fields{
+			"message":  "error refreshing GitHub status",
+			"category": string(statusErr.Category),
+			"patch_id": j.FetchID,
+			"job_id":   j.ID(),
+		}))
+	}
+
+	j.AddError(err)
+}
+
+// errorsForCategory returns all of the errors added to the job with the
+// given category.
+func (j *githubStatusRefreshJob) errorsForCategory(category githubStatusErrorCategory) []error {
+	var out []error
+	for _, err := range j.categorizedErrors {
+		if err.Category == category {
+			out = append(out, err)
+		}
+	}
+
+	return out
+}
+
+// findPatch finds the job's patch, if the job wasn't created with it.
+func (j *githubStatusRefreshJob) findPatch() error {
+	if j.patch != nil {
+		return nil
+	}
+
+	var err error
+	j.patch, err = patch.FindOneId(j.FetchID)
+	if err != nil {
+		return newGithubStatusError(githubStatusErrorCategoryFetch, errors.Wrap(err, "finding patch"))
+	}
+	if j.patch == nil {
+		return newGithubStatusError(githubStatusErrorCategoryFetch, errors.New("patch not found"))
+	}
+
+	return nil
+}
+
+// isDisabledForProject returns whether the patch's project has disabled
+// GitHub statuses.
+func (j *githubStatusRefreshJob) isDisabledForProject() (bool, error) {
+	if err := j.findPatch(); err != nil {
+		return false, err
+	}
+
+	projectRef, err := model.FindMergedProjectRef(j.patch.Project, j.patch.Version, false)
+	if err != nil {
+		return false, newGithubStatusError(githubStatusErrorCategoryFetch, errors.Wrap(err, "finding project ref"))
+	}
+	j.projectRef = projectRef
+
+	return projectRef != nil && projectRef.IsGithubStatusesDisabled(), nil
+}
+
 func (j *githubStatusRefreshJob) fetch(ctx context.Context) error {
 	if j.env == nil {
 		j.env = evergreen.GetEnvironment()
@@ -90,49 +349,361 @@ func (j *githubStatusRefreshJob) fetch(ctx context.Context) error {
 	uiConfig := evergreen.UIConfig{}
 	var err error
 	if err := uiConfig.Get(ctx); err != nil {
-		return errors.Wrap(err, "retrieving UI config")
+		return newGithubStatusError(githubStatusErrorCategoryFetch, errors.Wrap(err, "retrieving UI config"))
 	}
 	j.urlBase = uiConfig.Url
+	j.omitSpruceRedirect = uiConfig.OmitSpruceRedirect
 	if j.urlBase == "" {
-		return errors.New("url base doesn't exist")
+		return newGithubStatusError(githubStatusErrorCategoryFetch, errors.New("url base doesn't exist"))
 	}
-	j.sender, err = j.env.GetGitHubSender(j.patch.GithubPatchData.BaseOwner, j.patch.GithubPatchData.BaseRepo)
-	if err != nil {
+	if err = j.findPatch(); err != nil {
 		return err
 	}
-	if j.patch == nil {
-		j.patch, err = patch.FindOneId(j.FetchID)
+	if !j.senderOverridden && !j.dryRun {
+		j.sender, err = j.env.GetGitHubSender(j.patch.GithubPatchData.BaseOwner, j.patch.GithubPatchData.BaseRepo)
 		if err != nil {
-			return errors.Wrap(err, "finding patch")
-		}
-		if j.patch == nil {
-			return errors.New("patch not found")
+			return newGithubStatusError(githubStatusErrorCategorySend, errors.Wrap(err, "getting GitHub sender"))
 		}
 	}
 
-	j.builds, err = build.Find(build.ByVersion(j.FetchID))
+	builds, err := build.Find(build.ByVersion(j.FetchID))
 	if err != nil {
-		return errors.Wrap(err, "finding builds")
+		return newGithubStatusError(githubStatusErrorCategoryFetch, errors.Wrap(err, "finding builds"))
+	}
+	j.builds = getLatestBuildPerVariant(builds)
+
+	if j.projectRef == nil {
+		j.projectRef, err = model.FindMergedProjectRef(j.patch.Project, j.patch.Version, false)
+		if err != nil {
+			return newGithubStatusError(githubStatusErrorCategoryFetch, errors.Wrap(err, "finding project ref"))
+		}
+	}
+
+	if j.projectRef != nil && j.projectRef.IsGithubModuleStatusesEnabled() {
+		if err = j.fetchModuleVariants(ctx); err != nil {
+			return err
+		}
 	}
 
 	if len(j.patch.Triggers.ChildPatches) > 0 {
 		j.childPatches, err = patch.Find(patch.ByStringIds(j.patch.Triggers.ChildPatches))
 		if err != nil {
-			return errors.Wrap(err, "finding child patches")
+			return newGithubStatusError(githubStatusErrorCategoryFetch, errors.Wrap(err, "finding child patches"))
 		}
 	}
+
+	j.noTasksScheduled = j.patch.Activated && len(j.builds) == 0 && len(j.childPatches) == 0
+
+	v, err := model.VersionFindOne(model.VersionById(j.patch.Version).WithFields(model.VersionRestartedByKey))
+	if err != nil {
+		return newGithubStatusError(githubStatusErrorCategoryFetch, errors.Wrap(err, "finding version"))
+	}
+	if v != nil && v.RestartedBy != j.patch.Author {
+		j.restartedBy = v.RestartedBy
+	}
+
+	if j.patch.Activated {
+		activatedQuery := task.ByVersion(j.patch.Version)
+		activatedQuery[task.ActivatedKey] = true
+		activatedTasks, err := task.FindAll(db.Query(activatedQuery).WithFields(task.StatusKey, task.StartTimeKey, task.ExpectedDurationKey))
+		if err != nil {
+			return newGithubStatusError(githubStatusErrorCategoryFetch, errors.Wrap(err, "finding activated tasks"))
+		}
+		j.waitingInQueue = len(activatedTasks) > 0
+		for _, t := range activatedTasks {
+			if t.Status != evergreen.TaskUndispatched {
+				j.waitingInQueue = false
+				break
+			}
+		}
+		if j.projectRef != nil && j.projectRef.IsGithubCompletionEstimateEnabled() {
+			j.remainingEstimate = estimateRemainingTime(activatedTasks, j.now())
+		}
+	}
+
+	if j.patch.IsFinished() && j.reportRetriedTasks() {
+		retriedQuery := task.ByVersion(j.patch.Version)
+		retriedQuery[task.StatusKey] = evergreen.TaskSucceeded
+		retriedQuery[task.ExecutionKey] = bson.M{"$gt": 0}
+		j.numRetriedSuccesses, err = task.Count(db.Query(retriedQuery))
+		if err != nil {
+			return newGithubStatusError(githubStatusErrorCategoryFetch, errors.Wrap(err, "counting retried tasks"))
+		}
+	}
+
+	if j.patch.Status == evergreen.VersionFailed {
+		failedQuery := task.ByVersion(j.patch.Version)
+		failedQuery[task.StatusKey] = bson.M{"$in": evergreen.TaskFailureStatuses}
+		failedTasks, err := task.FindAll(db.Query(failedQuery).WithFields(task.StatusKey, task.DetailsKey))
+		if err != nil {
+			return newGithubStatusError(githubStatusErrorCategoryFetch, errors.Wrap(err, "finding failed tasks"))
+		}
+		j.numSetupFailures, j.numTestFailures = countFailureTypes(failedTasks)
+	}
 	return nil
 }
 
-func (j *githubStatusRefreshJob) sendStatus(status *message.GithubStatus) {
-	c := message.MakeGithubStatusMessageWithRepo(*status)
-	if !c.Loggable() {
-		j.AddError(errors.Errorf("status message is invalid: %+v", status))
+// fetchModuleVariants finds the variants in the patch's project that use
+// each of the modules that the patch changes.
+func (j *githubStatusRefreshJob) fetchModuleVariants(ctx context.Context) error {
+	var modules []string
+	for _, modulePatch := range j.patch.Patches {
+		if modulePatch.ModuleName != "" {
+			modules = append(modules, modulePatch.ModuleName)
+		}
+	}
+	if len(modules) == 0 {
+		return nil
+	}
+
+	v, err := model.VersionFindOneId(j.patch.Version)
+	if err != nil {
+		return newGithubStatusError(githubStatusErrorCategoryFetch, errors.Wrapf(err, "finding version '%s'", j.patch.Version))
+	}
+	if v == nil {
+		return newGithubStatusError(githubStatusErrorCategoryFetch, errors.Errorf("version '%s' not found", j.patch.Version))
+	}
+	project, _, err := model.FindAndTranslateProjectForVersion(ctx, j.env.Settings(), v)
+	if err != nil {
+		return newGithubStatusError(githubStatusErrorCategoryFetch, errors.Wrapf(err, "loading project for version '%s'", j.patch.Version))
+	}
+
+	j.moduleVariants = map[string][]string{}
+	for _, module := range modules {
+		j.moduleVariants[module] = []string{}
+		for _, bv := range project.BuildVariants {
+			if utility.StringSliceContains(bv.Modules, module) {
+				j.moduleVariants[module] = append(j.moduleVariants[module], bv.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// reportRetriedTasks returns whether the project notes the tasks that only
+// succeeded after being retried in the descriptions of finished builds
+// and versions.
+func (j *githubStatusRefreshJob) reportRetriedTasks() bool {
+	return j.projectRef != nil && j.projectRef.IsGithubReportRetriedTasksEnabled()
+}
+
+// estimateRemainingTime estimates how long it will take for the
+// unfinished tasks to finish, assuming that they all run at the same
+// time, from their expected durations and how long the running tasks
+// have already been running. It returns 0 if none of the unfinished
+// tasks have an expected duration.
+func estimateRemainingTime(tasks []task.Task, now time.Time) time.Duration {
+	var remaining time.Duration
+	for _, t := range tasks {
+		if evergreen.IsFinishedTaskStatus(t.Status) || t.ExpectedDuration <= 0 {
+			continue
+		}
+
+		taskRemaining := t.ExpectedDuration
+		if !utility.IsZeroTime(t.StartTime) {
+			taskRemaining -= now.Sub(t.StartTime)
+		}
+		if taskRemaining > remaining {
+			remaining = taskRemaining
+		}
+	}
+
+	return remaining
+}
+
+// withRemainingTime appends the estimated remaining time to the
+// description, rounded up to the minute.
+func withRemainingTime(description string, remaining time.Duration) string {
+	if remaining <= 0 {
+		return description
+	}
+	rounded := remaining.Truncate(time.Minute)
+	if rounded < remaining {
+		rounded += time.Minute
+	}
+
+	return fmt.Sprintf("%s, ~%s remaining", description, strings.TrimSuffix(rounded.String(), "0s"))
+}
+
+// countFailureTypes returns the number of the failed tasks that failed
+// during setup or because of a system failure, and the number that
+// failed otherwise.
+func countFailureTypes(failedTasks []task.Task) (int, int) {
+	var numSetup, numTest int
+	for _, t := range failedTasks {
+		switch t.Details.Type {
+		case evergreen.CommandTypeSetup, evergreen.CommandTypeSystem:
+			numSetup++
+		default:
+			numTest++
+		}
+	}
+
+	return numSetup, numTest
+}
+
+// getLatestBuildPerVariant returns the most recently created build for
+// each variant, in the order that the variants first appear. If a patch
+// is restarted with new builds, this ensures that each variant's
+// context reports the state of the latest run rather than a status for
+// every run.
+func getLatestBuildPerVariant(builds []build.Build) []build.Build {
+	out := make([]build.Build, 0, len(builds))
+	variantIdx := map[string]int{}
+	for _, b := range builds {
+		idx, ok := variantIdx[b.BuildVariant]
+		if !ok {
+			variantIdx[b.BuildVariant] = len(out)
+			out = append(out, b)
+			continue
+		}
+		if !b.CreateTime.Before(out[idx].CreateTime) {
+			out[idx] = b
+		}
+	}
+
+	return out
+}
+
+// sanitizeGithubStatus returns a copy of the status with its description
+// and context truncated to fit within GitHub's limits. Truncated
+// descriptions end with an ellipsis.
+func sanitizeGithubStatus(status message.GithubStatus) message.GithubStatus {
+	if description := []rune(status.Description); len(description) > githubStatusDescriptionMaxLength {
+		status.Description = string(description[:githubStatusDescriptionMaxLength-len(githubStatusEllipsis)]) + githubStatusEllipsis
+	}
+	if githubContext := []rune(status.Context); len(githubContext) > githubStatusContextMaxLength {
+		status.Context = string(githubContext[:githubStatusContextMaxLength])
+	}
+
+	return status
+}
+
+// linkURL returns the URL to link to from GitHub, which drops the Spruce
+// redirect if the job omits it.
+func (j *githubStatusRefreshJob) linkURL(u string) string {
+	if j.omitSpruceRedirect {
+		return withoutSpruceRedirect(u)
+	}
+
+	return u
+}
+
+// withoutSpruceRedirect returns the URL without the query parameter that
+// redirects users to Spruce. URLs that can't be parsed are returned
+// unchanged.
+func withoutSpruceRedirect(statusURL string) string {
+	u, err := url.Parse(statusURL)
+	if err != nil {
+		return statusURL
+	}
+	query := u.Query()
+	if _, ok := query[spruceRedirectParam]; !ok {
+		return statusURL
+	}
+	query.Del(spruceRedirectParam)
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// queueStatus queues a copy of the status to be sent once all of the
+// patch's statuses have been computed. If more than one status is queued
+// for the same context, only the last one is sent, so that a context
+// never flaps between states within a single run.
+func (j *githubStatusRefreshJob) queueStatus(status *message.GithubStatus) {
+	if j.queuedStatuses == nil {
+		j.queuedStatuses = map[string]message.GithubStatus{}
+	}
+	if _, ok := j.queuedStatuses[status.Context]; !ok {
+		j.queuedContexts = append(j.queuedContexts, status.Context)
+	}
+	j.queuedStatuses[status.Context] = *status
+}
+
+// sendQueuedStatuses sends the queued statuses in the order their
+// contexts were first queued. If the project debounces statuses, a
+// status is skipped when it's the same as the last status sent for its
+// context. If the project has a minimum interval between statuses, a
+// status is skipped when the last status sent for its context had the
+// same state and was sent too recently. In either case, the sent
+// statuses are recorded on the patch.
+func (j *githubStatusRefreshJob) sendQueuedStatuses() {
+	debounce := j.projectRef != nil && j.projectRef.IsGithubDebounceStatusesEnabled()
+	var minInterval time.Duration
+	if j.projectRef != nil {
+		minInterval = j.projectRef.GetGithubStatusMinInterval()
+	}
+	lastSent := map[string]patch.GithubStatusRecord{}
+	for _, record := range j.patch.LastGithubStatuses {
+		lastSent[record.Context] = record
+	}
+
+	changed := false
+	for _, githubContext := range j.queuedContexts {
+		status := j.queuedStatuses[githubContext]
+		record := patch.GithubStatusRecord{
+			Context:     status.Context,
+			State:       string(status.State),
+			Description: sanitizeGithubStatus(status).Description,
+		}
+		last, ok := lastSent[githubContext]
+		if debounce && ok && last.State == record.State && last.Description == record.Description {
+			continue
+		}
+		if minInterval > 0 && ok && last.State == record.State && j.now().Sub(last.SentAt) < minInterval {
+			continue
+		}
+		if j.sendStatus(&status) {
+			record.SentAt = j.now()
+			lastSent[githubContext] = record
+			changed = true
+		}
+	}
+	j.queuedContexts = nil
+	j.queuedStatuses = nil
+
+	if (!debounce && minInterval <= 0) || !changed {
 		return
 	}
-	j.AddError(c.SetPriority(level.Notice))
 
+	records := make([]patch.GithubStatusRecord, 0, len(lastSent))
+	for _, record := range lastSent {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, k int) bool { return records[i].Context < records[k].Context })
+	j.addError(errors.Wrap(j.patch.SetLastGithubStatuses(records), "recording sent GitHub statuses"))
+}
+
+// sendStatus sends the status to GitHub, returning whether it was sent.
+func (j *githubStatusRefreshJob) sendStatus(status *message.GithubStatus) bool {
+	toSend := sanitizeGithubStatus(*status)
+	toSend.URL = j.linkURL(toSend.URL)
+	c := message.MakeGithubStatusMessageWithRepo(toSend)
+	if !c.Loggable() {
+		j.addError(newGithubStatusError(githubStatusErrorCategorySend, errors.Errorf("status message is invalid: %+v", status)))
+		return false
+	}
+	j.addError(newGithubStatusError(githubStatusErrorCategorySend, c.SetPriority(level.Notice)))
+
+	if j.dryRun {
+		j.logger.Info(message.Fields{
+			"message":     "dry run, not sending GitHub status",
+			"owner":       toSend.Owner,
+			"repo":        toSend.Repo,
+			"ref":         toSend.Ref,
+			"context":     toSend.Context,
+			"state":       toSend.State,
+			"description": toSend.Description,
+			"url":         toSend.URL,
+			"patch_id":    j.FetchID,
+			"job_id":      j.ID(),
+		})
+		return false
+	}
 	j.sender.Send(c)
+	j.unflushedContexts = append(j.unflushedContexts, toSend.Context)
 	grip.Info(message.Fields{
 		"ticket":   thirdparty.GithubInvestigation,
 		"message":  "called github status refresh",
@@ -141,9 +712,35 @@ func (j *githubStatusRefreshJob) sendStatus(status *message.GithubStatus) {
 		"patch_id": j.FetchID,
 		"job_id":   j.ID(),
 	})
+	return true
+}
+
+// flush waits, up to githubStatusFlushTimeout, for the sender to deliver
+// any statuses it has buffered, so that they aren't lost if the process
+// exits after the job completes. If the sender can't deliver them, each
+// status handed to it since it was last flushed is recorded as an error,
+// since the sender doesn't report which of them it delivered.
+func (j *githubStatusRefreshJob) flush(ctx context.Context) {
+	if j.sender == nil || len(j.unflushedContexts) == 0 {
+		return
+	}
+	defer func() { j.unflushedContexts = nil }()
+
+	ctx, cancel := context.WithTimeout(ctx, githubStatusFlushTimeout)
+	defer cancel()
+
+	err := j.sender.Flush(ctx)
+	if err == nil {
+		return
+	}
+	for _, githubContext := range j.unflushedContexts {
+		j.addError(newGithubStatusError(githubStatusErrorCategorySend, errors.Wrapf(err, "delivering GitHub status for context '%s'", githubContext)))
+	}
 }
 
 // sendChildPatchStatuses iterates through child patches if relevant and builds/sends statuses.
+// If the project coalesces child patches, the child patches for the same
+// project share a single status.
 func (j *githubStatusRefreshJob) sendChildPatchStatuses() error {
 	if len(j.childPatches) == 0 {
 		return nil
@@ -155,31 +752,136 @@ func (j *githubStatusRefreshJob) sendChildPatchStatuses() error {
 		Ref:   j.patch.GithubPatchData.HeadHash,
 	}
 
+	coalesce := j.projectRef != nil && j.projectRef.IsGithubCoalesceChildPatchesEnabled()
+	var coalescedProjects []string
+	coalesced := map[string][]message.GithubStatus{}
 	for _, childPatch := range j.childPatches {
 		projectIdentifier, err := model.GetIdentifierForProject(childPatch.Project)
 		if err != nil {
-			return errors.Wrap(err, "finding project identifier")
+			return newGithubStatusError(githubStatusErrorCategoryFetch, errors.Wrap(err, "finding project identifier"))
+		}
+
+		status.URL = childPatch.GetURL(j.urlBase)
+		status.State, status.Description = getGithubStateAndDescriptionForPatch(&childPatch, j.now())
+		if j.patch.Status == evergreen.VersionFailed && !childPatch.IsFinished() && childPatch.StartTime.IsZero() {
+			// The child would otherwise stay pending even though
+			// the parent has already failed.
+			status.State = message.GithubStateError
+			status.Description = parentFailedDescription
 		}
+		status.Description = withPatchAlias(status.Description, &childPatch)
+
+		if coalesce {
+			if _, ok := coalesced[projectIdentifier]; !ok {
+				coalescedProjects = append(coalescedProjects, projectIdentifier)
+			}
+			coalesced[projectIdentifier] = append(coalesced[projectIdentifier], *status)
+			continue
+		}
+
 		status.Context, err = patch.GetGithubContextForChildPatch(projectIdentifier, j.patch, &childPatch)
 		if err != nil {
-			return errors.Wrapf(err, "getting github context for child patch '%s'", childPatch.Id.Hex())
+			return newGithubStatusError(githubStatusErrorCategoryFetch, errors.Wrapf(err, "getting github context for child patch '%s'", childPatch.Id.Hex()))
 		}
+		j.queueStatus(status)
+	}
 
-		status.URL = childPatch.GetURL(j.urlBase)
-		status.State, status.Description = getGithubStateAndDescriptionForPatch(&childPatch)
-		j.sendStatus(status)
+	for _, projectIdentifier := range coalescedProjects {
+		j.queueStatus(coalesceChildPatchStatuses(fmt.Sprintf("%s/%s", evergreenContext, projectIdentifier), coalesced[projectIdentifier]))
 	}
+
 	return nil
 }
 
-func getGithubStateAndDescriptionForPatch(p *patch.Patch) (message.GithubState, string) {
+// coalesceChildPatchStatuses combines the statuses of the child patches
+// for the same project into a single status for the given context. The
+// combined status fails if any child patch failed, errors if any errored,
+// is pending if any is still running, and succeeds otherwise, and links
+// to the first child patch with the combined state.
+func coalesceChildPatchStatuses(githubContext string, statuses []message.GithubStatus) *message.GithubStatus {
+	if len(statuses) == 1 {
+		status := statuses[0]
+		status.Context = githubContext
+		return &status
+	}
+
+	counts := map[message.GithubState]int{}
+	for _, status := range statuses {
+		counts[status.State]++
+	}
+	state := message.GithubStateSuccess
+	for _, candidate := range []message.GithubState{message.GithubStateFailure, message.GithubStateError, message.GithubStatePending} {
+		if counts[candidate] > 0 {
+			state = candidate
+			break
+		}
+	}
+
+	status := statuses[0]
+	for _, childStatus := range statuses {
+		if childStatus.State == state {
+			status = childStatus
+			break
+		}
+	}
+	status.Context = githubContext
+	status.State = state
+	status.Description = fmt.Sprintf("%d child patches: %d succeeded, %d failed, %d running",
+		len(statuses),
+		counts[message.GithubStateSuccess],
+		counts[message.GithubStateFailure]+counts[message.GithubStateError],
+		counts[message.GithubStatePending],
+	)
+
+	return &status
+}
+
+// withRestartedBy prefixes the description with the user who restarted
+// the patch, if any, so that PRs record who triggered the latest run.
+func withRestartedBy(description, restartedBy string) string {
+	if restartedBy == "" {
+		return description
+	}
+
+	return fmt.Sprintf("restarted by %s — %s", restartedBy, description)
+}
+
+// withBaseCommit prefixes the description with the short SHA of the base
+// commit that the patch was tested against, if it's known.
+func withBaseCommit(description, baseCommit string) string {
+	if baseCommit == "" {
+		return description
+	}
+	if len(baseCommit) > shortCommitLength {
+		baseCommit = baseCommit[:shortCommitLength]
+	}
+
+	return fmt.Sprintf("[base: %s] %s", baseCommit, description)
+}
+
+// withPatchAlias prefixes the description with the patch's alias, if it
+// was created with a user-defined alias, so that the statuses of patches
+// with different aliases on the same PR can be told apart.
+func withPatchAlias(description string, p *patch.Patch) string {
+	if p.Alias == "" || !model.IsPatchAlias(p.Alias) {
+		return description
+	}
+
+	return fmt.Sprintf("[alias: %s] %s", p.Alias, description)
+}
+
+func getGithubStateAndDescriptionForPatch(p *patch.Patch, now time.Time) (message.GithubState, string) {
+	if p.IsCommitQueuePatch() && p.CommitQueueDequeueReason != "" {
+		return message.GithubStateFailure, fmt.Sprintf("removed from commit queue: %s", p.CommitQueueDequeueReason)
+	}
+
 	var state message.GithubState
 	if evergreen.IsSuccessfulVersionStatus(p.Status) {
 		state = message.GithubStateSuccess
 	} else if p.Status == evergreen.VersionFailed {
 		state = message.GithubStateFailure
 	} else {
-		return message.GithubStatePending, evergreen.PRTasksRunningDescription
+		return message.GithubStatePending, getPendingDescriptionForPatch(p, now)
 	}
 	duration := p.FinishTime.Sub(p.StartTime).String()
 	name := "version"
@@ -189,7 +891,145 @@ func getGithubStateAndDescriptionForPatch(p *patch.Patch) (message.GithubState,
 	return state, fmt.Sprintf("%s finished in %s", name, duration)
 }
 
+// successDescription returns the project's custom description for a
+// successful patch, with the patch's duration filled in and any control
+// characters and repeated whitespace removed. It returns an empty string
+// if the project doesn't have a custom description.
+func (j *githubStatusRefreshJob) successDescription() string {
+	if j.projectRef == nil || j.projectRef.GithubSuccessDescription == "" {
+		return ""
+	}
+
+	description := strings.ReplaceAll(j.projectRef.GithubSuccessDescription, successDescriptionDurationPlaceholder, j.patch.FinishTime.Sub(j.patch.StartTime).String())
+	description = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, description)
+
+	return strings.Join(strings.Fields(description), " ")
+}
+
+// requiredVariants returns the build variants that determine the state of
+// the overall patch status. If empty, the patch status is used as is.
+func (j *githubStatusRefreshJob) requiredVariants() []string {
+	if j.projectRef == nil {
+		return nil
+	}
+
+	return j.projectRef.GithubRequiredVariants
+}
+
+// withRequiredIndicator appends the required variant indicator to the
+// description, shortening the description if needed so that the
+// indicator isn't truncated.
+func withRequiredIndicator(description string) string {
+	maxLength := githubStatusDescriptionMaxLength - len(requiredVariantIndicator)
+	if runes := []rune(description); len(runes) > maxLength {
+		description = string(runes[:maxLength-len(githubStatusEllipsis)]) + githubStatusEllipsis
+	}
+
+	return description + requiredVariantIndicator
+}
+
+// getPendingDescriptionForPatch returns the description for a patch that
+// is still running, including how long it has been running once it has
+// been running for at least a minute.
+func getPendingDescriptionForPatch(p *patch.Patch, now time.Time) string {
+	return withElapsedTime(evergreen.PRTasksRunningDescription, p.StartTime, now)
+}
+
+// withElapsedTime appends the time elapsed since start to the
+// description, truncated to the minute. The description is unchanged if
+// start is unset or less than a minute has elapsed.
+func withElapsedTime(description string, start, now time.Time) string {
+	if utility.IsZeroTime(start) {
+		return description
+	}
+	elapsed := now.Sub(start).Truncate(time.Minute)
+	if elapsed < time.Minute {
+		return description
+	}
+
+	return fmt.Sprintf("%s (%s elapsed)", description, elapsed.String())
+}
+
+// getGithubStateForRequiredVariants returns the state of the patch
+// considering only the builds for the required variants: it fails if any
+// required build failed, succeeds once every required build succeeded,
+// and is pending otherwise. It returns false if none of the builds are
+// for a required variant.
+func getGithubStateForRequiredVariants(builds []build.Build, requiredVariants []string) (message.GithubState, bool) {
+	found := false
+	allSucceeded := true
+	for _, b := range builds {
+		if !utility.StringSliceContains(requiredVariants, b.BuildVariant) {
+			continue
+		}
+		found = true
+
+		switch b.Status {
+		case evergreen.BuildFailed:
+			return message.GithubStateFailure, true
+		case evergreen.BuildSucceeded:
+		default:
+			allSucceeded = false
+		}
+	}
+	if !found {
+		return "", false
+	}
+	if allSucceeded {
+		return message.GithubStateSuccess, true
+	}
+
+	return message.GithubStatePending, true
+}
+
+// countCompleteRequiredVariants returns how many of the required variants
+// have a finished build, out of the number of required variants. Required
+// variants without a build yet count as incomplete.
+func countCompleteRequiredVariants(builds []build.Build, requiredVariants []string) (int, int) {
+	required := utility.UniqueStrings(requiredVariants)
+	var complete int
+	for _, b := range builds {
+		if utility.StringSliceContains(required, b.BuildVariant) && evergreen.IsFinishedBuildStatus(b.Status) {
+			complete++
+		}
+	}
+
+	return complete, len(required)
+}
+
+func getFailedTasks(tasks []task.Task) []task.Task {
+	var failed []task.Task
+	for _, t := range tasks {
+		if evergreen.IsFailedTaskStatus(t.Status) {
+			failed = append(failed, t)
+		}
+	}
+
+	return failed
+}
+
+// getTaskLogURL returns a URL to the task's log.
+func getTaskLogURL(urlBase string, t task.Task) string {
+	return fmt.Sprintf("%s/task_log_raw/%s/%d?type=T", urlBase, url.PathEscape(t.Id), t.Execution)
+}
+
+// tooManyBuildStatuses returns whether the patch has more builds than the
+// project allows to each have their own status.
+func (j *githubStatusRefreshJob) tooManyBuildStatuses() bool {
+	if j.projectRef == nil || j.projectRef.GithubMaxBuildStatuses <= 0 {
+		return false
+	}
+
+	return len(j.builds) > j.projectRef.GithubMaxBuildStatuses
+}
+
 func (j *githubStatusRefreshJob) sendBuildStatuses() {
+	onlyFailed := j.tooManyBuildStatuses()
 	status := &message.GithubStatus{
 		Owner: j.patch.GithubPatchData.BaseOwner,
 		Repo:  j.patch.GithubPatchData.BaseRepo,
@@ -208,31 +1048,282 @@ func (j *githubStatusRefreshJob) sendBuildStatuses() {
 			status.State = message.GithubStatePending
 		}
 
-		query := db.Query(task.ByBuildId(b.Id)).WithFields(task.StatusKey, task.IsEssentialToSucceedKey, task.ActivatedKey)
+		query := db.Query(task.ByBuildId(b.Id)).WithFields(task.IdKey, task.ExecutionKey, task.StatusKey, task.IsEssentialToSucceedKey, task.ActivatedKey)
 		tasks, err := task.FindAll(query)
 		if err != nil {
-			j.AddError(errors.Wrapf(err, "finding tasks in build '%s'", b.Id))
+			j.addError(newGithubStatusError(githubStatusErrorCategoryFetch, errors.Wrapf(err, "finding tasks in build '%s'", b.Id)))
+			continue
+		}
+		if j.reportRetriedTasks() {
+			status.Description = b.GetPRNotificationDescriptionWithRetries(tasks)
+		} else {
+			status.Description = b.GetPRNotificationDescription(tasks)
+		}
+		if status.State == message.GithubStatePending {
+			status.Description = withElapsedTime(status.Description, b.StartTime, j.now())
+		}
+		if b.Status == evergreen.BuildStarted && j.projectRef != nil && j.projectRef.IsGithubEarlyFailureStatusEnabled() {
+			// report failures as soon as they happen, rather than
+			// waiting for the rest of the build to finish.
+			if numFailed := len(getFailedTasks(tasks)); numFailed > 0 {
+				status.State = message.GithubStateFailure
+				status.Description = fmt.Sprintf("%d failed so far, others running", numFailed)
+			}
+		}
+		if status.State == message.GithubStatePending && j.exceededTimeBudget(b) {
+			// warn about slow builds before they finish.
+			status.State = message.GithubStateError
+			status.Description = fmt.Sprintf("%s of %s", exceededTimeBudgetDescription, j.projectRef.GetGithubVariantTimeBudget(b.BuildVariant))
+		}
+		if status.State == message.GithubStateFailure && j.projectRef != nil && j.projectRef.IsGithubFailedTaskLogLinkEnabled() {
+			// link straight to the log when there's only one
+			// failure to look at.
+			if failed := getFailedTasks(tasks); len(failed) == 1 {
+				status.URL = getTaskLogURL(j.urlBase, failed[0])
+			}
+		}
+		if onlyFailed && status.State != message.GithubStateFailure {
+			continue
+		}
+
+		j.collapseStatus(status)
+		if utility.StringSliceContains(j.requiredVariants(), b.BuildVariant) {
+			status.Description = withRequiredIndicator(status.Description)
+		}
+		j.queueStatus(status)
+	}
+}
+
+// exceededTimeBudget returns whether the build is running and has been
+// running for longer than the time budget for its variant, if it has one.
+func (j *githubStatusRefreshJob) exceededTimeBudget(b build.Build) bool {
+	if j.projectRef == nil || b.Status != evergreen.BuildStarted || b.StartTime.IsZero() {
+		return false
+	}
+	budget := j.projectRef.GetGithubVariantTimeBudget(b.BuildVariant)
+	if budget <= 0 {
+		return false
+	}
+
+	return j.now().Sub(b.StartTime) > budget
+}
+
+// collapseStatus replaces the description and URL of a successful
+// variant's status so that it points to the overall status, if the
+// patch's successful statuses are being collapsed.
+func (j *githubStatusRefreshJob) collapseStatus(status *message.GithubStatus) {
+	if !j.collapseSuccesses || status.State != message.GithubStateSuccess {
+		return
+	}
+
+	status.Description = collapsedStatusDescription
+	status.URL = j.patch.GetURL(j.urlBase)
+}
+
+// sendSkippedVariantStatuses sends an informational status for each
+// required variant that doesn't have a build in the patch, so reviewers
+// know that it was intentionally not run.
+func (j *githubStatusRefreshJob) sendSkippedVariantStatuses() {
+	if j.projectRef == nil || !j.projectRef.IsGithubReportSkippedVariantsEnabled() || j.tooManyBuildStatuses() {
+		return
+	}
+
+	ranVariants := map[string]bool{}
+	for _, b := range j.builds {
+		ranVariants[b.BuildVariant] = true
+	}
+
+	for _, variant := range j.requiredVariants() {
+		if ranVariants[variant] {
+			continue
+		}
+
+		status := &message.GithubStatus{
+			Owner:       j.patch.GithubPatchData.BaseOwner,
+			Repo:        j.patch.GithubPatchData.BaseRepo,
+			Ref:         j.patch.GithubPatchData.HeadHash,
+			URL:         j.patch.GetURL(j.urlBase),
+			Context:     fmt.Sprintf("%s/%s", evergreenContext, variant),
+			State:       message.GithubStateSuccess,
+			Description: skippedVariantDescription,
+		}
+		j.collapseStatus(status)
+		status.Description = withRequiredIndicator(status.Description)
+		j.queueStatus(status)
+	}
+}
+
+// sendModuleStatuses sends a status for each module changed by the
+// patch, which fails if any build of a variant that uses the module
+// failed, succeeds once all of them succeeded, and is pending otherwise.
+// Modules that none of the patch's builds use don't get a status.
+func (j *githubStatusRefreshJob) sendModuleStatuses() {
+	for _, modulePatch := range j.patch.Patches {
+		variants, ok := j.moduleVariants[modulePatch.ModuleName]
+		if !ok {
+			continue
+		}
+		state, ok := getGithubStateForRequiredVariants(j.builds, variants)
+		if !ok {
 			continue
 		}
-		status.Description = b.GetPRNotificationDescription(tasks)
 
-		j.sendStatus(status)
+		status := &message.GithubStatus{
+			Owner:   j.patch.GithubPatchData.BaseOwner,
+			Repo:    j.patch.GithubPatchData.BaseRepo,
+			Ref:     j.patch.GithubPatchData.HeadHash,
+			URL:     j.patch.GetURL(j.urlBase),
+			Context: fmt.Sprintf("%s/module/%s", evergreenContext, modulePatch.ModuleName),
+			State:   state,
+		}
+		switch state {
+		case message.GithubStateSuccess:
+			status.Description = "all variants using the module succeeded"
+		case message.GithubStateFailure:
+			status.Description = "a variant using the module failed"
+		default:
+			complete, total := countCompleteRequiredVariants(j.builds, variants)
+			status.Description = fmt.Sprintf("%d of %d variants using the module complete", complete, total)
+		}
+		j.queueStatus(status)
+	}
+}
+
+// githubSummaryComment is a comment summarizing the results of a
+// finished patch's variants, to be posted to its PR.
+type githubSummaryComment struct {
+	Owner     string
+	Repo      string
+	PRNumber  int
+	URL       string
+	Succeeded []string
+	Failed    []string
+}
+
+func (c *githubSummaryComment) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Evergreen patch finished: %d of %d variants succeeded.\n", len(c.Succeeded), len(c.Succeeded)+len(c.Failed))
+	if len(c.Failed) > 0 {
+		b.WriteString("\nFailed:\n")
+		for _, variant := range c.Failed {
+			fmt.Fprintf(&b, "- %s\n", variant)
+		}
+	}
+	if len(c.Succeeded) > 0 {
+		b.WriteString("\nSucceeded:\n")
+		for _, variant := range c.Succeeded {
+			fmt.Fprintf(&b, "- %s\n", variant)
+		}
+	}
+	if c.URL != "" {
+		fmt.Fprintf(&b, "\n[View patch](%s)\n", c.URL)
+	}
+
+	return b.String()
+}
+
+func (c *githubSummaryComment) Valid() bool {
+	return c.Owner != "" && c.Repo != "" && c.PRNumber > 0
+}
+
+func (c *githubSummaryComment) Send() error {
+	token, err := evergreen.GetEnvironment().Settings().GetGithubOauthToken()
+	if err != nil {
+		return errors.Wrap(err, "getting GitHub OAuth token")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return errors.Wrap(thirdparty.PostCommentToPullRequest(ctx, token, c.Owner, c.Repo, c.PRNumber, c.String()), "posting summary comment")
+}
+
+// sendSummaryComment posts a comment to the PR with the result of each
+// variant once the patch finishes, if the project has enabled it.
+// Variants that neither succeeded nor failed are left out. The comment is
+// only posted once per patch, even though the job runs again after the
+// patch finishes.
+func (j *githubStatusRefreshJob) sendSummaryComment() {
+	if j.projectRef == nil || !j.projectRef.IsGithubSummaryCommentEnabled() {
+		return
+	}
+	if !j.patch.IsFinished() || len(j.builds) == 0 || j.patch.GithubSummaryCommentSent {
+		return
+	}
+
+	comment := &githubSummaryComment{
+		Owner:    j.patch.GithubPatchData.BaseOwner,
+		Repo:     j.patch.GithubPatchData.BaseRepo,
+		PRNumber: j.patch.GithubPatchData.PRNumber,
+		URL:      j.linkURL(j.patch.GetURL(j.urlBase)),
+	}
+	for _, b := range j.builds {
+		switch b.Status {
+		case evergreen.BuildSucceeded:
+			comment.Succeeded = append(comment.Succeeded, b.BuildVariant)
+		case evergreen.BuildFailed:
+			comment.Failed = append(comment.Failed, b.BuildVariant)
+		}
+	}
+
+	c := message.NewGenericMessage(level.Notice, comment, comment.String())
+	if !c.Loggable() {
+		j.addError(newGithubStatusError(githubStatusErrorCategorySend, errors.Errorf("summary comment is invalid: %+v", comment)))
+		return
+	}
+	if j.dryRun {
+		j.logger.Info(message.Fields{
+			"message":   "dry run, not sending GitHub summary comment",
+			"owner":     comment.Owner,
+			"repo":      comment.Repo,
+			"pr_number": comment.PRNumber,
+			"comment":   comment.String(),
+			"patch_id":  j.FetchID,
+			"job_id":    j.ID(),
+		})
+		return
+	}
+	if j.senderOverridden {
+		j.sender.Send(c)
+	} else {
+		sender, err := j.env.GetSender(evergreen.SenderGeneric)
+		if err != nil {
+			j.addError(newGithubStatusError(githubStatusErrorCategorySend, errors.Wrap(err, "getting sender for summary comment")))
+			return
+		}
+		sender.Send(c)
 	}
+
+	j.addError(errors.Wrap(j.patch.SetGithubSummaryCommentSent(), "recording sent summary comment"))
 }
 
 func (j *githubStatusRefreshJob) Run(ctx context.Context) {
+	if j.logger == nil {
+		j.logger = logging.MakeGrip(grip.GetSender())
+	}
 	shouldUpdate, err := j.shouldUpdate(ctx)
 	if err != nil {
-		j.AddError(err)
+		j.addError(newGithubStatusError(githubStatusErrorCategoryFetch, err))
 		return
 	}
 	if !shouldUpdate {
 		return
 	}
+	disabled, err := j.isDisabledForProject()
+	if err != nil {
+		j.addError(err)
+		return
+	}
+	if disabled {
+		return
+	}
 	if err = j.fetch(ctx); err != nil {
-		j.AddError(err)
+		j.addError(err)
 		return
 	}
+	defer j.flush(ctx)
+	defer j.sendSummaryComment()
+	defer j.sendQueuedStatuses()
 
 	status := &message.GithubStatus{
 		URL:     j.patch.GetURL(j.urlBase),
@@ -241,17 +1332,81 @@ func (j *githubStatusRefreshJob) Run(ctx context.Context) {
 		Repo:    j.patch.GithubPatchData.BaseRepo,
 		Ref:     j.patch.GithubPatchData.HeadHash,
 	}
-	status.State, status.Description = getGithubStateAndDescriptionForPatch(j.patch)
+	status.State, status.Description = getGithubStateAndDescriptionForPatch(j.patch, j.now())
+	if description := j.successDescription(); status.State == message.GithubStateSuccess && description != "" {
+		status.Description = description
+	}
+	if status.State == message.GithubStateFailure && j.patch.CommitQueueDequeueReason == "" && j.numSetupFailures+j.numTestFailures > 0 {
+		// Tell developers whether they need to look at their tests
+		// or at the infrastructure.
+		status.Description = testFailuresDescription
+		if j.numSetupFailures > j.numTestFailures {
+			status.State = message.GithubStateError
+			status.Description = setupFailuresDescription
+		}
+	}
+	if state, ok := getGithubStateForRequiredVariants(j.builds, j.requiredVariants()); ok && state != status.State && j.patch.CommitQueueDequeueReason == "" {
+		// Non-required variants shouldn't affect the overall status.
+		status.State = state
+		switch state {
+		case message.GithubStateSuccess:
+			status.Description = requiredVariantsSucceededDescription
+		case message.GithubStateFailure:
+			status.Description = requiredVariantsFailedDescription
+		default:
+			status.Description = getPendingDescriptionForPatch(j.patch, j.now())
+		}
+	}
+	if status.State != message.GithubStatePending && j.numRetriedSuccesses > 0 {
+		// A successful version can hide flaky tasks.
+		status.Description = fmt.Sprintf("%s (%d succeeded after retry)", status.Description, j.numRetriedSuccesses)
+	}
+	if status.State == message.GithubStatePending && j.projectRef != nil && j.projectRef.IsGithubRequiredChecksSummaryEnabled() {
+		if complete, total := countCompleteRequiredVariants(j.builds, j.requiredVariants()); total > 0 {
+			status.Description = fmt.Sprintf("%d of %d required checks complete", complete, total)
+		}
+	}
+	if status.State == message.GithubStatePending && j.waitingInQueue {
+		status.Description = waitingInQueueDescription
+	}
+	if status.State == message.GithubStatePending && j.patch.AwaitingManualApproval {
+		// Point reviewers to where they can unblock the patch.
+		status.Description = awaitingApprovalDescription
+		status.URL = j.patch.GetConfigureURL(j.urlBase)
+	}
+	if status.State == message.GithubStatePending && !j.waitingInQueue && !j.patch.AwaitingManualApproval {
+		status.Description = withRemainingTime(status.Description, j.remainingEstimate)
+	}
+	if j.noTasksScheduled {
+		// Without any builds, the patch would otherwise stay pending
+		// forever.
+		status.State = message.GithubStateFailure
+		status.Description = withPatchAlias(noTasksScheduledDescription, j.patch)
+		j.queueStatus(status)
+		return
+	}
 
 	// Send patch status
-	j.sendStatus(status)
+	if j.projectRef != nil && j.projectRef.IsGithubReportBaseCommitEnabled() {
+		status.Description = withBaseCommit(status.Description, j.patch.Githash)
+	}
+	status.Description = withRestartedBy(status.Description, j.restartedBy)
+	status.Description = withPatchAlias(status.Description, j.patch)
+	j.queueStatus(status)
+	j.collapseSuccesses = status.State == message.GithubStateSuccess && j.projectRef != nil && j.projectRef.IsGithubCollapseSuccessStatusesEnabled()
 
 	// Send child patch statuses.
 	if err := j.sendChildPatchStatuses(); err != nil {
-		j.AddError(err)
+		j.addError(err)
 		return
 	}
 
 	// For each build, send build status.
 	j.sendBuildStatuses()
+
+	// For each required variant that didn't run, send a skipped status.
+	j.sendSkippedVariantStatuses()
+
+	// For each module that the patch changes, send a module status.
+	j.sendModuleStatuses()
 }
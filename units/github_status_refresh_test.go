@@ -3,10 +3,13 @@ package units
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/evergreen-ci/evergreen"
+	"github.com/evergreen-ci/evergreen/apimodels"
 	"github.com/evergreen-ci/evergreen/db"
 	mgobson "github.com/evergreen-ci/evergreen/db/mgo/bson"
 	"github.com/evergreen-ci/evergreen/mock"
@@ -16,8 +19,13 @@ import (
 	"github.com/evergreen-ci/evergreen/model/task"
 	"github.com/evergreen-ci/evergreen/testutil"
 	"github.com/evergreen-ci/evergreen/thirdparty"
+	"github.com/evergreen-ci/evergreen/util"
+	"github.com/evergreen-ci/utility"
+	"github.com/mongodb/grip/logging"
 	"github.com/mongodb/grip/message"
 	"github.com/mongodb/grip/send"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -47,7 +55,7 @@ func (s *githubStatusRefreshSuite) TearDownSuite() {
 func (s *githubStatusRefreshSuite) SetupTest() {
 	s.ctx = testutil.TestSpan(s.suiteCtx, s.T())
 
-	s.NoError(db.ClearCollections(patch.Collection, build.Collection, task.Collection, model.ProjectRefCollection, evergreen.ConfigCollection))
+	s.NoError(db.ClearCollections(patch.Collection, build.Collection, task.Collection, model.ProjectRefCollection, model.VersionCollection, model.ParserProjectCollection, evergreen.ConfigCollection))
 
 	uiConfig := evergreen.UIConfig{}
 	uiConfig.Url = "https://example.com"
@@ -100,6 +108,32 @@ func (s *githubStatusRefreshSuite) TestRunInDegradedMode() {
 	s.False(job.HasErrors())
 }
 
+func (s *githubStatusRefreshSuite) TestRunDisabledForProject() {
+	pRef := model.ProjectRef{
+		Id:                     "myProject",
+		Identifier:             "myProjectIdentifier",
+		GithubStatusesDisabled: utility.TruePtr(),
+	}
+	s.NoError(pRef.Insert())
+	s.patchDoc.Project = pRef.Id
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: "myBuild",
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildStarted,
+	}
+	s.NoError(b.Insert())
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.Run(s.ctx)
+
+	s.False(job.HasErrors())
+	_, ok = s.env.InternalSender.GetMessageSafe()
+	s.False(ok)
+}
+
 func (s *githubStatusRefreshSuite) TestFetch() {
 	b := build.Build{
 		Id:      "b1",
@@ -126,6 +160,494 @@ func (s *githubStatusRefreshSuite) TestFetch() {
 	s.Len(job.childPatches, 1)
 }
 
+func (s *githubStatusRefreshSuite) TestFetchErrorIsCategorized() {
+	// A build with a malformed status can't be read from the database.
+	s.NoError(db.Insert(build.Collection, mgobson.M{
+		build.IdKey:      "b1",
+		build.VersionKey: s.patchDoc.Version,
+		build.StatusKey:  mgobson.M{"not": "a string"},
+	}))
+
+	logs := send.MakeInternalLogger()
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.logger = logging.MakeGrip(logs)
+	job.Run(s.ctx)
+	s.Require().True(job.HasErrors())
+	s.Contains(job.Error().Error(), "fetch error: finding builds")
+
+	s.Require().True(logs.HasMessage())
+	fields, ok := logs.GetMessage().Message.Raw().(message.Fields)
+	s.Require().True(ok)
+	s.Equal("fetch", fields["category"])
+	s.Contains(fields["error"], "finding builds")
+
+	fetchErrs := job.errorsForCategory(githubStatusErrorCategoryFetch)
+	s.Require().Len(fetchErrs, 1)
+	s.Empty(job.errorsForCategory(githubStatusErrorCategorySend))
+
+	var statusErr *githubStatusError
+	s.Require().True(errors.As(fetchErrs[0], &statusErr))
+	s.Equal(githubStatusErrorCategoryFetch, statusErr.Category)
+}
+
+func (s *githubStatusRefreshSuite) TestStatusOnlyConsidersRequiredVariants() {
+	pRef := model.ProjectRef{
+		Id:                     "myProject",
+		Identifier:             "myProjectIdentifier",
+		GithubRequiredVariants: []string{"required"},
+	}
+	s.NoError(pRef.Insert())
+	s.patchDoc.Project = pRef.Id
+	s.patchDoc.Status = evergreen.VersionFailed
+
+	for _, b := range []build.Build{
+		{
+			Id:           "b1",
+			BuildVariant: "required",
+			Version:      s.patchDoc.Version,
+			Status:       evergreen.BuildSucceeded,
+		},
+		{
+			Id:           "b2",
+			BuildVariant: "optional",
+			Version:      s.patchDoc.Version,
+			Status:       evergreen.BuildFailed,
+		},
+	} {
+		s.NoError(b.Insert())
+	}
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen", status.Context)
+	s.Equal(message.GithubStateSuccess, status.State)
+	s.Equal(requiredVariantsSucceededDescription, status.Description)
+
+	// Every variant still gets its own status.
+	variantStates := map[string]message.GithubState{}
+	for i := 0; i < 2; i++ {
+		status = s.getAndValidateStatus(s.env.InternalSender)
+		variantStates[status.Context] = status.State
+	}
+	s.Equal(message.GithubStateSuccess, variantStates["evergreen/required"])
+	s.Equal(message.GithubStateFailure, variantStates["evergreen/optional"])
+}
+
+func (s *githubStatusRefreshSuite) TestRequiredVariantStatusesIndicated() {
+	pRef := model.ProjectRef{
+		Id:                     "myProject",
+		Identifier:             "myProjectIdentifier",
+		GithubRequiredVariants: []string{"required"},
+	}
+	s.NoError(pRef.Insert())
+	s.patchDoc.Project = pRef.Id
+
+	for _, b := range []build.Build{
+		{Id: "b1", BuildVariant: "required", Version: s.patchDoc.Version, Status: evergreen.BuildStarted},
+		{Id: "b2", BuildVariant: "optional", Version: s.patchDoc.Version, Status: evergreen.BuildStarted},
+	} {
+		s.NoError(b.Insert())
+	}
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen", status.Context)
+	s.NotContains(status.Description, requiredVariantIndicator)
+
+	descriptions := map[string]string{}
+	for i := 0; i < 2; i++ {
+		status = s.getAndValidateStatus(s.env.InternalSender)
+		descriptions[status.Context] = status.Description
+	}
+	s.True(strings.HasSuffix(descriptions["evergreen/required"], requiredVariantIndicator))
+	s.NotContains(descriptions["evergreen/optional"], requiredVariantIndicator)
+}
+
+func (s *githubStatusRefreshSuite) TestStatusPendingSummarizesRequiredChecks() {
+	pRef := model.ProjectRef{
+		Id:                          "myProject",
+		Identifier:                  "myProjectIdentifier",
+		GithubRequiredVariants:      []string{"r1", "r2", "r3", "r4", "r5", "r6"},
+		GithubRequiredChecksSummary: utility.TruePtr(),
+	}
+	s.NoError(pRef.Insert())
+	s.patchDoc.Project = pRef.Id
+
+	// r6 doesn't have a build yet, but it's still required.
+	for _, b := range []build.Build{
+		{Id: "b1", BuildVariant: "r1", Version: s.patchDoc.Version, Status: evergreen.BuildSucceeded},
+		{Id: "b2", BuildVariant: "r2", Version: s.patchDoc.Version, Status: evergreen.BuildSucceeded},
+		{Id: "b3", BuildVariant: "r3", Version: s.patchDoc.Version, Status: evergreen.BuildStarted},
+		{Id: "b4", BuildVariant: "r4", Version: s.patchDoc.Version, Status: evergreen.BuildStarted},
+		{Id: "b5", BuildVariant: "r5", Version: s.patchDoc.Version, Status: evergreen.BuildCreated},
+		{Id: "b6", BuildVariant: "optional", Version: s.patchDoc.Version, Status: evergreen.BuildSucceeded},
+	} {
+		s.NoError(b.Insert())
+	}
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen", status.Context)
+	s.Equal(message.GithubStatePending, status.State)
+	s.Equal("2 of 6 required checks complete", status.Description)
+}
+
+func (s *githubStatusRefreshSuite) TestOnlyFinalStatusSentPerContext() {
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.sender = s.env.InternalSender
+
+	status := &message.GithubStatus{
+		Owner:       s.patchDoc.GithubPatchData.BaseOwner,
+		Repo:        s.patchDoc.GithubPatchData.BaseRepo,
+		Ref:         s.patchDoc.GithubPatchData.HeadHash,
+		URL:         "https://example.com",
+		Context:     "evergreen/myBuild",
+		State:       message.GithubStatePending,
+		Description: "tasks are running",
+	}
+	job.queueStatus(status)
+	status.State = message.GithubStateFailure
+	status.Description = "none succeeded, 1 failed"
+	job.queueStatus(status)
+	job.queueStatus(&message.GithubStatus{
+		Owner:       s.patchDoc.GithubPatchData.BaseOwner,
+		Repo:        s.patchDoc.GithubPatchData.BaseRepo,
+		Ref:         s.patchDoc.GithubPatchData.HeadHash,
+		URL:         "https://example.com",
+		Context:     "evergreen/otherBuild",
+		State:       message.GithubStateSuccess,
+		Description: "1 succeeded, none failed",
+	})
+	status.State = message.GithubStatePending
+	status.Description = "tasks are running"
+	job.queueStatus(status)
+	job.sendQueuedStatuses()
+	s.False(job.HasErrors())
+
+	sent := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen/myBuild", sent.Context)
+	s.Equal(message.GithubStatePending, sent.State)
+	s.Equal("tasks are running", sent.Description)
+
+	sent = s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen/otherBuild", sent.Context)
+	s.Equal(message.GithubStateSuccess, sent.State)
+
+	_, ok = s.env.InternalSender.GetMessageSafe()
+	s.False(ok)
+}
+
+func (s *githubStatusRefreshSuite) TestDebouncedStatusesSkipUnchangedContexts() {
+	pRef := model.ProjectRef{
+		Id:                     "myProject",
+		Identifier:             "myProjectIdentifier",
+		GithubDebounceStatuses: utility.TruePtr(),
+	}
+	s.NoError(pRef.Insert())
+	s.patchDoc.Project = pRef.Id
+	s.NoError(db.Update(patch.Collection, mgobson.M{patch.IdKey: s.patchDoc.Id}, mgobson.M{"$set": mgobson.M{patch.ProjectKey: pRef.Id}}))
+
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: "myBuild",
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildStarted,
+	}
+	s.NoError(b.Insert())
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+
+	s.Equal("evergreen", s.getAndValidateStatus(s.env.InternalSender).Context)
+	s.Equal("evergreen/myBuild", s.getAndValidateStatus(s.env.InternalSender).Context)
+
+	dbPatch, err := patch.FindOneId(s.patchDoc.Id.Hex())
+	s.Require().NoError(err)
+	s.Require().NotNil(dbPatch)
+	s.Require().Len(dbPatch.LastGithubStatuses, 2)
+	s.Equal("evergreen", dbPatch.LastGithubStatuses[0].Context)
+	s.Equal(string(message.GithubStatePending), dbPatch.LastGithubStatuses[0].State)
+
+	// Nothing has changed, so nothing is sent.
+	job, ok = NewGithubStatusRefreshJob(dbPatch).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+	_, ok = s.env.InternalSender.GetMessageSafe()
+	s.False(ok)
+
+	// Only the context that changed is sent.
+	s.NoError(b.UpdateStatus(evergreen.BuildFailed))
+	job, ok = NewGithubStatusRefreshJob(dbPatch).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen/myBuild", status.Context)
+	s.Equal(message.GithubStateFailure, status.State)
+	_, ok = s.env.InternalSender.GetMessageSafe()
+	s.False(ok)
+}
+
+func (s *githubStatusRefreshSuite) TestStatusesWithinMinIntervalSkipped() {
+	pRef := model.ProjectRef{
+		Id:                             "myProject",
+		Identifier:                     "myProjectIdentifier",
+		GithubStatusMinIntervalSeconds: 600,
+	}
+	s.NoError(pRef.Insert())
+	s.patchDoc.Project = pRef.Id
+	s.NoError(db.Update(patch.Collection, mgobson.M{patch.IdKey: s.patchDoc.Id}, mgobson.M{"$set": mgobson.M{patch.ProjectKey: pRef.Id}}))
+
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: "myBuild",
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildStarted,
+	}
+	s.NoError(b.Insert())
+
+	runAt := func(p *patch.Patch, now time.Time) {
+		job, ok := NewGithubStatusRefreshJob(p).(*githubStatusRefreshJob)
+		s.Require().True(ok)
+		job.env = s.env
+		job.clock = func() time.Time { return now }
+		job.Run(s.ctx)
+		s.False(job.HasErrors())
+	}
+
+	runAt(s.patchDoc, s.patchDoc.StartTime)
+	s.Equal("evergreen", s.getAndValidateStatus(s.env.InternalSender).Context)
+	s.Equal("evergreen/myBuild", s.getAndValidateStatus(s.env.InternalSender).Context)
+
+	dbPatch, err := patch.FindOneId(s.patchDoc.Id.Hex())
+	s.Require().NoError(err)
+	s.Require().NotNil(dbPatch)
+	s.Require().Len(dbPatch.LastGithubStatuses, 2)
+
+	// The descriptions now include the elapsed time, but the states
+	// haven't changed, so nothing is sent.
+	runAt(dbPatch, s.patchDoc.StartTime.Add(2*time.Minute))
+	_, ok := s.env.InternalSender.GetMessageSafe()
+	s.False(ok)
+
+	// A changed state is sent even within the interval.
+	s.NoError(b.UpdateStatus(evergreen.BuildFailed))
+	runAt(dbPatch, s.patchDoc.StartTime.Add(3*time.Minute))
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen/myBuild", status.Context)
+	s.Equal(message.GithubStateFailure, status.State)
+	_, ok = s.env.InternalSender.GetMessageSafe()
+	s.False(ok)
+
+	// Once the interval has passed, the same state is sent again.
+	runAt(dbPatch, s.patchDoc.StartTime.Add(11*time.Minute))
+	status = s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen", status.Context)
+	s.Equal(message.GithubStatePending, status.State)
+	_, ok = s.env.InternalSender.GetMessageSafe()
+	s.False(ok)
+}
+
+// heldGithubStatusEnvironment is an environment whose GitHub sender holds
+// on to statuses until it's flushed.
+type heldGithubStatusEnvironment struct {
+	*mock.Environment
+	sender *heldGithubStatusSender
+}
+
+func (e *heldGithubStatusEnvironment) GetGitHubSender(string, string) (send.Sender, error) {
+	return e.sender, nil
+}
+
+type heldGithubStatusSender struct {
+	send.Sender
+	held     []message.Composer
+	flushErr error
+	// flushDeadline is the deadline of the context the sender was last
+	// flushed with.
+	flushDeadline time.Time
+}
+
+func (s *heldGithubStatusSender) Send(m message.Composer) { s.held = append(s.held, m) }
+
+func (s *heldGithubStatusSender) Flush(ctx context.Context) error {
+	s.flushDeadline, _ = ctx.Deadline()
+	if s.flushErr != nil {
+		return s.flushErr
+	}
+	for _, m := range s.held {
+		s.Sender.Send(m)
+	}
+	s.held = nil
+
+	return nil
+}
+
+func (s *githubStatusRefreshSuite) TestRunFlushesHeldStatuses() {
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: "myBuild",
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildStarted,
+	}
+	s.NoError(b.Insert())
+	sender := &heldGithubStatusSender{Sender: s.env.InternalSender}
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = &heldGithubStatusEnvironment{Environment: s.env, sender: sender}
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+
+	s.Empty(sender.held)
+	s.False(sender.flushDeadline.IsZero(), "flushing should be bounded by a deadline")
+	s.Equal("evergreen", s.getAndValidateStatus(s.env.InternalSender).Context)
+	s.Equal("evergreen/myBuild", s.getAndValidateStatus(s.env.InternalSender).Context)
+}
+
+// capturedGithubStatusSender keeps the statuses sent to it in memory.
+type capturedGithubStatusSender struct {
+	send.Sender
+	statuses []message.GithubStatus
+}
+
+func (s *capturedGithubStatusSender) Send(m message.Composer) {
+	if status, ok := m.Raw().(*message.GithubStatus); ok {
+		s.statuses = append(s.statuses, *status)
+	}
+}
+
+func (s *capturedGithubStatusSender) Flush(context.Context) error { return nil }
+
+func (s *githubStatusRefreshSuite) TestStatusesSentToProvidedSender() {
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: "myBuild",
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildSucceeded,
+	}
+	s.NoError(b.Insert())
+	s.patchDoc.Status = evergreen.VersionSucceeded
+	sender := &capturedGithubStatusSender{Sender: send.MakeInternalLogger()}
+
+	job, ok := NewGithubStatusRefreshJobWithOptions(s.patchDoc, GithubStatusRefreshOptions{Sender: sender}).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+
+	s.Require().Len(sender.statuses, 2)
+	for _, status := range sender.statuses {
+		s.Equal("evergreen-ci", status.Owner)
+		s.Equal("evergreen", status.Repo)
+		s.Equal("776f608b5b12cd27b8d931c8ee4ca0c13f857299", status.Ref)
+		s.Equal(message.GithubStateSuccess, status.State)
+	}
+	s.Equal("evergreen", sender.statuses[0].Context)
+	s.Equal("version finished in 10m0s", sender.statuses[0].Description)
+	s.Equal("evergreen/myBuild", sender.statuses[1].Context)
+	s.Equal(fmt.Sprintf("https://example.com/build/%s?redirect_spruce_users=true", b.Id), sender.statuses[1].URL)
+
+	// Nothing is sent to the environment's senders.
+	_, ok = s.env.InternalSender.GetMessageSafe()
+	s.False(ok)
+}
+
+func (s *githubStatusRefreshSuite) TestDryRunLogsStatusesWithoutSending() {
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: "myBuild",
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildSucceeded,
+	}
+	s.NoError(b.Insert())
+	s.patchDoc.Status = evergreen.VersionSucceeded
+	logs := send.MakeInternalLogger()
+
+	job, ok := NewGithubStatusRefreshJobWithOptions(s.patchDoc, GithubStatusRefreshOptions{DryRun: true}).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.logger = logging.MakeGrip(logs)
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+
+	var logged []message.Fields
+	for logs.HasMessage() {
+		if fields, ok := logs.GetMessage().Message.Raw().(message.Fields); ok {
+			logged = append(logged, fields)
+		}
+	}
+	s.Require().Len(logged, 2)
+	for _, fields := range logged {
+		s.Equal("evergreen-ci", fields["owner"])
+		s.Equal("evergreen", fields["repo"])
+		s.Equal("776f608b5b12cd27b8d931c8ee4ca0c13f857299", fields["ref"])
+		s.Equal(message.GithubStateSuccess, fields["state"])
+	}
+	s.Equal("evergreen", logged[0]["context"])
+	s.Equal("version finished in 10m0s", logged[0]["description"])
+	s.Equal("evergreen/myBuild", logged[1]["context"])
+	s.Equal(fmt.Sprintf("https://example.com/build/%s?redirect_spruce_users=true", b.Id), logged[1]["url"])
+
+	_, ok = s.env.InternalSender.GetMessageSafe()
+	s.False(ok)
+}
+
+func (s *githubStatusRefreshSuite) TestRunRecordsUndeliveredStatuses() {
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: "myBuild",
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildStarted,
+	}
+	s.NoError(b.Insert())
+	sender := &heldGithubStatusSender{
+		Sender:   s.env.InternalSender,
+		flushErr: errors.New("GitHub is unavailable"),
+	}
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = &heldGithubStatusEnvironment{Environment: s.env, sender: sender}
+	job.Run(s.ctx)
+	s.Require().True(job.HasErrors())
+
+	errs := job.errorsForCategory(githubStatusErrorCategorySend)
+	s.Require().Len(errs, len(sender.held))
+	s.Require().Len(errs, 2)
+	s.Contains(errs[0].Error(), "delivering GitHub status for context 'evergreen'")
+	s.Contains(errs[1].Error(), "delivering GitHub status for context 'evergreen/myBuild'")
+	for _, err := range errs {
+		s.Contains(err.Error(), "GitHub is unavailable")
+	}
+}
+
 func (s *githubStatusRefreshSuite) TestStatusPending() {
 	tsk := task.Task{
 		Id:           "t1",
@@ -188,17 +710,627 @@ func (s *githubStatusRefreshSuite) TestStatusPending() {
 	s.Equal("tasks are running", status.Description)
 }
 
-func (s *githubStatusRefreshSuite) TestStatusPendingDueToEssentialTaskThatWillRun() {
-	tsk := task.Task{
-		Id:                   "t1",
-		BuildId:              "b1",
-		BuildVariant:         "myBuild",
-		Version:              s.patchDoc.Version,
-		Activated:            true,
-		Status:               evergreen.TaskStarted,
-		IsEssentialToSucceed: true,
+func (s *githubStatusRefreshSuite) TestChildPatchesOfSameProjectCoalesced() {
+	pRef := model.ProjectRef{
+		Id:                         "myProject",
+		Identifier:                 "myProjectIdentifier",
+		GithubCoalesceChildPatches: utility.TruePtr(),
 	}
-	s.NoError(tsk.Insert())
+	s.NoError(pRef.Insert())
+	s.patchDoc.Project = pRef.Id
+
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: "myBuild",
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildStarted,
+	}
+	s.NoError(b.Insert())
+
+	failedChild := patch.Patch{
+		Id:         mgobson.NewObjectId(),
+		Status:     evergreen.VersionFailed,
+		Project:    "myChildProject",
+		Activated:  true,
+		StartTime:  s.patchDoc.StartTime,
+		FinishTime: s.patchDoc.StartTime.Add(time.Minute),
+		Triggers: patch.TriggerInfo{
+			ParentPatch: s.patchDoc.Id.Hex(),
+		},
+		DisplayNewUI: true,
+	}
+	s.NoError(failedChild.Insert())
+	runningChild := patch.Patch{
+		Id:        mgobson.NewObjectId(),
+		Status:    evergreen.VersionStarted,
+		Project:   "myChildProject",
+		Activated: true,
+		StartTime: s.patchDoc.StartTime,
+		Triggers: patch.TriggerInfo{
+			ParentPatch: s.patchDoc.Id.Hex(),
+		},
+		DisplayNewUI: true,
+	}
+	s.NoError(runningChild.Insert())
+	s.patchDoc.Triggers.ChildPatches = []string{runningChild.Id.Hex(), failedChild.Id.Hex()}
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+
+	childStatuses := []*message.GithubStatus{}
+	for s.env.InternalSender.HasMessage() {
+		status := s.getAndValidateStatus(s.env.InternalSender)
+		if strings.HasPrefix(status.Context, "evergreen/myChildProjectIdentifier") {
+			childStatuses = append(childStatuses, status)
+		}
+	}
+	s.Require().Len(childStatuses, 1)
+	s.Equal("evergreen/myChildProjectIdentifier", childStatuses[0].Context)
+	s.Equal(message.GithubStateFailure, childStatuses[0].State)
+	s.Equal("2 child patches: 0 succeeded, 1 failed, 1 running", childStatuses[0].Description)
+	s.Equal(fmt.Sprintf("https://example.com/version/%s/downstream-projects?redirect_spruce_users=true", failedChild.Id.Hex()), childStatuses[0].URL)
+}
+
+func (s *githubStatusRefreshSuite) TestUnstartedChildPatchOfFailedParent() {
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: "myBuild",
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildFailed,
+	}
+	s.NoError(b.Insert())
+	s.patchDoc.Status = evergreen.VersionFailed
+
+	childPatch := patch.Patch{
+		Id:        mgobson.NewObjectId(),
+		Status:    evergreen.VersionCreated,
+		Project:   "myChildProject",
+		Activated: true,
+		Triggers: patch.TriggerInfo{
+			ParentPatch: s.patchDoc.Id.Hex(),
+		},
+		DisplayNewUI: true,
+	}
+	s.NoError(childPatch.Insert())
+	s.patchDoc.Triggers.ChildPatches = []string{childPatch.Id.Hex()}
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen", status.Context)
+	s.Equal(message.GithubStateFailure, status.State)
+
+	status = s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen/myChildProjectIdentifier", status.Context)
+	s.Equal(message.GithubStateError, status.State)
+	s.Equal("not run — parent failed", status.Description)
+
+	status = s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen/myBuild", status.Context)
+	s.Equal(message.GithubStateFailure, status.State)
+}
+
+func (s *githubStatusRefreshSuite) TestStatusPendingShowsElapsedTime() {
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: "myBuild",
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildStarted,
+	}
+	s.NoError(b.Insert())
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.clock = func() time.Time { return s.patchDoc.StartTime.Add(12 * time.Minute) }
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen", status.Context)
+	s.Equal(message.GithubStatePending, status.State)
+	s.Equal("tasks are running (12m0s elapsed)", status.Description)
+}
+
+func (s *githubStatusRefreshSuite) TestBuildStatusPendingShowsElapsedTime() {
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: "myBuild",
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildStarted,
+		StartTime:    s.patchDoc.StartTime.Add(time.Minute),
+	}
+	s.NoError(b.Insert())
+	s.NoError((&task.Task{
+		Id:        "t1",
+		Version:   s.patchDoc.Version,
+		BuildId:   b.Id,
+		Status:    evergreen.TaskStarted,
+		Activated: true,
+	}).Insert())
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.clock = func() time.Time { return b.StartTime.Add(5*time.Minute + 30*time.Second) }
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+
+	s.getAndValidateStatus(s.env.InternalSender)
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen/myBuild", status.Context)
+	s.Equal(message.GithubStatePending, status.State)
+	s.Equal("tasks are running (5m0s elapsed)", status.Description)
+}
+
+func (s *githubStatusRefreshSuite) TestBuildStatusExceedingTimeBudget() {
+	pRef := model.ProjectRef{
+		Id:         "myProject",
+		Identifier: "myProjectIdentifier",
+		GithubVariantTimeBudgetSeconds: map[string]int{
+			"slow": 30 * 60,
+			"fast": 2 * 60 * 60,
+		},
+	}
+	s.NoError(pRef.Insert())
+	s.patchDoc.Project = pRef.Id
+
+	startTime := s.patchDoc.StartTime.Add(time.Minute)
+	for _, b := range []build.Build{
+		{Id: "b1", BuildVariant: "slow", Version: s.patchDoc.Version, Status: evergreen.BuildStarted, StartTime: startTime},
+		{Id: "b2", BuildVariant: "fast", Version: s.patchDoc.Version, Status: evergreen.BuildStarted, StartTime: startTime},
+	} {
+		s.NoError(b.Insert())
+	}
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.clock = func() time.Time { return startTime.Add(45 * time.Minute) }
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+
+	s.getAndValidateStatus(s.env.InternalSender)
+	statuses := map[string]*message.GithubStatus{}
+	for i := 0; i < 2; i++ {
+		status := s.getAndValidateStatus(s.env.InternalSender)
+		statuses[status.Context] = status
+	}
+	s.Require().Contains(statuses, "evergreen/slow")
+	s.Equal(message.GithubStateError, statuses["evergreen/slow"].State)
+	s.Equal("exceeded time budget of 30m0s", statuses["evergreen/slow"].Description)
+	s.Require().Contains(statuses, "evergreen/fast")
+	s.Equal(message.GithubStatePending, statuses["evergreen/fast"].State)
+}
+
+func (s *githubStatusRefreshSuite) TestStatusIncludesBaseCommit() {
+	pRef := model.ProjectRef{
+		Id:                     "myProject",
+		Identifier:             "myProjectIdentifier",
+		GithubReportBaseCommit: utility.TruePtr(),
+	}
+	s.NoError(pRef.Insert())
+	s.patchDoc.Project = pRef.Id
+	s.patchDoc.Githash = "0123456789abcdef0123456789abcdef01234567"
+
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: "myBuild",
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildStarted,
+	}
+	s.NoError(b.Insert())
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen", status.Context)
+	s.Equal("[base: 0123456] tasks are running", status.Description)
+
+	status = s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen/myBuild", status.Context)
+	s.NotContains(status.Description, "0123456")
+}
+
+func (s *githubStatusRefreshSuite) TestStatusIncludesPatchAlias() {
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: "myBuild",
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildStarted,
+	}
+	s.NoError(b.Insert())
+	childPatch := patch.Patch{
+		Id:        mgobson.NewObjectId(),
+		Status:    evergreen.VersionStarted,
+		Project:   "myChildProject",
+		Activated: true,
+		Alias:     "unit-tests",
+		Triggers: patch.TriggerInfo{
+			ParentPatch: s.patchDoc.Id.Hex(),
+		},
+	}
+	s.NoError(childPatch.Insert())
+	s.patchDoc.Triggers.ChildPatches = []string{childPatch.Id.Hex()}
+	s.patchDoc.Alias = "lint"
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.Zero(job.Error())
+
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen", status.Context)
+	s.Equal("[alias: lint] tasks are running", status.Description)
+
+	status = s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen/myChildProjectIdentifier", status.Context)
+	s.Equal("[alias: unit-tests] tasks are running", status.Description)
+
+	// Build statuses are already specific to a variant.
+	status = s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen/myBuild", status.Context)
+	s.NotContains(status.Description, "alias")
+}
+
+func (s *githubStatusRefreshSuite) TestStatusOmitsInternalPatchAlias() {
+	s.patchDoc.Alias = evergreen.GithubPRAlias
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.Run(s.ctx)
+
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen", status.Context)
+	s.NotContains(status.Description, "alias")
+}
+
+func (s *githubStatusRefreshSuite) TestStatusForSkippedRequiredVariant() {
+	pRef := model.ProjectRef{
+		Id:                          "myProject",
+		Identifier:                  "myProjectIdentifier",
+		GithubRequiredVariants:      []string{"ran", "skipped"},
+		GithubReportSkippedVariants: utility.TruePtr(),
+	}
+	s.NoError(pRef.Insert())
+	s.patchDoc.Project = pRef.Id
+
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: "ran",
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildStarted,
+	}
+	s.NoError(b.Insert())
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+
+	// Patch status
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen", status.Context)
+
+	// Build status
+	status = s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen/ran", status.Context)
+	s.Equal(message.GithubStatePending, status.State)
+
+	// Skipped variant status
+	status = s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen/skipped", status.Context)
+	s.Equal(message.GithubStateSuccess, status.State)
+	s.Equal(skippedVariantDescription+requiredVariantIndicator, status.Description)
+	s.Equal(fmt.Sprintf("https://example.com/version/%s?redirect_spruce_users=true", s.patchDoc.Version), status.URL)
+}
+
+func (s *githubStatusRefreshSuite) TestStatusEarlyFailureForRunningBuild() {
+	pRef := model.ProjectRef{
+		Id:                       "myProject",
+		Identifier:               "myProjectIdentifier",
+		GithubEarlyFailureStatus: utility.TruePtr(),
+	}
+	s.NoError(pRef.Insert())
+	s.patchDoc.Project = pRef.Id
+
+	for _, tsk := range []task.Task{
+		{
+			Id:           "t1",
+			BuildId:      "b1",
+			BuildVariant: "myBuild",
+			Version:      s.patchDoc.Version,
+			Activated:    true,
+			Status:       evergreen.TaskFailed,
+		},
+		{
+			Id:           "t2",
+			BuildId:      "b1",
+			BuildVariant: "myBuild",
+			Version:      s.patchDoc.Version,
+			Activated:    true,
+			Status:       evergreen.TaskStarted,
+		},
+	} {
+		s.NoError(tsk.Insert())
+	}
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: "myBuild",
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildStarted,
+	}
+	s.NoError(b.Insert())
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+
+	// Patch status
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen", status.Context)
+	s.Equal(message.GithubStatePending, status.State)
+
+	// Build status
+	status = s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen/myBuild", status.Context)
+	s.Equal(message.GithubStateFailure, status.State)
+	s.Equal("1 failed so far, others running", status.Description)
+}
+
+func (s *githubStatusRefreshSuite) TestStatusFailureLinksToSingleFailedTaskLog() {
+	pRef := model.ProjectRef{
+		Id:                      "myProject",
+		Identifier:              "myProjectIdentifier",
+		GithubFailedTaskLogLink: utility.TruePtr(),
+	}
+	s.NoError(pRef.Insert())
+	s.patchDoc.Project = pRef.Id
+
+	for _, tsk := range []task.Task{
+		{
+			Id:           "t1",
+			BuildId:      "b1",
+			BuildVariant: "myBuild",
+			Version:      s.patchDoc.Version,
+			Activated:    true,
+			Execution:    2,
+			Status:       evergreen.TaskFailed,
+		},
+		{
+			Id:           "t2",
+			BuildId:      "b1",
+			BuildVariant: "myBuild",
+			Version:      s.patchDoc.Version,
+			Activated:    true,
+			Status:       evergreen.TaskSucceeded,
+		},
+	} {
+		s.NoError(tsk.Insert())
+	}
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: "myBuild",
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildFailed,
+	}
+	s.NoError(b.Insert())
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+
+	// Patch status
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen", status.Context)
+
+	// Build status
+	status = s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen/myBuild", status.Context)
+	s.Equal(message.GithubStateFailure, status.State)
+	s.Equal("https://example.com/task_log_raw/t1/2?type=T", status.URL)
+}
+
+func (s *githubStatusRefreshSuite) TestStatusPendingElapsedTimeUsesClock() {
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: "myBuild",
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildStarted,
+	}
+	s.NoError(b.Insert())
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.clock = func() time.Time { return s.patchDoc.StartTime.Add(95*time.Minute + 59*time.Second) }
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen", status.Context)
+	s.Equal(message.GithubStatePending, status.State)
+	s.Equal("tasks are running (1h35m0s elapsed)", status.Description)
+}
+
+func (s *githubStatusRefreshSuite) TestStatusPendingShowsRemainingTime() {
+	pRef := model.ProjectRef{
+		Id:                       "myProject",
+		Identifier:               "myProjectIdentifier",
+		GithubCompletionEstimate: utility.TruePtr(),
+	}
+	s.NoError(pRef.Insert())
+	s.patchDoc.Project = pRef.Id
+
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: "myBuild",
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildStarted,
+	}
+	s.NoError(b.Insert())
+	now := s.patchDoc.StartTime.Add(30 * time.Second)
+	for _, t := range []task.Task{
+		{Id: "t1", Version: s.patchDoc.Version, Activated: true, Status: evergreen.TaskStarted, StartTime: now.Add(-2 * time.Minute), ExpectedDuration: 10 * time.Minute},
+		{Id: "t2", Version: s.patchDoc.Version, Activated: true, Status: evergreen.TaskUndispatched, ExpectedDuration: 5 * time.Minute},
+		{Id: "t3", Version: s.patchDoc.Version, Activated: true, Status: evergreen.TaskSucceeded, ExpectedDuration: time.Hour},
+	} {
+		s.NoError(t.Insert())
+	}
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.clock = func() time.Time { return now }
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen", status.Context)
+	s.Equal(message.GithubStatePending, status.State)
+	s.Equal(evergreen.PRTasksRunningDescription+", ~8m remaining", status.Description)
+
+	s.Equal("tasks are running", withRemainingTime("tasks are running", 0))
+	s.Equal("tasks are running, ~1h2m remaining", withRemainingTime("tasks are running", time.Hour+90*time.Second))
+	s.Zero(estimateRemainingTime([]task.Task{{Status: evergreen.TaskUndispatched}}, now))
+}
+
+func (s *githubStatusRefreshSuite) TestStatusPendingDueToEssentialTaskThatWillRun() {
+	tsk := task.Task{
+		Id:                   "t1",
+		BuildId:              "b1",
+		BuildVariant:         "myBuild",
+		Version:              s.patchDoc.Version,
+		Activated:            true,
+		Status:               evergreen.TaskStarted,
+		IsEssentialToSucceed: true,
+	}
+	s.NoError(tsk.Insert())
+
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: "myBuild",
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildStarted,
+	}
+	s.NoError(b.Insert())
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().NotNil(job)
+	s.Require().True(ok)
+	s.Require().NotNil(job.patch)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	// Patch status
+	s.Equal(fmt.Sprintf("https://example.com/version/%s?redirect_spruce_users=true", s.patchDoc.Version), status.URL)
+	s.Equal("evergreen", status.Context)
+	s.Equal(message.GithubStatePending, status.State)
+	s.Equal("tasks are running", status.Description)
+
+	// Build status
+	status = s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal(fmt.Sprintf("https://example.com/build/%s?redirect_spruce_users=true", b.Id), status.URL)
+	s.Equal("evergreen/myBuild", status.Context)
+	s.Equal(message.GithubStatePending, status.State)
+	s.Equal("tasks are running", status.Description)
+}
+
+func (s *githubStatusRefreshSuite) TestStatusWaitingInQueue() {
+	for _, tsk := range []task.Task{
+		{
+			Id:           "t1",
+			BuildId:      "b1",
+			BuildVariant: "myBuild",
+			Version:      s.patchDoc.Version,
+			Activated:    true,
+			Status:       evergreen.TaskUndispatched,
+		},
+		{
+			Id:           "t2",
+			BuildId:      "b1",
+			BuildVariant: "myBuild",
+			Version:      s.patchDoc.Version,
+			Activated:    true,
+			Status:       evergreen.TaskUndispatched,
+		},
+	} {
+		s.NoError(tsk.Insert())
+	}
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: "myBuild",
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildCreated,
+	}
+	s.NoError(b.Insert())
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen", status.Context)
+	s.Equal(message.GithubStatePending, status.State)
+	s.Equal("waiting in queue", status.Description)
+}
+
+func (s *githubStatusRefreshSuite) TestStatusAwaitingManualApproval() {
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: "myBuild",
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildCreated,
+	}
+	s.NoError(b.Insert())
+	s.NoError(s.patchDoc.SetAwaitingManualApproval(true))
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen", status.Context)
+	s.Equal(message.GithubStatePending, status.State)
+	s.Equal("awaiting manual approval", status.Description)
+	s.Equal(fmt.Sprintf("https://example.com/patch/%s?redirect_spruce_users=true", s.patchDoc.Id.Hex()), status.URL)
+}
+
+func (s *githubStatusRefreshSuite) TestStatusPendingDueToAllUnscheduledEssentialTasks() {
+	tsk := task.Task{
+		Id:                   "t1",
+		BuildId:              "b1",
+		BuildVariant:         "myBuild",
+		Version:              s.patchDoc.Version,
+		Activated:            false,
+		Status:               evergreen.TaskUndispatched,
+		IsEssentialToSucceed: true,
+	}
+	s.NoError(tsk.Insert())
 
 	b := build.Build{
 		Id:           "b1",
@@ -216,8 +1348,8 @@ func (s *githubStatusRefreshSuite) TestStatusPendingDueToEssentialTaskThatWillRu
 	job.Run(s.ctx)
 	s.False(job.HasErrors())
 
-	status := s.getAndValidateStatus(s.env.InternalSender)
 	// Patch status
+	status := s.getAndValidateStatus(s.env.InternalSender)
 	s.Equal(fmt.Sprintf("https://example.com/version/%s?redirect_spruce_users=true", s.patchDoc.Version), status.URL)
 	s.Equal("evergreen", status.Context)
 	s.Equal(message.GithubStatePending, status.State)
@@ -228,115 +1360,427 @@ func (s *githubStatusRefreshSuite) TestStatusPendingDueToEssentialTaskThatWillRu
 	s.Equal(fmt.Sprintf("https://example.com/build/%s?redirect_spruce_users=true", b.Id), status.URL)
 	s.Equal("evergreen/myBuild", status.Context)
 	s.Equal(message.GithubStatePending, status.State)
-	s.Equal("tasks are running", status.Description)
+	s.Equal("1 essential task(s) not scheduled", status.Description)
 }
 
-func (s *githubStatusRefreshSuite) TestStatusPendingDueToAllUnscheduledEssentialTasks() {
-	tsk := task.Task{
+func (s *githubStatusRefreshSuite) TestStatusFailedDueToMixOfFailedAndUnscheduledEssentialTasks() {
+	failedTask := task.Task{
 		Id:                   "t1",
 		BuildId:              "b1",
 		BuildVariant:         "myBuild",
 		Version:              s.patchDoc.Version,
+		Activated:            true,
+		Status:               evergreen.TaskFailed,
+		IsEssentialToSucceed: true,
+	}
+	s.NoError(failedTask.Insert())
+	unscheduledEssentialTask := task.Task{
+		Id:                   "t2",
+		BuildId:              "b1",
+		BuildVariant:         "myBuild",
+		Version:              s.patchDoc.Version,
 		Activated:            false,
 		Status:               evergreen.TaskUndispatched,
 		IsEssentialToSucceed: true,
 	}
-	s.NoError(tsk.Insert())
+	s.NoError(unscheduledEssentialTask.Insert())
+
+	startTime := time.Now()
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: "myBuild",
+		StartTime:    startTime,
+		FinishTime:   startTime.Add(time.Minute),
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildFailed,
+	}
+	s.NoError(b.Insert())
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().NotNil(job)
+	s.Require().True(ok)
+	s.Require().NotNil(job.patch)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+
+	// Patch status
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal(fmt.Sprintf("https://example.com/version/%s?redirect_spruce_users=true", s.patchDoc.Version), status.URL)
+	s.Equal("evergreen", status.Context)
+	s.Equal(message.GithubStatePending, status.State)
+	s.Equal("tasks are running", status.Description)
+
+	// Build status
+	status = s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal(fmt.Sprintf("https://example.com/build/%s?redirect_spruce_users=true", b.Id), status.URL)
+	s.Equal("evergreen/myBuild", status.Context)
+	s.Equal(message.GithubStateFailure, status.State)
+	s.Equal("none succeeded, 1 failed, 1 essential task(s) not scheduled in 1m0s", status.Description)
+}
+
+func (s *githubStatusRefreshSuite) TestStatusSucceeded() {
+	startTime := time.Now()
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: "myBuild",
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildSucceeded,
+		StartTime:    startTime,
+		FinishTime:   startTime.Add(time.Minute),
+	}
+	s.NoError(b.Insert())
+	t1 := task.Task{
+		Id:      "t1",
+		Version: s.patchDoc.Version,
+		BuildId: b.Id,
+		Status:  evergreen.TaskSucceeded,
+	}
+	s.NoError(t1.Insert())
+
+	childPatch := patch.Patch{
+		Id:         mgobson.NewObjectId(),
+		Status:     evergreen.VersionSucceeded,
+		Project:    "myChildProject",
+		Activated:  true,
+		StartTime:  startTime,
+		FinishTime: startTime.Add(12 * time.Minute),
+		Triggers: patch.TriggerInfo{
+			ParentPatch: s.patchDoc.Id.Hex(),
+		},
+		DisplayNewUI: true,
+	}
+	s.NoError(childPatch.Insert())
+	s.patchDoc.Triggers.ChildPatches = []string{childPatch.Id.Hex()}
+	s.patchDoc.Status = evergreen.VersionSucceeded
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().NotNil(job)
+	s.Require().True(ok)
+	s.Require().NotNil(job.patch)
+
+	job.env = s.env
+	job.Run(s.ctx)
+	s.Zero(job.Error())
+
+	// Patch status
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal(fmt.Sprintf("https://example.com/version/%s?redirect_spruce_users=true", s.patchDoc.Version), status.URL)
+	s.Equal("evergreen", status.Context)
+	s.Equal(message.GithubStateSuccess, status.State)
+	s.Equal("version finished in 10m0s", status.Description)
+
+	// Child patch status
+	status = s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal(fmt.Sprintf("https://example.com/version/%s/downstream-projects?redirect_spruce_users=true", childPatch.Id.Hex()), status.URL)
+	s.Equal("evergreen/myChildProjectIdentifier", status.Context)
+	s.Equal(message.GithubStateSuccess, status.State)
+	s.Equal("child patch finished in 12m0s", status.Description)
+
+	// Build status
+	status = s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal(fmt.Sprintf("https://example.com/build/%s?redirect_spruce_users=true", b.Id), status.URL)
+	s.Equal("evergreen/myBuild", status.Context)
+	s.Equal("1 succeeded, none failed in 1m0s", status.Description)
+	s.Equal(message.GithubStateSuccess, status.State)
+}
+
+func (s *githubStatusRefreshSuite) TestStatusSucceededNotesRetriedTasks() {
+	pRef := model.ProjectRef{
+		Id:                       "myProject",
+		Identifier:               "myProjectIdentifier",
+		GithubReportRetriedTasks: utility.TruePtr(),
+	}
+	s.NoError(pRef.Insert())
+	s.patchDoc.Project = pRef.Id
+	s.patchDoc.Status = evergreen.VersionSucceeded
+
+	startTime := time.Now()
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: "myBuild",
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildSucceeded,
+		StartTime:    startTime,
+		FinishTime:   startTime.Add(time.Minute),
+	}
+	s.NoError(b.Insert())
+	for _, t := range []task.Task{
+		{Id: "t1", Version: s.patchDoc.Version, BuildId: b.Id, Status: evergreen.TaskSucceeded},
+		{Id: "t2", Version: s.patchDoc.Version, BuildId: b.Id, Status: evergreen.TaskSucceeded, Execution: 1},
+	} {
+		s.NoError(t.Insert())
+	}
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.Zero(job.Error())
+
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen", status.Context)
+	s.Equal(message.GithubStateSuccess, status.State)
+	s.Equal("version finished in 10m0s (1 succeeded after retry)", status.Description)
+
+	status = s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen/myBuild", status.Context)
+	s.Equal(message.GithubStateSuccess, status.State)
+	s.Equal("2 succeeded (1 after retry), none failed in 1m0s", status.Description)
+}
+
+func (s *githubStatusRefreshSuite) TestStatusUsesCustomSuccessDescription() {
+	pRef := model.ProjectRef{
+		Id:                       "myProject",
+		Identifier:               "myProjectIdentifier",
+		GithubSuccessDescription: "All checks green\n— ready to merge after {duration}",
+	}
+	s.NoError(pRef.Insert())
+	s.patchDoc.Project = pRef.Id
+
+	startTime := time.Now()
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: "myBuild",
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildSucceeded,
+		StartTime:    startTime,
+		FinishTime:   startTime.Add(time.Minute),
+	}
+	s.NoError(b.Insert())
+
+	for status, expected := range map[string]string{
+		evergreen.VersionSucceeded: "All checks green — ready to merge after 10m0s",
+		evergreen.VersionFailed:    "version finished in 10m0s",
+	} {
+		s.patchDoc.Status = status
+		job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+		s.Require().True(ok)
+		job.env = s.env
+		job.Run(s.ctx)
+		s.Zero(job.Error())
+
+		patchStatus := s.getAndValidateStatus(s.env.InternalSender)
+		s.Equal("evergreen", patchStatus.Context)
+		s.Equal(expected, patchStatus.Description)
+
+		buildStatus := s.getAndValidateStatus(s.env.InternalSender)
+		s.Equal("evergreen/myBuild", buildStatus.Context)
+	}
+}
+
+func (s *githubStatusRefreshSuite) TestStatusNotesRestartingUser() {
+	v := model.Version{
+		Id:          s.patchDoc.Version,
+		RestartedBy: "alice",
+	}
+	s.NoError(v.Insert())
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: "myBuild",
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildStarted,
+	}
+	s.NoError(b.Insert())
+
+	for author, expected := range map[string]string{
+		"bob":   "restarted by alice — tasks are running",
+		"alice": "tasks are running",
+	} {
+		s.patchDoc.Author = author
+		job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+		s.Require().True(ok)
+		job.env = s.env
+		job.clock = func() time.Time { return s.patchDoc.StartTime }
+		job.Run(s.ctx)
+		s.Zero(job.Error())
+
+		status := s.getAndValidateStatus(s.env.InternalSender)
+		s.Equal("evergreen", status.Context)
+		s.Equal(message.GithubStatePending, status.State)
+		s.Equal(expected, status.Description)
+
+		status = s.getAndValidateStatus(s.env.InternalSender)
+		s.Equal("evergreen/myBuild", status.Context)
+	}
+}
+
+func (s *githubStatusRefreshSuite) TestSuccessfulVariantStatusesCollapsed() {
+	pRef := model.ProjectRef{
+		Id:                            "myProject",
+		Identifier:                    "myProjectIdentifier",
+		GithubCollapseSuccessStatuses: utility.TruePtr(),
+	}
+	s.NoError(pRef.Insert())
+	s.patchDoc.Project = pRef.Id
+	s.patchDoc.Status = evergreen.VersionSucceeded
+
+	for _, b := range []build.Build{
+		{Id: "b1", BuildVariant: "variant1", Version: s.patchDoc.Version, Status: evergreen.BuildSucceeded},
+		{Id: "b2", BuildVariant: "variant2", Version: s.patchDoc.Version, Status: evergreen.BuildSucceeded},
+	} {
+		s.NoError(b.Insert())
+	}
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.Zero(job.Error())
+
+	patchURL := fmt.Sprintf("https://example.com/version/%s?redirect_spruce_users=true", s.patchDoc.Version)
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen", status.Context)
+	s.Equal(message.GithubStateSuccess, status.State)
+	s.Equal("version finished in 10m0s", status.Description)
+
+	for _, variant := range []string{"variant1", "variant2"} {
+		status = s.getAndValidateStatus(s.env.InternalSender)
+		s.Equal("evergreen/"+variant, status.Context)
+		s.Equal(message.GithubStateSuccess, status.State)
+		s.Equal(collapsedStatusDescription, status.Description)
+		s.Equal(patchURL, status.URL)
+	}
+	_, ok = s.env.InternalSender.GetMessageSafe()
+	s.False(ok)
+
+	// Variant statuses aren't collapsed until the patch succeeds.
+	s.patchDoc.Status = evergreen.VersionStarted
+	job, ok = NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.Zero(job.Error())
+
+	s.Equal(message.GithubStatePending, s.getAndValidateStatus(s.env.InternalSender).State)
+	status = s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen/variant1", status.Context)
+	s.NotEqual(collapsedStatusDescription, status.Description)
+	s.Equal("https://example.com/build/b1?redirect_spruce_users=true", status.URL)
+}
+
+func (s *githubStatusRefreshSuite) TestStatusForRestartedPatchUsesLatestRun() {
+	startTime := time.Now()
+	firstRun := build.Build{
+		Id:           "b1",
+		BuildVariant: "myBuild",
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildFailed,
+		CreateTime:   startTime,
+		StartTime:    startTime,
+		FinishTime:   startTime.Add(time.Minute),
+	}
+	s.NoError(firstRun.Insert())
+	s.NoError((&task.Task{
+		Id:      "t1",
+		Version: s.patchDoc.Version,
+		BuildId: firstRun.Id,
+		Status:  evergreen.TaskFailed,
+	}).Insert())
+	s.patchDoc.Status = evergreen.VersionFailed
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.Zero(job.Error())
+
+	s.getAndValidateStatus(s.env.InternalSender)
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen/myBuild", status.Context)
+	s.Equal(message.GithubStateFailure, status.State)
+	_, ok = s.env.InternalSender.GetMessageSafe()
+	s.False(ok)
 
-	b := build.Build{
-		Id:           "b1",
+	// Restarting the patch creates a new build for the same variant.
+	secondRun := build.Build{
+		Id:           "b2",
 		BuildVariant: "myBuild",
 		Version:      s.patchDoc.Version,
-		Status:       evergreen.BuildStarted,
+		Status:       evergreen.BuildSucceeded,
+		CreateTime:   startTime.Add(time.Hour),
+		StartTime:    startTime.Add(time.Hour),
+		FinishTime:   startTime.Add(time.Hour + time.Minute),
 	}
-	s.NoError(b.Insert())
+	s.NoError(secondRun.Insert())
+	s.NoError((&task.Task{
+		Id:      "t2",
+		Version: s.patchDoc.Version,
+		BuildId: secondRun.Id,
+		Status:  evergreen.TaskSucceeded,
+	}).Insert())
+	s.patchDoc.Status = evergreen.VersionSucceeded
 
-	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
-	s.Require().NotNil(job)
+	job, ok = NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
 	s.Require().True(ok)
-	s.Require().NotNil(job.patch)
 	job.env = s.env
 	job.Run(s.ctx)
-	s.False(job.HasErrors())
+	s.Zero(job.Error())
 
-	// Patch status
-	status := s.getAndValidateStatus(s.env.InternalSender)
-	s.Equal(fmt.Sprintf("https://example.com/version/%s?redirect_spruce_users=true", s.patchDoc.Version), status.URL)
+	status = s.getAndValidateStatus(s.env.InternalSender)
 	s.Equal("evergreen", status.Context)
-	s.Equal(message.GithubStatePending, status.State)
-	s.Equal("tasks are running", status.Description)
+	s.Equal(message.GithubStateSuccess, status.State)
 
-	// Build status
 	status = s.getAndValidateStatus(s.env.InternalSender)
-	s.Equal(fmt.Sprintf("https://example.com/build/%s?redirect_spruce_users=true", b.Id), status.URL)
 	s.Equal("evergreen/myBuild", status.Context)
-	s.Equal(message.GithubStatePending, status.State)
-	s.Equal("1 essential task(s) not scheduled", status.Description)
+	s.Equal(fmt.Sprintf("https://example.com/build/%s?redirect_spruce_users=true", secondRun.Id), status.URL)
+	s.Equal(message.GithubStateSuccess, status.State)
+	s.Equal("1 succeeded, none failed in 1m0s", status.Description)
+
+	_, ok = s.env.InternalSender.GetMessageSafe()
+	s.False(ok)
 }
 
-func (s *githubStatusRefreshSuite) TestStatusFailedDueToMixOfFailedAndUnscheduledEssentialTasks() {
-	failedTask := task.Task{
-		Id:                   "t1",
-		BuildId:              "b1",
-		BuildVariant:         "myBuild",
-		Version:              s.patchDoc.Version,
-		Activated:            true,
-		Status:               evergreen.TaskFailed,
-		IsEssentialToSucceed: true,
-	}
-	s.NoError(failedTask.Insert())
-	unscheduledEssentialTask := task.Task{
-		Id:                   "t2",
-		BuildId:              "b1",
-		BuildVariant:         "myBuild",
-		Version:              s.patchDoc.Version,
-		Activated:            false,
-		Status:               evergreen.TaskUndispatched,
-		IsEssentialToSucceed: true,
+func (s *githubStatusRefreshSuite) TestStatusURLsOmitSpruceRedirect() {
+	uiConfig := evergreen.UIConfig{
+		Url:                "https://example.com",
+		OmitSpruceRedirect: true,
 	}
-	s.NoError(unscheduledEssentialTask.Insert())
+	s.Require().NoError(uiConfig.Set(s.ctx))
 
-	startTime := time.Now()
 	b := build.Build{
 		Id:           "b1",
 		BuildVariant: "myBuild",
-		StartTime:    startTime,
-		FinishTime:   startTime.Add(time.Minute),
 		Version:      s.patchDoc.Version,
-		Status:       evergreen.BuildFailed,
+		Status:       evergreen.BuildStarted,
 	}
 	s.NoError(b.Insert())
+	childPatch := patch.Patch{
+		Id:           mgobson.NewObjectId(),
+		Status:       evergreen.VersionStarted,
+		Project:      "myChildProject",
+		Activated:    true,
+		DisplayNewUI: true,
+		Triggers: patch.TriggerInfo{
+			ParentPatch: s.patchDoc.Id.Hex(),
+		},
+	}
+	s.NoError(childPatch.Insert())
+	s.patchDoc.Triggers.ChildPatches = []string{childPatch.Id.Hex()}
 
 	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
-	s.Require().NotNil(job)
 	s.Require().True(ok)
-	s.Require().NotNil(job.patch)
 	job.env = s.env
 	job.Run(s.ctx)
-	s.False(job.HasErrors())
+	s.Zero(job.Error())
 
-	// Patch status
 	status := s.getAndValidateStatus(s.env.InternalSender)
-	s.Equal(fmt.Sprintf("https://example.com/version/%s?redirect_spruce_users=true", s.patchDoc.Version), status.URL)
-	s.Equal("evergreen", status.Context)
-	s.Equal(message.GithubStatePending, status.State)
-	s.Equal("tasks are running", status.Description)
+	s.Equal(fmt.Sprintf("https://example.com/version/%s", s.patchDoc.Version), status.URL)
 
-	// Build status
 	status = s.getAndValidateStatus(s.env.InternalSender)
-	s.Equal(fmt.Sprintf("https://example.com/build/%s?redirect_spruce_users=true", b.Id), status.URL)
-	s.Equal("evergreen/myBuild", status.Context)
-	s.Equal(message.GithubStateFailure, status.State)
-	s.Equal("none succeeded, 1 failed, 1 essential task(s) not scheduled in 1m0s", status.Description)
+	s.Equal(fmt.Sprintf("https://example.com/version/%s/downstream-projects", childPatch.Id.Hex()), status.URL)
+
+	status = s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal(fmt.Sprintf("https://example.com/build/%s", b.Id), status.URL)
 }
 
-func (s *githubStatusRefreshSuite) TestStatusSucceeded() {
+func (s *githubStatusRefreshSuite) TestStatusFailed() {
 	startTime := time.Now()
 	b := build.Build{
 		Id:           "b1",
 		BuildVariant: "myBuild",
 		Version:      s.patchDoc.Version,
-		Status:       evergreen.BuildSucceeded,
+		Status:       evergreen.BuildFailed,
 		StartTime:    startTime,
 		FinishTime:   startTime.Add(time.Minute),
 	}
@@ -345,13 +1789,13 @@ func (s *githubStatusRefreshSuite) TestStatusSucceeded() {
 		Id:      "t1",
 		Version: s.patchDoc.Version,
 		BuildId: b.Id,
-		Status:  evergreen.TaskSucceeded,
+		Status:  evergreen.TaskFailed,
 	}
 	s.NoError(t1.Insert())
 
 	childPatch := patch.Patch{
 		Id:         mgobson.NewObjectId(),
-		Status:     evergreen.VersionSucceeded,
+		Status:     evergreen.VersionFailed,
 		Project:    "myChildProject",
 		Activated:  true,
 		StartTime:  startTime,
@@ -365,6 +1809,8 @@ func (s *githubStatusRefreshSuite) TestStatusSucceeded() {
 	s.patchDoc.Triggers.ChildPatches = []string{childPatch.Id.Hex()}
 	s.patchDoc.Status = evergreen.VersionSucceeded
 
+	s.patchDoc.Status = evergreen.VersionFailed
+
 	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
 	s.Require().NotNil(job)
 	s.Require().True(ok)
@@ -372,96 +1818,355 @@ func (s *githubStatusRefreshSuite) TestStatusSucceeded() {
 
 	job.env = s.env
 	job.Run(s.ctx)
-	s.Zero(job.Error())
+	s.False(job.HasErrors())
 
 	// Patch status
 	status := s.getAndValidateStatus(s.env.InternalSender)
 	s.Equal(fmt.Sprintf("https://example.com/version/%s?redirect_spruce_users=true", s.patchDoc.Version), status.URL)
 	s.Equal("evergreen", status.Context)
-	s.Equal(message.GithubStateSuccess, status.State)
-	s.Equal("version finished in 10m0s", status.Description)
+	s.Equal(message.GithubStateFailure, status.State)
+	s.Equal(testFailuresDescription, status.Description)
 
 	// Child patch status
 	status = s.getAndValidateStatus(s.env.InternalSender)
 	s.Equal(fmt.Sprintf("https://example.com/version/%s/downstream-projects?redirect_spruce_users=true", childPatch.Id.Hex()), status.URL)
 	s.Equal("evergreen/myChildProjectIdentifier", status.Context)
-	s.Equal(message.GithubStateSuccess, status.State)
+	s.Equal(message.GithubStateFailure, status.State)
 	s.Equal("child patch finished in 12m0s", status.Description)
 
 	// Build status
 	status = s.getAndValidateStatus(s.env.InternalSender)
 	s.Equal(fmt.Sprintf("https://example.com/build/%s?redirect_spruce_users=true", b.Id), status.URL)
 	s.Equal("evergreen/myBuild", status.Context)
-	s.Equal("1 succeeded, none failed in 1m0s", status.Description)
-	s.Equal(message.GithubStateSuccess, status.State)
+	s.Equal("none succeeded, 1 failed in 1m0s", status.Description)
+	s.Equal(message.GithubStateFailure, status.State)
 }
 
-func (s *githubStatusRefreshSuite) TestStatusFailed() {
-	startTime := time.Now()
+func (s *githubStatusRefreshSuite) TestStatusErrorForSetupFailures() {
 	b := build.Build{
 		Id:           "b1",
 		BuildVariant: "myBuild",
 		Version:      s.patchDoc.Version,
 		Status:       evergreen.BuildFailed,
-		StartTime:    startTime,
-		FinishTime:   startTime.Add(time.Minute),
 	}
 	s.NoError(b.Insert())
-	t1 := task.Task{
-		Id:      "t1",
-		Version: s.patchDoc.Version,
-		BuildId: b.Id,
-		Status:  evergreen.TaskFailed,
+	for _, t := range []task.Task{
+		{Id: "t1", Version: s.patchDoc.Version, BuildId: b.Id, Status: evergreen.TaskFailed, Details: apimodels.TaskEndDetail{Type: evergreen.CommandTypeSetup}},
+		{Id: "t2", Version: s.patchDoc.Version, BuildId: b.Id, Status: evergreen.TaskFailed, Details: apimodels.TaskEndDetail{Type: evergreen.CommandTypeSystem}},
+		{Id: "t3", Version: s.patchDoc.Version, BuildId: b.Id, Status: evergreen.TaskSucceeded},
+	} {
+		s.NoError(t.Insert())
 	}
-	s.NoError(t1.Insert())
+	s.patchDoc.Status = evergreen.VersionFailed
 
-	childPatch := patch.Patch{
-		Id:         mgobson.NewObjectId(),
-		Status:     evergreen.VersionFailed,
-		Project:    "myChildProject",
-		Activated:  true,
-		StartTime:  startTime,
-		FinishTime: startTime.Add(12 * time.Minute),
-		Triggers: patch.TriggerInfo{
-			ParentPatch: s.patchDoc.Id.Hex(),
-		},
-		DisplayNewUI: true,
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen", status.Context)
+	s.Equal(message.GithubStateError, status.State)
+	s.Equal(setupFailuresDescription, status.Description)
+}
+
+func (s *githubStatusRefreshSuite) TestSummaryCommentForFinishedPatch() {
+	pRef := model.ProjectRef{
+		Id:                   "myProject",
+		Identifier:           "myProjectIdentifier",
+		GithubSummaryComment: utility.TruePtr(),
 	}
-	s.NoError(childPatch.Insert())
-	s.patchDoc.Triggers.ChildPatches = []string{childPatch.Id.Hex()}
-	s.patchDoc.Status = evergreen.VersionSucceeded
+	s.NoError(pRef.Insert())
+	s.patchDoc.Project = pRef.Id
+
+	for _, b := range []build.Build{
+		{Id: "b1", BuildVariant: "variant1", Version: s.patchDoc.Version, Status: evergreen.BuildSucceeded},
+		{Id: "b2", BuildVariant: "variant2", Version: s.patchDoc.Version, Status: evergreen.BuildFailed},
+		{Id: "b3", BuildVariant: "variant3", Version: s.patchDoc.Version, Status: evergreen.BuildSucceeded},
+	} {
+		s.NoError(b.Insert())
+	}
+	run := func() {
+		job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+		s.Require().True(ok)
+		job.env = s.env
+		job.Run(s.ctx)
+		s.False(job.HasErrors())
+
+		for i := 0; i < 4; i++ {
+			s.getAndValidateStatus(s.env.InternalSender)
+		}
+	}
+
+	// Nothing is posted while the patch is running.
+	s.patchDoc.Status = evergreen.VersionStarted
+	run()
+	_, ok := s.env.InternalSender.GetMessageSafe()
+	s.False(ok)
 
 	s.patchDoc.Status = evergreen.VersionFailed
+	run()
+	msg, ok := s.env.InternalSender.GetMessageSafe()
+	s.Require().True(ok)
+	comment, ok := msg.Message.Raw().(*githubSummaryComment)
+	s.Require().True(ok)
+	s.Equal("evergreen-ci", comment.Owner)
+	s.Equal("evergreen", comment.Repo)
+	s.Equal(448, comment.PRNumber)
+	s.Equal([]string{"variant1", "variant3"}, comment.Succeeded)
+	s.Equal([]string{"variant2"}, comment.Failed)
+	s.Contains(msg.Rendered, "2 of 3 variants succeeded")
+	_, ok = s.env.InternalSender.GetMessageSafe()
+	s.False(ok)
+
+	dbPatch, err := patch.FindOneId(s.patchDoc.Id.Hex())
+	s.Require().NoError(err)
+	s.Require().NotNil(dbPatch)
+	s.True(dbPatch.GithubSummaryCommentSent)
+
+	// Refreshing the finished patch again doesn't post another comment.
+	s.True(s.patchDoc.GithubSummaryCommentSent)
+	run()
+	_, ok = s.env.InternalSender.GetMessageSafe()
+	s.False(ok)
+}
+
+func (s *githubStatusRefreshSuite) TestSummaryCommentOmitsSpruceRedirect() {
+	uiConfig := evergreen.UIConfig{
+		Url:                "https://example.com",
+		OmitSpruceRedirect: true,
+	}
+	s.Require().NoError(uiConfig.Set(s.ctx))
+	pRef := model.ProjectRef{
+		Id:                   "myProject",
+		Identifier:           "myProjectIdentifier",
+		GithubSummaryComment: utility.TruePtr(),
+	}
+	s.NoError(pRef.Insert())
+	s.patchDoc.Project = pRef.Id
+	s.patchDoc.Status = evergreen.VersionSucceeded
+	b := build.Build{Id: "b1", BuildVariant: "variant1", Version: s.patchDoc.Version, Status: evergreen.BuildSucceeded}
+	s.NoError(b.Insert())
 
 	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
-	s.Require().NotNil(job)
 	s.Require().True(ok)
-	s.Require().NotNil(job.patch)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
 
+	var comment *githubSummaryComment
+	for {
+		msg, ok := s.env.InternalSender.GetMessageSafe()
+		if !ok {
+			break
+		}
+		if c, isComment := msg.Message.Raw().(*githubSummaryComment); isComment {
+			comment = c
+		}
+	}
+	s.Require().NotNil(comment)
+	s.Equal(fmt.Sprintf("https://example.com/version/%s", s.patchDoc.Id.Hex()), comment.URL)
+}
+
+func (s *githubStatusRefreshSuite) TestBuildStatusesCappedToFailedBuilds() {
+	pRef := model.ProjectRef{
+		Id:                     "myProject",
+		Identifier:             "myProjectIdentifier",
+		GithubMaxBuildStatuses: 2,
+	}
+	s.NoError(pRef.Insert())
+	s.patchDoc.Project = pRef.Id
+
+	for _, b := range []build.Build{
+		{Id: "b1", BuildVariant: "variant1", Version: s.patchDoc.Version, Status: evergreen.BuildSucceeded},
+		{Id: "b2", BuildVariant: "variant2", Version: s.patchDoc.Version, Status: evergreen.BuildFailed},
+		{Id: "b3", BuildVariant: "variant3", Version: s.patchDoc.Version, Status: evergreen.BuildStarted},
+	} {
+		s.NoError(b.Insert())
+	}
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
 	job.env = s.env
 	job.Run(s.ctx)
 	s.False(job.HasErrors())
 
-	// Patch status
+	s.Equal("evergreen", s.getAndValidateStatus(s.env.InternalSender).Context)
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen/variant2", status.Context)
+	s.Equal(message.GithubStateFailure, status.State)
+	_, ok = s.env.InternalSender.GetMessageSafe()
+	s.False(ok)
+}
+
+func (s *githubStatusRefreshSuite) TestModuleStatusesForMultiModulePatch() {
+	pRef := model.ProjectRef{
+		Id:                   "myProject",
+		Identifier:           "myProjectIdentifier",
+		GithubModuleStatuses: utility.TruePtr(),
+	}
+	s.NoError(pRef.Insert())
+	s.patchDoc.Project = pRef.Id
+	s.patchDoc.Patches = []patch.ModulePatch{
+		{ModuleName: ""},
+		{ModuleName: "module1"},
+		{ModuleName: "module2"},
+	}
+
+	v := model.Version{Id: s.patchDoc.Version}
+	s.NoError(v.Insert())
+	pp := &model.ParserProject{}
+	s.NoError(util.UnmarshalYAMLWithFallback([]byte(`
+tasks:
+- name: t1
+buildvariants:
+- name: variant1
+  modules: [module1]
+  tasks: [t1]
+- name: variant2
+  modules: [module1, module2]
+  tasks: [t1]
+- name: variant3
+  modules: [module2]
+  tasks: [t1]
+`), &pp))
+	pp.Id = v.Id
+	s.NoError(pp.Insert())
+
+	for _, b := range []build.Build{
+		{Id: "b1", BuildVariant: "variant1", Version: s.patchDoc.Version, Status: evergreen.BuildSucceeded},
+		{Id: "b2", BuildVariant: "variant2", Version: s.patchDoc.Version, Status: evergreen.BuildSucceeded},
+		{Id: "b3", BuildVariant: "variant3", Version: s.patchDoc.Version, Status: evergreen.BuildFailed},
+	} {
+		s.NoError(b.Insert())
+	}
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+
+	for i := 0; i < 4; i++ {
+		s.getAndValidateStatus(s.env.InternalSender)
+	}
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen/module/module1", status.Context)
+	s.Equal(message.GithubStateSuccess, status.State)
+	s.Equal(fmt.Sprintf("https://example.com/version/%s?redirect_spruce_users=true", s.patchDoc.Version), status.URL)
+	status = s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen/module/module2", status.Context)
+	s.Equal(message.GithubStateFailure, status.State)
+	_, ok = s.env.InternalSender.GetMessageSafe()
+	s.False(ok)
+}
+
+func (s *githubStatusRefreshSuite) TestStatusNoTasksScheduled() {
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+	s.True(job.noTasksScheduled)
+
 	status := s.getAndValidateStatus(s.env.InternalSender)
 	s.Equal(fmt.Sprintf("https://example.com/version/%s?redirect_spruce_users=true", s.patchDoc.Version), status.URL)
 	s.Equal("evergreen", status.Context)
 	s.Equal(message.GithubStateFailure, status.State)
-	s.Equal("version finished in 10m0s", status.Description)
+	s.Equal("no tasks were scheduled for this patch", status.Description)
 
-	// Child patch status
-	status = s.getAndValidateStatus(s.env.InternalSender)
-	s.Equal(fmt.Sprintf("https://example.com/version/%s/downstream-projects?redirect_spruce_users=true", childPatch.Id.Hex()), status.URL)
-	s.Equal("evergreen/myChildProjectIdentifier", status.Context)
+	_, ok = s.env.InternalSender.GetMessageSafe()
+	s.False(ok)
+}
+
+func (s *githubStatusRefreshSuite) TestStatusDequeuedFromCommitQueue() {
+	s.patchDoc.Alias = evergreen.CommitQueueAlias
+	s.patchDoc.Status = evergreen.VersionFailed
+	s.patchDoc.CommitQueueDequeueReason = "merge conflict"
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: "myBuild",
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildFailed,
+	}
+	s.NoError(b.Insert())
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Equal("evergreen", status.Context)
 	s.Equal(message.GithubStateFailure, status.State)
-	s.Equal("child patch finished in 12m0s", status.Description)
+	s.Equal("removed from commit queue: merge conflict", status.Description)
+}
+
+func (s *githubStatusRefreshSuite) TestStatusTruncatedToGithubLimits() {
+	s.patchDoc.Status = evergreen.VersionFailed
+	b := build.Build{
+		Id:           "b1",
+		BuildVariant: strings.Repeat("v", 300),
+		Version:      s.patchDoc.Version,
+		Status:       evergreen.BuildFailed,
+	}
+	s.NoError(b.Insert())
+
+	job, ok := NewGithubStatusRefreshJob(s.patchDoc).(*githubStatusRefreshJob)
+	s.Require().True(ok)
+	job.env = s.env
+	job.Run(s.ctx)
+	s.False(job.HasErrors())
+
+	// Patch status
+	s.getAndValidateStatus(s.env.InternalSender)
 
 	// Build status
-	status = s.getAndValidateStatus(s.env.InternalSender)
-	s.Equal(fmt.Sprintf("https://example.com/build/%s?redirect_spruce_users=true", b.Id), status.URL)
-	s.Equal("evergreen/myBuild", status.Context)
-	s.Equal("none succeeded, 1 failed in 1m0s", status.Description)
-	s.Equal(message.GithubStateFailure, status.State)
+	status := s.getAndValidateStatus(s.env.InternalSender)
+	s.Len(status.Context, githubStatusContextMaxLength)
+	s.True(strings.HasPrefix(status.Context, "evergreen/vvv"))
+}
+
+func TestSanitizeGithubStatus(t *testing.T) {
+	t.Run("ShortFieldsUnchanged", func(t *testing.T) {
+		status := message.GithubStatus{
+			Context:     "evergreen/myBuild",
+			Description: "tasks are running",
+		}
+		assert.Equal(t, status, sanitizeGithubStatus(status))
+	})
+	t.Run("LongDescriptionTruncated", func(t *testing.T) {
+		status := sanitizeGithubStatus(message.GithubStatus{
+			Context:     "evergreen",
+			Description: strings.Repeat("a", 200),
+		})
+		assert.Len(t, status.Description, githubStatusDescriptionMaxLength)
+		assert.True(t, strings.HasSuffix(status.Description, "aaa..."))
+		assert.Equal(t, "evergreen", status.Context)
+	})
+	t.Run("LongContextTruncated", func(t *testing.T) {
+		status := sanitizeGithubStatus(message.GithubStatus{
+			Context:     "evergreen/" + strings.Repeat("v", 300),
+			Description: "tasks are running",
+		})
+		assert.Len(t, status.Context, githubStatusContextMaxLength)
+		assert.True(t, strings.HasPrefix(status.Context, "evergreen/vvv"))
+		assert.Equal(t, "tasks are running", status.Description)
+	})
+	t.Run("MultibyteDescriptionTruncatedByCharacter", func(t *testing.T) {
+		status := sanitizeGithubStatus(message.GithubStatus{
+			Description: strings.Repeat("é", 200),
+		})
+		assert.Equal(t, githubStatusDescriptionMaxLength, utf8.RuneCountInString(status.Description))
+	})
+}
+
+func TestWithoutSpruceRedirect(t *testing.T) {
+	assert.Equal(t, "https://example.com/build/b1", withoutSpruceRedirect("https://example.com/build/b1?redirect_spruce_users=true"))
+	assert.Equal(t, "https://example.com/build/b1?type=T", withoutSpruceRedirect("https://example.com/build/b1?redirect_spruce_users=true&type=T"))
+	assert.Equal(t, "https://example.com/build/b1", withoutSpruceRedirect("https://example.com/build/b1"))
 }
 
 func (s *githubStatusRefreshSuite) getAndValidateStatus(sender *send.InternalSender) *message.GithubStatus {
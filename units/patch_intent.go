@@ -443,6 +443,10 @@ func (j *patchIntentProcessor) finishPatch(ctx context.Context, patchDoc *patch.
 				"alias":         patchDoc.Alias,
 			})
 		}
+	} else if !canFinalize && j.IntentType == patch.GithubIntentType {
+		// The PR author isn't authorized, so the patch's tasks are held
+		// until someone approves them.
+		catcher.Wrap(patchDoc.SetAwaitingManualApproval(true), "marking patch as awaiting manual approval")
 	}
 
 	return catcher.Resolve()
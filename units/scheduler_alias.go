@@ -7,8 +7,10 @@ import (
 
 	"github.com/evergreen-ci/evergreen"
 	"github.com/evergreen-ci/evergreen/model/distro"
+	"github.com/evergreen-ci/evergreen/model/host"
 	"github.com/evergreen-ci/evergreen/model/task"
 	"github.com/evergreen-ci/evergreen/scheduler"
+	"github.com/evergreen-ci/utility"
 	"github.com/mongodb/amboy"
 	"github.com/mongodb/amboy/job"
 	"github.com/mongodb/amboy/registry"
@@ -83,10 +85,34 @@ func (j *distroAliasSchedulerJob) Run(ctx context.Context) {
 	if d == nil {
 		return
 	}
+	settings, err := evergreen.GetConfig(ctx)
+	if err != nil {
+		j.AddError(errors.Wrap(err, "getting scheduler settings"))
+		return
+	}
+	if _, err = d.GetResolvedPlannerSettings(settings); err != nil {
+		j.AddError(err)
+		return
+	}
+	var numIdleHosts *int
+	if d.PlannerSettings.ShouldScaleByIdleHosts() {
+		idleHosts, err := host.IdleHostsWithDistroID(ctx, d.Id)
+		grip.Warning(message.WrapError(err, message.Fields{
+			"message": "could not find idle hosts",
+			"runner":  scheduler.RunnerName,
+			"distro":  j.DistroID,
+			"alias":   true,
+			"job":     j.ID(),
+		}))
+		if err == nil {
+			numIdleHosts = utility.ToIntPtr(len(idleHosts))
+		}
+	}
 	plan, err := scheduler.PrioritizeTasks(d, tasks, scheduler.TaskPlannerOptions{
 		StartedAt:        startAt,
 		ID:               j.ID(),
 		IsSecondaryQueue: true,
+		IdleHosts:        numIdleHosts,
 	})
 	if err != nil {
 		j.AddError(err)
@@ -3,6 +3,7 @@ package util
 import (
 	"errors"
 	"fmt"
+	"math"
 	"time"
 )
 
@@ -86,6 +87,25 @@ type DurationStats struct {
 	StdDev  time.Duration
 }
 
+// Percentile estimates the duration below which the given percentage of
+// durations fall, assuming that they're normally distributed. The
+// percentile must be between 0 and 100, exclusive. If it isn't, or
+// there's no standard deviation to estimate from, the average is
+// returned.
+func (s DurationStats) Percentile(percentile float64) time.Duration {
+	if s.StdDev <= 0 || percentile <= 0 || percentile >= 100 {
+		return s.Average
+	}
+
+	z := math.Sqrt2 * math.Erfinv(2*percentile/100-1)
+	estimate := time.Duration(float64(s.Average) + z*float64(s.StdDev))
+	if estimate < 0 {
+		return 0
+	}
+
+	return estimate
+}
+
 // CachedDurationValueRefresher provides a mechanism for CachedDurationValues to
 // update their values when the current cached value
 // expires. Implementations are responsible for logging errors, as
@@ -112,3 +112,19 @@ func TestCachedDurationValue(t *testing.T) {
 	assert.NoError(cv.SetRefresher(trueRefresher))
 	assert.NoError(cv.SetRefresher(falseRefresher))
 }
+
+func TestDurationStatsPercentile(t *testing.T) {
+	assert := assert.New(t)
+	stats := DurationStats{Average: 10 * time.Minute, StdDev: 5 * time.Minute}
+
+	assert.Equal(stats.Average, stats.Percentile(50))
+	assert.InDelta(float64(16*time.Minute+24*time.Second), float64(stats.Percentile(90)), float64(time.Second))
+	assert.True(stats.Percentile(10) < stats.Average)
+	assert.Equal(time.Duration(0), stats.Percentile(0.001))
+
+	// fall back to the average without a percentile or a standard
+	// deviation.
+	assert.Equal(stats.Average, stats.Percentile(0))
+	assert.Equal(stats.Average, stats.Percentile(100))
+	assert.Equal(10*time.Minute, DurationStats{Average: 10 * time.Minute}.Percentile(90))
+}